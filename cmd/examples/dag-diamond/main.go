@@ -95,5 +95,12 @@ func main() {
 	}
 
 	fmt.Printf("Execution order: %v\n", order)
+
+	// Group tasks into parallel waves
+	levels, err := graph.Levels()
+	if err != nil {
+		log.Fatalf("Levels: %v", err)
+	}
+	fmt.Printf("Parallel waves: %v\n", levels)
 	fmt.Println("DAG Diamond workflow created successfully!")
 }
@@ -0,0 +1,186 @@
+package executor
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tarGz writes the file or directory at path to w as a gzipped tarball.
+// As in Argo, entries are named relative to the parent of path, so the
+// tarball holds a single top-level entry named after it.
+func tarGz(path string, w io.Writer, level int) error {
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	parent := filepath.Dir(filepath.Clean(path))
+	err = filepath.WalkDir(path, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return fmt.Errorf("%s: unsupported file type %s", p, info.Mode().Type())
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(parent, p)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", path, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// isTarGz reports whether the file at path is a gzipped tarball.
+func isTarGz(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return false, nil
+	}
+	defer gz.Close()
+	_, err = tar.NewReader(gz).Next()
+	return err == nil, nil
+}
+
+// untarGz extracts the gzipped tarball at src into the directory dir.
+// Only directories and regular files are extracted, and no entry may be
+// written outside dir.
+func untarGz(src, dir string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	root := filepath.Clean(dir)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", src, err)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return fmt.Errorf("extract %s: invalid entry %q", src, header.Name)
+		}
+		mode := header.FileInfo().Mode()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode.Perm()|0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return fmt.Errorf("extract %s: %w", src, err)
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("extract %s: unsupported entry %q", src, header.Name)
+		}
+	}
+}
+
+// unpack moves a loaded artifact from src to dst. A gzipped tarball is
+// extracted first; if it holds a single top-level entry, as the tarballs
+// Argo writes do, that entry becomes dst.
+func unpack(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() {
+		archived, err := isTarGz(src)
+		if err != nil {
+			return err
+		}
+		if archived {
+			dir := src + ".d"
+			if err := untarGz(src, dir); err != nil {
+				return err
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return err
+			}
+			src = dir
+			if len(entries) == 1 {
+				src = filepath.Join(dir, entries[0].Name())
+			}
+		}
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("move artifact to %s: %w", dst, err)
+	}
+	return nil
+}
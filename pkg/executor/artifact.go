@@ -0,0 +1,437 @@
+package executor
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/vjranagit/argo-workflows/pkg/workflow"
+)
+
+// ArtifactDriver moves artifacts between their location and the local
+// filesystem, like the drivers of the Argo executor. Archiving is left to
+// the executor: a driver stores and returns bytes as they are.
+// Implementations must be safe for concurrent use.
+type ArtifactDriver interface {
+	// Load copies the artifact at a's location to path, which must not
+	// exist. It returns an error wrapping ErrArtifactNotFound if there is
+	// no artifact at the location.
+	Load(ctx context.Context, a *workflow.Artifact, path string) error
+	// Save copies the file or directory at path to a's location,
+	// replacing what is there.
+	Save(ctx context.Context, path string, a *workflow.Artifact) error
+	// List returns the keys of the objects stored at a's location: its
+	// own key, or the keys below it if the artifact is a directory.
+	List(ctx context.Context, a *workflow.Artifact) ([]string, error)
+	// Delete removes the artifact at a's location. Deleting an artifact
+	// that does not exist is not an error.
+	Delete(ctx context.Context, a *workflow.Artifact) error
+}
+
+// ErrArtifactNotFound is returned by an ArtifactDriver loading an artifact
+// that does not exist.
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// objectKey returns the bucket and key of an artifact stored in an object
+// store. For Azure, the bucket is the container.
+func objectKey(a *workflow.Artifact) (bucket, key string, err error) {
+	switch {
+	case a.S3 != nil:
+		bucket, key = a.S3.Bucket, a.S3.Key
+	case a.OSS != nil:
+		bucket, key = a.OSS.Bucket, a.OSS.Key
+	case a.GCS != nil:
+		bucket, key = a.GCS.Bucket, a.GCS.Key
+	case a.Azure != nil:
+		bucket, key = a.Azure.Container, a.Azure.Blob
+	case a.HDFS != nil:
+		key = a.HDFS.Path
+	default:
+		return "", "", fmt.Errorf("artifact %q is not in an object store", a.Name)
+	}
+	if key == "" {
+		return "", "", fmt.Errorf("artifact %q has no key", a.Name)
+	}
+	return bucket, key, nil
+}
+
+// DirDriver is an ArtifactDriver that keeps artifacts in a local
+// directory, standing in for an object store: the object at key in bucket
+// is the file dir/bucket/key. It serves artifacts in any key-addressed
+// location, that is s3, oss, gcs, azure and hdfs.
+type DirDriver struct {
+	dir string
+}
+
+// NewDirDriver creates a driver that stores artifacts under dir. The
+// directory is created on the first save.
+func NewDirDriver(dir string) *DirDriver {
+	return &DirDriver{dir: dir}
+}
+
+// Load copies the artifact's file or directory to path.
+func (d *DirDriver) Load(ctx context.Context, a *workflow.Artifact, path string) error {
+	src, err := d.path(a)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("load %s: %w", src, ErrArtifactNotFound)
+	}
+	return copyPath(src, path)
+}
+
+// Save copies the file or directory at path into the driver's directory.
+// The previous artifact is only replaced once the copy is complete.
+func (d *DirDriver) Save(ctx context.Context, path string, a *workflow.Artifact) error {
+	dst, err := d.path(a)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("create artifact directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), ".save-")
+	if err != nil {
+		return fmt.Errorf("create artifact directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	staged := filepath.Join(tmp, "artifact")
+	if err := copyPath(path, staged); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("replace %s: %w", dst, err)
+	}
+	if err := os.Rename(staged, dst); err != nil {
+		return fmt.Errorf("replace %s: %w", dst, err)
+	}
+	return nil
+}
+
+// List returns the artifact's key, or the keys of the files below it.
+func (d *DirDriver) List(ctx context.Context, a *workflow.Artifact) ([]string, error) {
+	root, err := d.path(a)
+	if err != nil {
+		return nil, err
+	}
+	_, key, _ := objectKey(a)
+	key = strings.TrimSuffix(key, "/")
+
+	var keys []string
+	err = filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && p == root:
+			return nil
+		case err != nil:
+			return err
+		case entry.IsDir():
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			keys = append(keys, key)
+		} else {
+			keys = append(keys, key+"/"+filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", root, err)
+	}
+	return keys, nil
+}
+
+// Delete removes the artifact's file or directory.
+func (d *DirDriver) Delete(ctx context.Context, a *workflow.Artifact) error {
+	p, err := d.path(a)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(p); err != nil {
+		return fmt.Errorf("delete %s: %w", p, err)
+	}
+	return nil
+}
+
+// path returns where the artifact is kept. Keys are cleaned as if they
+// were absolute, so they cannot refer to files outside the directory.
+func (d *DirDriver) path(a *workflow.Artifact) (string, error) {
+	bucket, key, err := objectKey(a)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(bucket, `/\`) || bucket == ".." {
+		return "", fmt.Errorf("artifact %q has an invalid bucket %q", a.Name, bucket)
+	}
+	return filepath.Join(d.dir, bucket, filepath.FromSlash(path.Clean("/"+key))), nil
+}
+
+// copyPath copies the file or directory tree at src to dst.
+func copyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		}
+		return fmt.Errorf("%s: unsupported file type %s", p, info.Mode().Type())
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// driver returns the artifact driver, which every location but raw needs.
+func (op *operation) driver(a *workflow.Artifact) (ArtifactDriver, error) {
+	if op.exec.artifacts == nil {
+		return nil, fmt.Errorf("artifact %q: no artifact driver is configured", a.Name)
+	}
+	return op.exec.artifacts, nil
+}
+
+// hasArtifacts reports whether a template has input or output artifacts.
+func hasArtifacts(tmpl *workflow.Template) bool {
+	return (tmpl.Inputs != nil && len(tmpl.Inputs.Artifacts) > 0) ||
+		(tmpl.Outputs != nil && len(tmpl.Outputs.Artifacts) > 0)
+}
+
+// artifactDir returns the artifact directory of the node id, below a
+// directory created for the run, so that artifact paths from the manifest
+// never touch the host's files. Like a pod's filesystem, it starts out
+// empty.
+func (op *operation) artifactDir(id string) (string, error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	if op.artifactRoot == "" {
+		root, err := os.MkdirTemp("", "artifacts-")
+		if err != nil {
+			return "", fmt.Errorf("create artifact directory: %w", err)
+		}
+		op.artifactRoot = root
+	}
+	dir := filepath.Join(op.artifactRoot, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create artifact directory: %w", err)
+	}
+	return dir, nil
+}
+
+// removeArtifactDirs removes the artifact directories of the run.
+func (op *operation) removeArtifactDirs() {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if op.artifactRoot != "" {
+		os.RemoveAll(op.artifactRoot)
+	}
+}
+
+// localPath returns where the artifact at path p is kept in the artifact
+// directory dir. Paths that resolve to dir itself or outside it are
+// rejected.
+func localPath(dir, p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	target := filepath.Join(dir, filepath.FromSlash(p))
+	if !strings.HasPrefix(target, filepath.Clean(dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the artifact directory", p)
+	}
+	return target, nil
+}
+
+// loadArtifacts places the input artifacts of a task's template at their
+// paths in its artifact directory, extracting tarballs. Optional artifacts
+// that were not supplied, or that do not exist, are skipped.
+func (op *operation) loadArtifacts(ctx context.Context, task *Task) error {
+	tmpl := task.Template
+	if tmpl.Inputs == nil {
+		return nil
+	}
+	for i := range tmpl.Inputs.Artifacts {
+		a := &tmpl.Inputs.Artifacts[i]
+		if !a.HasLocation() && a.Optional {
+			continue
+		}
+		if err := op.loadArtifact(ctx, task.ArtifactDir, a); err != nil {
+			return fmt.Errorf("load inputs.artifacts.%s: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+func (op *operation) loadArtifact(ctx context.Context, dir string, a *workflow.Artifact) error {
+	dst, err := localPath(dir, a.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	// Only this run writes to the artifact directory, so anything at the
+	// path was left by an earlier attempt at the same node.
+	if err := os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("replace %s: %w", a.Path, err)
+	}
+
+	if a.Raw != nil {
+		if err := os.WriteFile(dst, []byte(a.Raw.Data), 0o644); err != nil {
+			return err
+		}
+		return chmodFiles(dst, a.Mode)
+	}
+
+	driver, err := op.driver(a)
+	if err != nil {
+		return err
+	}
+	// Load next to the destination, so that it can be moved into place.
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), ".artifact-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	loaded := filepath.Join(tmp, "artifact")
+	if err := driver.Load(ctx, a, loaded); err != nil {
+		if a.Optional && errors.Is(err, ErrArtifactNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := unpack(loaded, dst); err != nil {
+		return err
+	}
+	return chmodFiles(dst, a.Mode)
+}
+
+// saveArtifacts saves the output artifacts of a task's template from its
+// artifact directory and returns them with their locations. Artifacts
+// without a location of their own are saved under
+// {{workflow.name}}/{{pod.name}}/<name>.tgz, Argo's default key, in the
+// driver's default bucket.
+func (op *operation) saveArtifacts(ctx context.Context, task *Task) ([]workflow.Artifact, error) {
+	tmpl := task.Template
+	if tmpl.Outputs == nil {
+		return nil, nil
+	}
+	var saved []workflow.Artifact
+	for _, a := range tmpl.Outputs.Artifacts {
+		ok, err := op.saveArtifact(ctx, task.NodeID, task.ArtifactDir, &a)
+		if err != nil {
+			return nil, fmt.Errorf("save outputs.artifacts.%s: %w", a.Name, err)
+		}
+		if ok {
+			saved = append(saved, a)
+		}
+	}
+	return saved, nil
+}
+
+// saveArtifact saves a, setting its location if it has none. It returns
+// false for an optional artifact whose path does not exist.
+func (op *operation) saveArtifact(ctx context.Context, id, dir string, a *workflow.Artifact) (bool, error) {
+	src, err := localPath(dir, a.Path)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(src); err != nil {
+		if a.Optional && errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	archive := a.Archive == nil || a.Archive.None == nil
+	if !a.HasLocation() {
+		key := op.wf.Name + "/" + id + "/" + a.Name
+		if archive {
+			key += ".tgz"
+		}
+		a.S3 = &workflow.S3Artifact{Key: key}
+	}
+	driver, err := op.driver(a)
+	if err != nil {
+		return false, err
+	}
+
+	if archive {
+		level := gzip.DefaultCompression
+		if a.Archive != nil && a.Archive.Tar != nil && a.Archive.Tar.CompressionLevel != nil {
+			level = int(*a.Archive.Tar.CompressionLevel)
+		}
+		f, err := os.CreateTemp("", "artifact-*.tgz")
+		if err != nil {
+			return false, err
+		}
+		defer os.Remove(f.Name())
+		err = tarGz(src, f, level)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return false, err
+		}
+		src = f.Name()
+	}
+
+	if err := driver.Save(ctx, src, a); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// chmodFiles sets the permissions of the file at path, or of every file
+// below it, if mode is set.
+func chmodFiles(path string, mode *int32) error {
+	if mode == nil {
+		return nil
+	}
+	return filepath.WalkDir(path, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		return os.Chmod(p, fs.FileMode(*mode))
+	})
+}
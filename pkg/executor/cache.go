@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vjranagit/argo-workflows/pkg/workflow"
+)
+
+// Cache stores the outputs of memoized templates. Entries are grouped into
+// named caches, the way the controller keeps each cache in a ConfigMap.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Load returns the entry stored under key, or nil if there is none.
+	Load(ctx context.Context, cache, key string) (*CacheEntry, error)
+	// Save stores entry under key, replacing any previous entry.
+	Save(ctx context.Context, cache, key string, entry *CacheEntry) error
+}
+
+// CacheEntry is a memoized result, encoded like the entries the controller
+// writes to its cache ConfigMaps.
+type CacheEntry struct {
+	NodeID    string            `json:"nodeID"`
+	Outputs   *workflow.Outputs `json:"outputs,omitempty"`
+	CreatedAt metav1.Time       `json:"creationTimestamp"`
+}
+
+// DirCache is a Cache kept on disk, so memoized results survive between
+// runs. Each named cache is a JSON file in the directory mapping keys to
+// entries.
+//
+// Saves read, update and rewrite the whole file under a lock that is only
+// held within the process. Executors in different processes must not share
+// a directory: when they save at the same time, one overwrites the entries
+// of the other.
+type DirCache struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDirCache creates a cache that stores its files in dir. The directory
+// is created on the first save.
+func NewDirCache(dir string) *DirCache {
+	return &DirCache{dir: dir}
+}
+
+// Load returns the entry stored under key in the named cache.
+func (c *DirCache) Load(ctx context.Context, cache, key string) (*CacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.read(cache)
+	if err != nil {
+		return nil, err
+	}
+	return entries[key], nil
+}
+
+// Save stores entry under key in the named cache. The file is replaced
+// atomically, so a run that is interrupted never leaves it half written.
+func (c *DirCache) Save(ctx context.Context, cache, key string, entry *CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.read(cache)
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = make(map[string]*CacheEntry)
+	}
+	entries[key] = entry
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cache %q: %w", cache, err)
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("create cache directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(c.dir, cache+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write cache %q: %w", cache, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write cache %q: %w", cache, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write cache %q: %w", cache, err)
+	}
+	if err := os.Rename(f.Name(), c.path(cache)); err != nil {
+		return fmt.Errorf("write cache %q: %w", cache, err)
+	}
+	return nil
+}
+
+// read loads the entries of the named cache; a cache that was never saved
+// has none.
+func (c *DirCache) read(cache string) (map[string]*CacheEntry, error) {
+	if cache == "" || cache == "." || cache == ".." || strings.ContainsAny(cache, `/\`) {
+		return nil, fmt.Errorf("invalid cache name %q", cache)
+	}
+
+	data, err := os.ReadFile(c.path(cache))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache %q: %w", cache, err)
+	}
+
+	var entries map[string]*CacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("decode cache %q: %w", cache, err)
+	}
+	return entries, nil
+}
+
+func (c *DirCache) path(cache string) string {
+	return filepath.Join(c.dir, cache+".json")
+}
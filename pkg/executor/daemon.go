@@ -0,0 +1,176 @@
+package executor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vjranagit/argo-workflows/pkg/workflow"
+)
+
+// daemon is a running daemon template. Like the controller, the executor
+// lets the tasks or steps after a daemon start once it is ready, and stops
+// it when the DAG or steps template that called it completes.
+type daemon struct {
+	boundaryID string
+	// nodes are the attempt node and, with a retry strategy, its Retry
+	// node; both take the daemon's final phase.
+	nodes []string
+	stop  context.CancelFunc
+	done  chan struct{}
+
+	// releases free the parallelism slot and synchronization locks the
+	// daemon holds for as long as it runs.
+	mu       sync.Mutex
+	exited   bool
+	releases []func()
+}
+
+// hold defers release until the daemon exits, or calls it at once if d is
+// nil or the daemon has already exited.
+func (d *daemon) hold(release func()) {
+	if d != nil {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if !d.exited {
+			d.releases = append(d.releases, release)
+			return
+		}
+	}
+	release()
+}
+
+// exit runs the releases held by the daemon, the last acquired first.
+func (d *daemon) exit() {
+	d.mu.Lock()
+	d.exited = true
+	releases := d.releases
+	d.releases = nil
+	d.mu.Unlock()
+
+	for i := len(releases) - 1; i >= 0; i-- {
+		releases[i]()
+	}
+}
+
+// outcome is what a runner returned.
+type outcome struct {
+	res *Result
+	err error
+}
+
+// executeDaemon starts a daemon and returns once the runner reports it
+// ready through Task.Ready, leaving it running in the background. A daemon
+// that exits before it is ready completes like any other task, and nil is
+// returned.
+func (op *operation) executeDaemon(ctx context.Context, task *Task) *daemon {
+	id := task.NodeID
+	// The daemon outlives this call, so it must not be stopped by the
+	// deadlines of the attempt; stopDaemons stops it instead.
+	dctx, stop := context.WithCancel(context.WithoutCancel(ctx))
+
+	ready := make(chan string, 1)
+	task.Ready = func(ip string) {
+		select {
+		case ready <- ip:
+		default:
+		}
+	}
+	finished := make(chan outcome, 1)
+	go func() {
+		res, err := op.exec.runner.Run(dctx, task)
+		finished <- outcome{res: res, err: err}
+	}()
+
+	var ip string
+	select {
+	case ip = <-ready:
+	case o := <-finished:
+		stop()
+		op.finishLeaf(ctx, task, o.res, o.err)
+		return nil
+	case <-ctx.Done():
+		stop()
+		<-finished
+		op.markNode(id, workflow.PhaseFailed, ctx.Err().Error(), nil)
+		return nil
+	}
+
+	d := &daemon{nodes: []string{id}, stop: stop, done: make(chan struct{})}
+	op.mu.Lock()
+	n := op.wf.Status.Nodes[id]
+	daemoned := true
+	n.Daemoned = &daemoned
+	n.PodIP = ip
+	op.wf.Status.Nodes[id] = n
+	d.boundaryID = n.BoundaryID
+	op.daemons[id] = d
+	op.mu.Unlock()
+
+	go func() {
+		o := <-finished
+		if dctx.Err() != nil {
+			// Stopped because its caller completed, not by failing.
+			op.markNode(id, workflow.PhaseSucceeded, "", nil)
+		} else {
+			op.finishLeaf(dctx, task, o.res, o.err)
+		}
+		d.exit()
+
+		op.mu.Lock()
+		defer op.mu.Unlock()
+		final := op.wf.Status.Nodes[id]
+		for _, other := range d.nodes[1:] {
+			n := op.wf.Status.Nodes[other]
+			n.Phase, n.Message, n.Outputs, n.FinishedAt = final.Phase, final.Message, final.Outputs, final.FinishedAt
+			op.wf.Status.Nodes[other] = n
+		}
+		close(d.done)
+	}()
+	return d
+}
+
+// adoptDaemon marks the Retry node parentID as daemoned like its attempt
+// childID, so that it is reported ready and finishes with the daemon.
+func (op *operation) adoptDaemon(childID, parentID string) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	child := op.wf.Status.Nodes[childID]
+	n := op.wf.Status.Nodes[parentID]
+	n.Daemoned = child.Daemoned
+	n.PodIP = child.PodIP
+	op.wf.Status.Nodes[parentID] = n
+
+	if d, ok := op.daemons[childID]; ok {
+		select {
+		case <-d.done:
+			// The daemon already exited; take its phase now.
+			n.Phase, n.Message, n.Outputs, n.FinishedAt = child.Phase, child.Message, child.Outputs, child.FinishedAt
+			op.wf.Status.Nodes[parentID] = n
+		default:
+			d.nodes = append(d.nodes, parentID)
+		}
+	}
+}
+
+// stopDaemons stops the daemons started within the template whose node is
+// boundaryID, or by the workflow itself if it is empty, and waits for them
+// to exit.
+func (op *operation) stopDaemons(boundaryID string) {
+	op.mu.Lock()
+	var stopping []*daemon
+	for id, d := range op.daemons {
+		if d.boundaryID == boundaryID {
+			stopping = append(stopping, d)
+			delete(op.daemons, id)
+		}
+	}
+	op.mu.Unlock()
+
+	for _, d := range stopping {
+		d.stop()
+	}
+	for _, d := range stopping {
+		<-d.done
+	}
+}
@@ -0,0 +1,1344 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/vjranagit/argo-workflows/pkg/expr"
+	"github.com/vjranagit/argo-workflows/pkg/workflow"
+)
+
+// Executor runs workflows in-process.
+// Unlike the Argo workflow controller, which reconciles Workflow resources
+// and schedules pods, this walks the template tree directly and hands each
+// leaf template to a TaskRunner. Node names, IDs, types and phases follow the
+// controller's conventions so the resulting WorkflowStatus looks the same.
+type Executor struct {
+	runner    TaskRunner
+	now       func() time.Time
+	templates *workflow.TemplateStore
+	cache     Cache
+	locks     *LockManager
+	artifacts ArtifactDriver
+}
+
+// Option is a functional option for executor configuration.
+type Option func(*Executor)
+
+// WithClock overrides the clock used for node timestamps.
+func WithClock(now func() time.Time) Option {
+	return func(e *Executor) {
+		e.now = now
+	}
+}
+
+// WithTemplateStore sets the store that workflowTemplateRef and
+// templateRef references are resolved from.
+func WithTemplateStore(store *workflow.TemplateStore) Option {
+	return func(e *Executor) {
+		e.templates = store
+	}
+}
+
+// WithCache sets the cache that memoized templates store their outputs in.
+// Without one, memoize settings are ignored and every template runs.
+func WithCache(cache Cache) Option {
+	return func(e *Executor) {
+		e.cache = cache
+	}
+}
+
+// WithLockManager sets the lock manager that enforces synchronization.
+// By default every executor has its own, shared by the workflows it runs.
+func WithLockManager(m *LockManager) Option {
+	return func(e *Executor) {
+		e.locks = m
+	}
+}
+
+// WithArtifactDriver sets the driver that input artifacts are loaded and
+// output artifacts saved with. Output artifacts without a location are
+// saved to its default bucket. Without one, only raw artifacts can be
+// used.
+func WithArtifactDriver(driver ArtifactDriver) Option {
+	return func(e *Executor) {
+		e.artifacts = driver
+	}
+}
+
+// New creates an executor that delegates leaf templates to runner.
+func New(runner TaskRunner, opts ...Option) *Executor {
+	e := &Executor{
+		runner: runner,
+		now:    time.Now,
+		locks:  NewLockManager(),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Run executes the workflow's entrypoint until every node has completed.
+// The workflow's Status is filled in as execution progresses and returned on
+// completion. A failing task is reported through the status phase, not as an
+// error; errors are reserved for workflows that cannot be started at all and
+// for context cancellation.
+func (e *Executor) Run(ctx context.Context, wf *workflow.Workflow) (*workflow.WorkflowStatus, error) {
+	if ref := wf.Spec.WorkflowTemplateRef; ref != nil {
+		base, err := e.templateStore().Spec(ref)
+		if err != nil {
+			return nil, fmt.Errorf("workflowTemplateRef: %w", err)
+		}
+
+		// Run a copy with the joined spec so the caller's spec is left as
+		// written; only the status is copied back.
+		joined := *wf
+		joined.Spec = workflow.JoinWorkflowSpec(&wf.Spec, base)
+		joined.Spec.WorkflowTemplateRef = nil
+		status, err := e.Run(ctx, &joined)
+		if status == nil {
+			return nil, err
+		}
+		wf.Name = joined.Name
+		wf.Status = *status
+		return &wf.Status, err
+	}
+
+	if wf.Spec.Entrypoint == "" {
+		return nil, fmt.Errorf("entrypoint is required")
+	}
+
+	if wf.Name == "" {
+		if wf.GenerateName == "" {
+			return nil, fmt.Errorf("name or generateName is required")
+		}
+		wf.Name = wf.GenerateName + randomSuffix()
+	}
+
+	op := newOperation(e, wf)
+	defer op.removeArtifactDirs()
+	tmpl, err := op.template(wf.Spec.Entrypoint)
+	if err != nil {
+		return nil, err
+	}
+
+	wf.Status = workflow.WorkflowStatus{
+		Phase:     workflow.PhaseRunning,
+		StartedAt: op.now(),
+		Nodes:     make(map[string]workflow.Node),
+	}
+
+	// The deadline stops the entrypoint, but not the exit handler.
+	runCtx := ctx
+	if wf.Spec.ActiveDeadline != nil {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, time.Duration(*wf.Spec.ActiveDeadline)*time.Second)
+		defer cancel()
+	}
+
+	// Workflow locks are held until the exit handler has run.
+	release := func() {}
+	if s := wf.Spec.Synchronization; s != nil {
+		if release, err = op.acquireLocks(runCtx, s, ""); err != nil {
+			op.mu.Lock()
+			wf.Status.Phase = lockErrorPhase(runCtx)
+			wf.Status.Message = err.Error()
+			wf.Status.FinishedAt = op.now()
+			op.mu.Unlock()
+			return &wf.Status, ctx.Err()
+		}
+	}
+
+	rootID := op.executeTemplate(runCtx, wf.Name, wf.Name, tmpl, wf.Spec.Arguments, "", "")
+	op.stopDaemons("")
+
+	op.mu.Lock()
+	root := wf.Status.Nodes[rootID]
+	wf.Status.Phase = root.Phase
+	wf.Status.Message = root.Message
+	op.mu.Unlock()
+
+	if wf.Spec.OnExit != "" {
+		op.executeOnExit(ctx)
+		op.stopDaemons("")
+	}
+	release()
+
+	op.mu.Lock()
+	wf.Status.FinishedAt = op.now()
+	op.mu.Unlock()
+
+	return &wf.Status, ctx.Err()
+}
+
+// executeOnExit runs the workflow's exit handler once the entrypoint has
+// completed. As in the controller, a failing exit handler fails an
+// otherwise successful workflow.
+func (op *operation) executeOnExit(ctx context.Context) {
+	name := op.wf.Name + ".onExit"
+
+	var id string
+	if tmpl, err := op.template(op.wf.Spec.OnExit); err != nil {
+		id = op.errorNode(name, name, op.wf.Spec.OnExit, "", err)
+	} else {
+		// The exit handler runs even if the workflow was cancelled.
+		id = op.executeTemplate(context.WithoutCancel(ctx), name, name, tmpl, nil, "")
+	}
+
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if n := op.wf.Status.Nodes[id]; isFailure(n.Phase) && op.wf.Status.Phase == workflow.PhaseSucceeded {
+		op.wf.Status.Phase = workflow.PhaseFailed
+		op.wf.Status.Message = n.Message
+	}
+}
+
+// operation holds the state of a single workflow run.
+// It plays the role of the controller's per-workflow operation context.
+type operation struct {
+	exec     *Executor
+	wf       *workflow.Workflow
+	resolver *workflow.Resolver
+	sem      chan struct{}
+
+	// owners maps templates reached through a templateRef to the spec
+	// that holds them; all other templates belong to the workflow.
+	owners map[*workflow.Template]*workflow.WorkflowSpec
+
+	// daemons holds the running daemons by the ID of their node.
+	daemons map[string]*daemon
+
+	// artifactRoot holds the artifact directories of the run's nodes. It
+	// is created when the first node with artifacts runs.
+	artifactRoot string
+
+	mu sync.Mutex
+}
+
+func newOperation(e *Executor, wf *workflow.Workflow) *operation {
+	op := &operation{
+		exec:     e,
+		wf:       wf,
+		resolver: workflow.NewResolver(wf),
+		owners:   make(map[*workflow.Template]*workflow.WorkflowSpec),
+		daemons:  make(map[string]*daemon),
+	}
+
+	if wf.Spec.Parallelism != nil && *wf.Spec.Parallelism > 0 {
+		op.sem = make(chan struct{}, *wf.Spec.Parallelism)
+	}
+
+	return op
+}
+
+func (op *operation) now() metav1.Time {
+	return metav1.NewTime(op.exec.now())
+}
+
+// scope returns the variables visible to every template: the workflow's
+// globals plus workflow.status and workflow.failures as of now.
+func (op *operation) scope() workflow.Scope {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.resolver.Scope().SetWorkflowStatus(&op.wf.Status)
+}
+
+// template looks up a template of the workflow by name.
+func (op *operation) template(name string) (*workflow.Template, error) {
+	return op.lookup(target{spec: &op.wf.Spec, name: name})
+}
+
+// target names the template a task, step or hook calls. Like the
+// controller's template resolution context, plain names are looked up in
+// spec, the spec holding the calling template, so templates reached through
+// a templateRef can call their siblings.
+type target struct {
+	spec *workflow.WorkflowSpec
+	name string
+	ref  *workflow.TemplateRef
+}
+
+// String returns the name recorded as the node's template.
+func (t target) String() string {
+	if t.ref != nil {
+		return t.ref.Template
+	}
+	return t.name
+}
+
+// specOf returns the spec holding tmpl.
+func (op *operation) specOf(tmpl *workflow.Template) *workflow.WorkflowSpec {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if spec, ok := op.owners[tmpl]; ok {
+		return spec
+	}
+	return &op.wf.Spec
+}
+
+// lookup resolves a target to its template.
+func (op *operation) lookup(t target) (*workflow.Template, error) {
+	if t.ref != nil {
+		tmpl, spec, err := op.exec.templateStore().Template(t.ref)
+		if err != nil {
+			return nil, fmt.Errorf("templateRef: %w", err)
+		}
+		op.mu.Lock()
+		op.owners[tmpl] = spec
+		op.mu.Unlock()
+		return tmpl, nil
+	}
+
+	for i := range t.spec.Templates {
+		if t.spec.Templates[i].Name == t.name {
+			return &t.spec.Templates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("template %q not found", t.name)
+}
+
+// templateStore returns the configured store, or an empty one so that
+// references fail with a not found error.
+func (e *Executor) templateStore() *workflow.TemplateStore {
+	if e.templates == nil {
+		return workflow.NewTemplateStore()
+	}
+	return e.templates
+}
+
+// nodeID derives a node ID from its name the same way the controller does:
+// the root node shares the workflow name, every other node appends an FNV
+// hash of its fully qualified name.
+func (op *operation) nodeID(name string) string {
+	if name == op.wf.Name {
+		return op.wf.Name
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return fmt.Sprintf("%s-%v", op.wf.Name, h.Sum32())
+}
+
+// initNode records a new node and links it to its parents.
+func (op *operation) initNode(name, displayName, nodeType, templateName, boundaryID string, parents ...string) string {
+	id := op.nodeID(name)
+
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	op.wf.Status.Nodes[id] = workflow.Node{
+		ID:           id,
+		Name:         name,
+		DisplayName:  displayName,
+		Type:         nodeType,
+		TemplateName: templateName,
+		Phase:        workflow.PhaseRunning,
+		BoundaryID:   boundaryID,
+		StartedAt:    op.now(),
+	}
+
+	for _, parent := range parents {
+		if parent == "" {
+			continue
+		}
+		p := op.wf.Status.Nodes[parent]
+		p.Children = append(p.Children, id)
+		op.wf.Status.Nodes[parent] = p
+	}
+
+	return id
+}
+
+// markNode moves a node to a terminal phase.
+func (op *operation) markNode(id, phase, message string, outputs *workflow.Outputs) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	n := op.wf.Status.Nodes[id]
+	n.Phase = phase
+	n.Message = message
+	n.Outputs = outputs
+	n.FinishedAt = op.now()
+	op.wf.Status.Nodes[id] = n
+}
+
+// node returns a snapshot of a node.
+func (op *operation) node(id string) workflow.Node {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.wf.Status.Nodes[id]
+}
+
+// executeTemplate creates the node for a template invocation, runs it to
+// completion and returns the node ID. args must already be resolved.
+func (op *operation) executeTemplate(ctx context.Context, name, displayName string, tmpl *workflow.Template, args *workflow.Arguments, boundaryID string, parents ...string) string {
+	if tmpl.Memoize != nil && op.exec.cache != nil {
+		return op.executeMemoized(ctx, name, displayName, tmpl, args, boundaryID, parents...)
+	}
+	return op.executeTimeout(ctx, name, displayName, tmpl, args, boundaryID, parents...)
+}
+
+// executeMemoized runs a template with memoize set. As in the controller,
+// the key is looked up in the template's cache first: on a hit the node
+// succeeds at once with the cached outputs, otherwise the template runs and
+// its outputs are cached if it succeeds. A cache that cannot be read or
+// written only costs the reuse, so those errors do not fail the node.
+// Entries are found by the resolved key alone; workflow.Fingerprint is not
+// applied.
+func (op *operation) executeMemoized(ctx context.Context, name, displayName string, tmpl *workflow.Template, args *workflow.Arguments, boundaryID string, parents ...string) string {
+	memo := tmpl.Memoize
+	if memo.Cache == nil || memo.Cache.ConfigMap == nil {
+		return op.errorNode(name, displayName, tmpl.Name, boundaryID, fmt.Errorf("memoize cache is required"), parents...)
+	}
+	params, err := resolveInputs(tmpl, args)
+	if err != nil {
+		return op.errorNode(name, displayName, tmpl.Name, boundaryID, err, parents...)
+	}
+	key, missing := op.scope().SetInputs(params).Replace(memo.Key)
+	if len(missing) > 0 {
+		return op.errorNode(name, displayName, tmpl.Name, boundaryID,
+			fmt.Errorf("unable to resolve memoize key %q: unresolved reference {{%s}}", memo.Key, missing[0]), parents...)
+	}
+	var maxAge time.Duration
+	if memo.MaxAge != "" {
+		if maxAge, err = workflow.ParseDuration(memo.MaxAge); err != nil {
+			return op.errorNode(name, displayName, tmpl.Name, boundaryID, err, parents...)
+		}
+	}
+
+	status := &workflow.MemoizationStatus{Key: key, CacheName: memo.Cache.ConfigMap.Name}
+	entry, err := op.exec.cache.Load(ctx, status.CacheName, key)
+	if err == nil && entry != nil && (maxAge == 0 || op.exec.now().Sub(entry.CreatedAt.Time) <= maxAge) {
+		status.Hit = true
+		id := op.initNode(name, displayName, templateNodeType(tmpl), tmpl.Name, boundaryID, parents...)
+		op.setMemoizationStatus(id, status)
+		op.markNode(id, workflow.PhaseSucceeded, "", entry.Outputs)
+		return id
+	}
+
+	id := op.executeTimeout(ctx, name, displayName, tmpl, args, boundaryID, parents...)
+	op.setMemoizationStatus(id, status)
+	if n := op.node(id); n.Phase == workflow.PhaseSucceeded {
+		_ = op.exec.cache.Save(ctx, status.CacheName, key, &CacheEntry{NodeID: id, Outputs: n.Outputs, CreatedAt: op.now()})
+	}
+	return id
+}
+
+// setMemoizationStatus records where a memoized node's outputs came from.
+func (op *operation) setMemoizationStatus(id string, status *workflow.MemoizationStatus) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	n := op.wf.Status.Nodes[id]
+	n.MemoizationStatus = status
+	op.wf.Status.Nodes[id] = n
+}
+
+// executeTimeout runs a template under its timeout, which covers its node
+// including all retries.
+func (op *operation) executeTimeout(ctx context.Context, name, displayName string, tmpl *workflow.Template, args *workflow.Arguments, boundaryID string, parents ...string) string {
+	if tmpl.Timeout != nil && tmpl.Timeout.Duration != "" {
+		timeout, err := op.timeout(tmpl, args)
+		if err != nil {
+			return op.errorNode(name, displayName, tmpl.Name, boundaryID, err, parents...)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if tmpl.RetryStrategy != nil {
+		return op.executeRetries(ctx, name, displayName, tmpl, args, boundaryID, parents...)
+	}
+	return op.executeAttempt(ctx, name, displayName, tmpl, args, boundaryID, parents...)
+}
+
+// executeRetries runs a template with a retry strategy. As in the
+// controller, a Retry node is the parent of one child per attempt, named
+// "name(0)", "name(1)" and so on, and takes the phase and outputs of the
+// last one. workflow.ShouldRetry decides whether another attempt is made.
+func (op *operation) executeRetries(ctx context.Context, name, displayName string, tmpl *workflow.Template, args *workflow.Arguments, boundaryID string, parents ...string) string {
+	id := op.initNode(name, displayName, workflow.NodeTypeRetry, tmpl.Name, boundaryID, parents...)
+
+	strategy := *tmpl.RetryStrategy
+	if strategy.Expression != "" {
+		params, err := resolveInputs(tmpl, args)
+		if err != nil {
+			op.markNode(id, workflow.PhaseError, err.Error(), nil)
+			return id
+		}
+		strategy.Expression, _ = op.scope().SetInputs(params).Replace(strategy.Expression)
+	}
+
+	for attempt := 0; ; attempt++ {
+		childID := op.executeAttempt(ctx, fmt.Sprintf("%s(%d)", name, attempt), fmt.Sprintf("%s(%d)", displayName, attempt), tmpl, args, boundaryID, id)
+		child := op.node(childID)
+
+		result := workflow.AttemptResult{
+			Attempt:  attempt + 1,
+			Phase:    child.Phase,
+			Message:  child.Message,
+			Duration: child.FinishedAt.Sub(child.StartedAt.Time),
+		}
+		if child.Outputs != nil && child.Outputs.ExitCode != nil {
+			if code, err := strconv.Atoi(*child.Outputs.ExitCode); err == nil {
+				result.ExitCode = &code
+			}
+		}
+
+		retry, delay, err := workflow.ShouldRetry(&strategy, result)
+		switch {
+		case err != nil:
+			op.markNode(id, workflow.PhaseError, err.Error(), child.Outputs)
+			return id
+		case child.Daemoned != nil:
+			op.adoptDaemon(childID, id)
+			return id
+		case !retry || ctx.Err() != nil:
+			op.markNode(id, child.Phase, child.Message, child.Outputs)
+			return id
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			op.markNode(id, child.Phase, child.Message, child.Outputs)
+			return id
+		}
+	}
+}
+
+// executeAttempt runs a template once under a node of its own type,
+// holding its synchronization locks and limited by its
+// activeDeadlineSeconds. A daemon holds the locks until it exits.
+func (op *operation) executeAttempt(ctx context.Context, name, displayName string, tmpl *workflow.Template, args *workflow.Arguments, boundaryID string, parents ...string) string {
+	nodeType := templateNodeType(tmpl)
+	id := op.initNode(name, displayName, nodeType, tmpl.Name, boundaryID, parents...)
+
+	var d *daemon
+	if tmpl.Synchronization != nil {
+		release, err := op.acquireLocks(ctx, tmpl.Synchronization, id)
+		if err != nil {
+			op.markNode(id, lockErrorPhase(ctx), err.Error(), nil)
+			return id
+		}
+		defer func() { d.hold(release) }()
+	}
+
+	params, err := resolveInputs(tmpl, args)
+	if err != nil {
+		op.markNode(id, workflow.PhaseError, err.Error(), nil)
+		return id
+	}
+	artifacts, err := resolveInputArtifacts(tmpl, args)
+	if err != nil {
+		op.markNode(id, workflow.PhaseError, err.Error(), nil)
+		return id
+	}
+	scope := op.scope().SetInputs(params).SetInputArtifacts(artifacts)
+
+	if tmpl.ActiveDeadlineSeconds != nil {
+		deadline, err := activeDeadline(tmpl.ActiveDeadlineSeconds, scope)
+		if err != nil {
+			op.markNode(id, workflow.PhaseError, err.Error(), nil)
+			return id
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	switch nodeType {
+	case workflow.NodeTypeDAG:
+		phase, message := op.executeDAG(ctx, id, name, tmpl, scope)
+		op.stopDaemons(id)
+		op.markNode(id, phase, message, nil)
+	case workflow.NodeTypeSteps:
+		phase, message := op.executeSteps(ctx, id, name, tmpl, scope)
+		op.stopDaemons(id)
+		op.markNode(id, phase, message, nil)
+	default:
+		d = op.executeLeaf(ctx, id, name, tmpl, params, scope)
+	}
+	return id
+}
+
+// templateNodeType returns the type of the node that runs tmpl.
+func templateNodeType(tmpl *workflow.Template) string {
+	switch {
+	case tmpl.DAG != nil:
+		return workflow.NodeTypeDAG
+	case tmpl.Steps != nil:
+		return workflow.NodeTypeSteps
+	}
+	return workflow.NodeTypePod
+}
+
+// timeout resolves a template's timeout, which may refer to its inputs.
+func (op *operation) timeout(tmpl *workflow.Template, args *workflow.Arguments) (time.Duration, error) {
+	params, err := resolveInputs(tmpl, args)
+	if err != nil {
+		return 0, err
+	}
+	value, missing := op.scope().SetInputs(params).Replace(tmpl.Timeout.Duration)
+	if len(missing) > 0 {
+		return 0, fmt.Errorf("unable to resolve timeout %q: unresolved reference {{%s}}", tmpl.Timeout.Duration, missing[0])
+	}
+	return workflow.ParseDuration(value)
+}
+
+// activeDeadline resolves a template's activeDeadlineSeconds, which may
+// refer to its inputs.
+func activeDeadline(value *intstr.IntOrString, scope workflow.Scope) (time.Duration, error) {
+	if value.Type == intstr.Int {
+		return time.Duration(value.IntVal) * time.Second, nil
+	}
+
+	s, missing := scope.Replace(value.StrVal)
+	if len(missing) > 0 {
+		return 0, fmt.Errorf("unable to resolve activeDeadlineSeconds %q: unresolved reference {{%s}}", value.StrVal, missing[0])
+	}
+	seconds, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid activeDeadlineSeconds %q", s)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// executeLeaf renders a Container, Script or ContainerSet template and
+// hands it to the runner. For a daemon that is still running, it returns
+// the daemon, which then holds the parallelism slot until it exits.
+func (op *operation) executeLeaf(ctx context.Context, id, name string, tmpl *workflow.Template, params map[string]string, scope workflow.Scope) (d *daemon) {
+	if tmpl.Container == nil && tmpl.Script == nil && tmpl.ContainerSet == nil {
+		op.markNode(id, workflow.PhaseError, fmt.Sprintf("template %q has nothing to execute", tmpl.Name), nil)
+		return nil
+	}
+
+	rendered, errs := op.resolver.Resolve(tmpl, scope)
+	if len(errs) > 0 {
+		op.markNode(id, workflow.PhaseError, errs.ToAggregate().Error(), nil)
+		return nil
+	}
+
+	if op.sem != nil {
+		select {
+		case op.sem <- struct{}{}:
+			defer func() { d.hold(func() { <-op.sem }) }()
+		case <-ctx.Done():
+			op.markNode(id, workflow.PhaseFailed, ctx.Err().Error(), nil)
+			return nil
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		op.markNode(id, workflow.PhaseFailed, err.Error(), nil)
+		return nil
+	}
+
+	task := &Task{
+		NodeID:     id,
+		NodeName:   name,
+		Template:   rendered,
+		Parameters: params,
+	}
+	if hasArtifacts(rendered) {
+		var err error
+		if task.ArtifactDir, err = op.artifactDir(id); err != nil {
+			op.markNode(id, workflow.PhaseError, err.Error(), nil)
+			return nil
+		}
+	}
+	if err := op.loadArtifacts(ctx, task); err != nil {
+		op.markNode(id, workflow.PhaseError, err.Error(), nil)
+		return nil
+	}
+
+	if tmpl.Daemon != nil && *tmpl.Daemon {
+		return op.executeDaemon(ctx, task)
+	}
+
+	res, err := op.exec.runner.Run(ctx, task)
+	op.finishLeaf(ctx, task, res, err)
+	return nil
+}
+
+// finishLeaf records the outcome of a runner call.
+func (op *operation) finishLeaf(ctx context.Context, task *Task, res *Result, err error) {
+	id, rendered := task.NodeID, task.Template
+	if err != nil {
+		// A runner that gives up because of a deadline or cancellation
+		// has failed, like a pod the controller stops.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			op.markNode(id, workflow.PhaseFailed, ctxErr.Error(), nil)
+			return
+		}
+		op.markNode(id, workflow.PhaseError, err.Error(), nil)
+		return
+	}
+	if res == nil {
+		res = &Result{}
+	}
+
+	outputs, err := collectOutputs(rendered, res)
+	if err != nil {
+		op.markNode(id, workflow.PhaseError, err.Error(), nil)
+		return
+	}
+
+	if res.ExitCode != 0 {
+		op.markNode(id, workflow.PhaseFailed, res.failureMessage(), outputs)
+		return
+	}
+
+	if outputs.Artifacts, err = op.saveArtifacts(ctx, task); err != nil {
+		op.markNode(id, workflow.PhaseError, err.Error(), outputs)
+		return
+	}
+
+	op.markNode(id, workflow.PhaseSucceeded, res.Message, outputs)
+}
+
+// taskDone reports the completion of a DAG task.
+type taskDone struct {
+	name string
+	id   string
+}
+
+// executeDAG schedules DAG tasks as their dependencies complete.
+// Like the controller's default failFast behaviour, no new tasks are started
+// once any task has failed; tasks that never ran are recorded as Omitted.
+// A failure that a depends expression handles, by testing the task for
+// Failed, Errored, AllFailed or AnySucceeded, neither stops nor fails the
+// DAG.
+func (op *operation) executeDAG(ctx context.Context, dagID, dagName string, tmpl *workflow.Template, scope workflow.Scope) (string, string) {
+	tasks := tmpl.DAG.Tasks
+	if err := workflow.NewDependencyGraph(tasks).Validate(); err != nil {
+		return workflow.PhaseError, err.Error()
+	}
+	depends, handled, err := parseDepends(tasks)
+	if err != nil {
+		return workflow.PhaseError, err.Error()
+	}
+	path := op.resolver.TemplatePath(tmpl.Name).Child("dag", "tasks")
+	spec := op.specOf(tmpl)
+
+	ids := make(map[string]string, len(tasks))
+	results := make(map[string]workflow.TaskResult, len(tasks))
+	started := make(map[string]bool, len(tasks))
+	done := make(chan taskDone)
+	running := 0
+	failed := ""
+	fail := func(task, id string) {
+		if failed == "" && !handled[task] {
+			failed = id
+		}
+	}
+
+	for {
+		for i := range tasks {
+			task := &tasks[i]
+			if started[task.Name] || failed != "" || ctx.Err() != nil {
+				continue
+			}
+
+			ready, satisfied := dependenciesMet(task, depends[task.Name], results)
+			if !ready {
+				continue
+			}
+
+			started[task.Name] = true
+			taskName := dagName + "." + task.Name
+			parents := dependencyNodes(task, dagID, ids)
+			tgt := target{spec: spec, name: task.Template, ref: task.TemplateRef}
+
+			if !satisfied {
+				ids[task.Name] = op.skipNode(taskName, task.Name, tgt.String(), dagID, workflow.PhaseOmitted, omittedMessage, parents...)
+				results[task.Name] = workflow.TaskResult{Phase: workflow.PhaseOmitted}
+				continue
+			}
+
+			argsPath := path.Index(i).Child("arguments")
+
+			if task.Looped() {
+				expanded, err := task.Expand(scope)
+				switch {
+				case err != nil:
+					ids[task.Name] = op.errorNode(taskName, task.Name, tgt.String(), dagID, err, parents...)
+					results[task.Name] = workflow.TaskResult{Phase: workflow.PhaseError}
+					fail(task.Name, ids[task.Name])
+					continue
+				case len(expanded) == 0:
+					ids[task.Name] = op.skipNode(taskName, task.Name, tgt.String(), dagID, workflow.PhaseSkipped, emptyLoopMessage, parents...)
+					results[task.Name] = workflow.TaskResult{Phase: workflow.PhaseSkipped}
+					continue
+				}
+
+				calls := make([]call, len(expanded))
+				for k := range expanded {
+					e := &expanded[k]
+					calls[k] = op.prepare(dagName+"."+e.Name, e.Name, target{spec: spec, name: e.Template, ref: e.TemplateRef}, e.When, e.Arguments, scope, argsPath).
+						withHooks(e.Hooks, "tasks", scope, path.Index(i).Child("hooks"))
+				}
+
+				running++
+				go func(task *workflow.DAGTask, name, templateName string, calls []call, parents []string) {
+					id := op.executeTaskGroup(ctx, name, task.Name, templateName, calls, dagID, parents...)
+					done <- taskDone{name: task.Name, id: id}
+				}(task, taskName, tgt.String(), calls, parents)
+				continue
+			}
+
+			c := op.prepare(taskName, task.Name, tgt, task.When, task.Arguments, scope, argsPath).
+				withHooks(task.Hooks, "tasks", scope, path.Index(i).Child("hooks"))
+			switch {
+			case c.err != nil:
+				ids[task.Name] = op.invoke(ctx, c, dagID, parents...)
+				results[task.Name] = workflow.TaskResult{Phase: workflow.PhaseError}
+				fail(task.Name, ids[task.Name])
+				continue
+			case c.skipped != "":
+				ids[task.Name] = op.invoke(ctx, c, dagID, parents...)
+				results[task.Name] = workflow.TaskResult{Phase: workflow.PhaseSkipped}
+				continue
+			}
+
+			running++
+			go func(name string, c call, parents []string) {
+				id := op.invoke(ctx, c, dagID, parents...)
+				done <- taskDone{name: name, id: id}
+			}(task.Name, c, parents)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+		node := op.node(d.id)
+		ids[d.name] = d.id
+		results[d.name] = op.taskResult(node)
+		scope.SetTaskOutputs(d.name, node.Outputs)
+		if node.PodIP != "" {
+			scope.SetTaskIP(d.name, node.PodIP)
+		}
+		if isFailure(node.Phase) {
+			fail(d.name, d.id)
+		}
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+		if started[task.Name] {
+			continue
+		}
+		parents := dependencyNodes(task, dagID, ids)
+		tgt := target{spec: spec, name: task.Template, ref: task.TemplateRef}
+		op.skipNode(dagName+"."+task.Name, task.Name, tgt.String(), dagID, workflow.PhaseOmitted, omittedMessage, parents...)
+	}
+
+	if failed != "" {
+		return workflow.PhaseFailed, fmt.Sprintf("child '%s' failed", failed)
+	}
+	if err := ctx.Err(); err != nil {
+		return workflow.PhaseFailed, err.Error()
+	}
+	return workflow.PhaseSucceeded, ""
+}
+
+// parseDepends parses the depends expressions of a DAG's tasks. It also
+// returns the tasks whose failure some expression handles.
+func parseDepends(tasks []workflow.DAGTask) (map[string]*workflow.Depends, map[string]bool, error) {
+	depends := make(map[string]*workflow.Depends)
+	handled := make(map[string]bool)
+	for i := range tasks {
+		if tasks[i].Depends == "" {
+			continue
+		}
+		d, err := workflow.ParseDepends(tasks[i].Depends)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid depends of task %q: %w", tasks[i].Name, err)
+		}
+		depends[tasks[i].Name] = d
+
+		for _, term := range d.Terms() {
+			switch term.Result {
+			case workflow.DependsFailed, workflow.DependsErrored, workflow.DependsAllFailed, workflow.DependsAnySucceeded:
+				handled[term.Task] = true
+			}
+		}
+	}
+	return depends, handled, nil
+}
+
+// taskResult describes a completed task node for depends expressions. The
+// children of a TaskGroup are its iterations, as dependents are only added
+// once it has completed.
+func (op *operation) taskResult(node workflow.Node) workflow.TaskResult {
+	result := workflow.TaskResult{Phase: node.Phase, Daemoned: node.Daemoned != nil && *node.Daemoned}
+	if node.Type == workflow.NodeTypeTaskGroup {
+		for _, child := range node.Children {
+			result.Children = append(result.Children, op.node(child).Phase)
+		}
+	}
+	return result
+}
+
+// dependenciesMet reports whether all of a task's dependencies have
+// completed, and if so whether they completed in a way that lets the task
+// run. Plain dependencies behave like "dep.Succeeded || dep.Skipped ||
+// dep.Daemoned"; a depends expression is evaluated as written.
+func dependenciesMet(task *workflow.DAGTask, depends *workflow.Depends, results map[string]workflow.TaskResult) (ready, satisfied bool) {
+	for _, dep := range task.AllDependencies() {
+		if _, ok := results[dep]; !ok {
+			return false, false
+		}
+	}
+
+	satisfied = true
+	for _, dep := range task.Dependencies {
+		r := results[dep]
+		if r.Phase != workflow.PhaseSucceeded && r.Phase != workflow.PhaseSkipped && !r.Daemoned {
+			satisfied = false
+		}
+	}
+	if depends != nil {
+		ok, err := depends.Eval(results)
+		satisfied = satisfied && ok && err == nil
+	}
+	return true, satisfied
+}
+
+// dependencyNodes returns the parent node IDs for a DAG task: the DAG node
+// itself for root tasks, otherwise the nodes of its dependencies.
+func dependencyNodes(task *workflow.DAGTask, dagID string, ids map[string]string) []string {
+	deps := task.AllDependencies()
+	if len(deps) == 0 {
+		return []string{dagID}
+	}
+
+	parents := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		if id, ok := ids[dep]; ok {
+			parents = append(parents, id)
+		}
+	}
+	return parents
+}
+
+const omittedMessage = "omitted: depends condition not met"
+
+// skipNode records a task or step that was not run, either because its
+// when condition was false (Skipped) or because its dependencies did not
+// allow it to run (Omitted).
+func (op *operation) skipNode(name, displayName, templateName, boundaryID, phase, message string, parents ...string) string {
+	id := op.initNode(name, displayName, workflow.NodeTypeSkipped, templateName, boundaryID, parents...)
+	op.markNode(id, phase, message, nil)
+	return id
+}
+
+// errorNode records a task or step that could not be started.
+func (op *operation) errorNode(name, displayName, templateName, boundaryID string, err error, parents ...string) string {
+	id := op.initNode(name, displayName, workflow.NodeTypePod, templateName, boundaryID, parents...)
+	op.markNode(id, workflow.PhaseError, err.Error(), nil)
+	return id
+}
+
+// call is a task or step invocation whose when condition, template and
+// arguments have been resolved.
+type call struct {
+	name        string
+	displayName string
+	template    string
+	spec        *workflow.WorkflowSpec // holds the calling template
+	tmpl        *workflow.Template
+	args        *workflow.Arguments
+	skipped     string // set when the when condition evaluated false
+	err         error
+
+	hooks     workflow.LifecycleHooks
+	hookScope workflow.Scope
+	hookPath  *field.Path
+	kind      string // "tasks" or "steps"
+}
+
+// withHooks attaches lifecycle hooks to a call. They are resolved against a
+// snapshot of scope to which the call's own outputs and status are added
+// once it completes.
+func (c call) withHooks(hooks workflow.LifecycleHooks, kind string, scope workflow.Scope, path *field.Path) call {
+	if len(hooks) == 0 {
+		return c
+	}
+	c.hooks = hooks
+	c.kind = kind
+	c.hookScope = scope.Clone()
+	c.hookPath = path
+	return c
+}
+
+// prepare resolves a task or step invocation against scope. It must run on
+// the scheduling goroutine, since scope is updated there as tasks complete.
+func (op *operation) prepare(name, displayName string, t target, when string, args *workflow.Arguments, scope workflow.Scope, path *field.Path) call {
+	c := call{name: name, displayName: displayName, template: t.String(), spec: t.spec}
+
+	run, cond, err := op.evaluateWhen(when, scope)
+	if err == nil && !run {
+		c.skipped = fmt.Sprintf("when '%s' evaluated false", cond)
+		return c
+	}
+	if err == nil {
+		c.tmpl, err = op.lookup(t)
+	}
+	if err == nil {
+		c.args, err = op.resolveArguments(args, scope, path)
+	}
+	c.err = err
+	return c
+}
+
+// invoke runs a prepared call to completion and returns its node ID.
+func (op *operation) invoke(ctx context.Context, c call, boundaryID string, parents ...string) string {
+	switch {
+	case c.err != nil:
+		return op.errorNode(c.name, c.displayName, c.template, boundaryID, c.err, parents...)
+	case c.skipped != "":
+		return op.skipNode(c.name, c.displayName, c.template, boundaryID, workflow.PhaseSkipped, c.skipped, parents...)
+	}
+	id := op.executeTemplate(ctx, c.name, c.displayName, c.tmpl, c.args, boundaryID, parents...)
+	if len(c.hooks) > 0 {
+		op.executeHooks(ctx, c, id, boundaryID)
+	}
+	return id
+}
+
+// executeHooks runs the lifecycle hooks of a completed call, in name order,
+// before the call is reported as complete. The exit hook always runs; any
+// other hook runs if its expression holds once the call has completed.
+// Hook nodes are children of the hooked node and do not affect its phase.
+func (op *operation) executeHooks(ctx context.Context, c call, id, boundaryID string) {
+	node := op.node(id)
+	scope := c.hookScope
+	scope.Set(c.kind+"."+c.displayName+".status", node.Phase)
+	if c.kind == "steps" {
+		scope.SetStepOutputs(c.displayName, node.Outputs)
+	} else {
+		scope.SetTaskOutputs(c.displayName, node.Outputs)
+	}
+	op.mu.Lock()
+	scope.SetWorkflowStatus(&op.wf.Status)
+	op.mu.Unlock()
+
+	names := make([]string, 0, len(c.hooks))
+	for name := range c.hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hook := c.hooks[name]
+		hookName, displayName := c.name+".onExit", c.displayName+".onExit"
+		if name != workflow.ExitHook {
+			hookName, displayName = c.name+".hooks."+name, c.displayName+".hooks."+name
+			run, err := evaluateHook(hook.Expression, scope)
+			if err != nil {
+				op.errorNode(hookName, displayName, hook.Template, boundaryID, err, id)
+				continue
+			}
+			if !run {
+				continue
+			}
+		}
+
+		hc := op.prepare(hookName, displayName, target{spec: c.spec, name: hook.Template}, "", hook.Arguments, scope, c.hookPath.Key(name).Child("arguments"))
+		op.invoke(context.WithoutCancel(ctx), hc, boundaryID, id)
+	}
+}
+
+// evaluateHook evaluates a hook expression with every scope variable
+// available by name, e.g. tasks.build.status == "Failed".
+func evaluateHook(expression string, scope workflow.Scope) (bool, error) {
+	vars := make(map[string]interface{}, len(scope))
+	for k, v := range scope {
+		vars[k] = v
+	}
+	run, err := expr.EvalBool(expression, vars)
+	if err != nil {
+		return false, fmt.Errorf("invalid hook expression %q: %w", expression, err)
+	}
+	return run, nil
+}
+
+// invokeAll runs calls in parallel and returns their node IDs in order.
+func (op *operation) invokeAll(ctx context.Context, calls []call, boundaryID string, parents ...string) []string {
+	ids := make([]string, len(calls))
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i] = op.invoke(ctx, calls[i], boundaryID, parents...)
+		}(i)
+	}
+	wg.Wait()
+	return ids
+}
+
+const emptyLoopMessage = "Skipped, empty params"
+
+// executeTaskGroup runs the expansions of a looped DAG task in parallel
+// under a TaskGroup node, as the controller does. The group fails if any
+// expansion fails, and carries the aggregated outputs of its children.
+func (op *operation) executeTaskGroup(ctx context.Context, name, displayName, templateName string, calls []call, boundaryID string, parents ...string) string {
+	id := op.initNode(name, displayName, workflow.NodeTypeTaskGroup, templateName, boundaryID, parents...)
+
+	phase, message := workflow.PhaseSucceeded, ""
+	children := op.invokeAll(ctx, calls, boundaryID, id)
+	outputs := make([]*workflow.Outputs, len(children))
+	for i, child := range children {
+		n := op.node(child)
+		outputs[i] = n.Outputs
+		if isFailure(n.Phase) && message == "" {
+			phase, message = workflow.PhaseFailed, fmt.Sprintf("child '%s' failed", child)
+		}
+	}
+
+	op.markNode(id, phase, message, workflow.AggregateOutputs(outputs))
+	return id
+}
+
+// evaluateWhen substitutes and evaluates a when condition. It returns the
+// substituted condition for use in node messages.
+func (op *operation) evaluateWhen(when string, scope workflow.Scope) (bool, string, error) {
+	if when == "" {
+		return true, "", nil
+	}
+
+	cond, missing := scope.Replace(when)
+	if len(missing) > 0 {
+		return false, cond, fmt.Errorf("unable to resolve when condition %q: unresolved reference {{%s}}", when, missing[0])
+	}
+
+	run, err := expr.EvalWhen(cond)
+	if err != nil {
+		return false, cond, fmt.Errorf("invalid when condition %q: %w", when, err)
+	}
+	return run, cond, nil
+}
+
+// resolveArguments substitutes task or step arguments from scope.
+func (op *operation) resolveArguments(args *workflow.Arguments, scope workflow.Scope, path *field.Path) (*workflow.Arguments, error) {
+	resolved, errs := op.resolver.ResolveArguments(args, scope, path)
+	if len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return resolved, nil
+}
+
+// executeSteps runs step groups in order, with the steps inside a group
+// running in parallel. A group only starts once every step of the previous
+// group has succeeded. Looped steps are expanded into one step per item
+// within their group.
+func (op *operation) executeSteps(ctx context.Context, stepsID, stepsName string, tmpl *workflow.Template, scope workflow.Scope) (string, string) {
+	prev := []string{stepsID}
+	path := op.resolver.TemplatePath(tmpl.Name).Child("steps")
+	spec := op.specOf(tmpl)
+
+	for i, group := range *tmpl.Steps {
+		groupName := fmt.Sprintf("%s[%d]", stepsName, i)
+		groupID := op.initNode(groupName, fmt.Sprintf("[%d]", i), workflow.NodeTypeStepGroup, "", stepsID, prev...)
+
+		// owners maps each call back to the step it was expanded from.
+		var calls []call
+		var owners []int
+		for j := range group {
+			step := &group[j]
+			stepName := groupName + "." + step.Name
+			argsPath := path.Index(i).Index(j).Child("arguments")
+			hooksPath := path.Index(i).Index(j).Child("hooks")
+			tgt := target{spec: spec, name: step.Template, ref: step.TemplateRef}
+
+			if !step.Looped() {
+				calls = append(calls, op.prepare(stepName, step.Name, tgt, step.When, step.Arguments, scope, argsPath).
+					withHooks(step.Hooks, "steps", scope, hooksPath))
+				owners = append(owners, j)
+				continue
+			}
+
+			expanded, err := step.Expand(scope)
+			switch {
+			case err != nil:
+				calls = append(calls, call{name: stepName, displayName: step.Name, template: tgt.String(), err: err})
+				owners = append(owners, j)
+			case len(expanded) == 0:
+				calls = append(calls, call{name: stepName, displayName: step.Name, template: tgt.String(), skipped: emptyLoopMessage})
+				owners = append(owners, j)
+			}
+			for k := range expanded {
+				e := &expanded[k]
+				calls = append(calls, op.prepare(groupName+"."+e.Name, e.Name, target{spec: spec, name: e.Template, ref: e.TemplateRef}, e.When, e.Arguments, scope, argsPath).
+					withHooks(e.Hooks, "steps", scope, hooksPath))
+				owners = append(owners, j)
+			}
+		}
+
+		ids := op.invokeAll(ctx, calls, stepsID, groupID)
+
+		outputs := make([][]*workflow.Outputs, len(group))
+		for k, id := range ids {
+			outputs[owners[k]] = append(outputs[owners[k]], op.node(id).Outputs)
+		}
+		for j := range group {
+			if group[j].Looped() {
+				scope.SetStepOutputs(group[j].Name, workflow.AggregateOutputs(outputs[j]))
+			} else {
+				scope.SetStepOutputs(group[j].Name, outputs[j][0])
+			}
+		}
+		for k, id := range ids {
+			if ip := op.node(id).PodIP; ip != "" && !group[owners[k]].Looped() {
+				scope.SetStepIP(group[owners[k]].Name, ip)
+			}
+		}
+
+		for _, id := range ids {
+			if isFailure(op.node(id).Phase) {
+				message := fmt.Sprintf("child '%s' failed", id)
+				op.markNode(groupID, workflow.PhaseFailed, message, nil)
+				return workflow.PhaseFailed, message
+			}
+		}
+		op.markNode(groupID, workflow.PhaseSucceeded, "", nil)
+
+		if err := ctx.Err(); err != nil {
+			return workflow.PhaseFailed, err.Error()
+		}
+		prev = ids
+	}
+
+	return workflow.PhaseSucceeded, ""
+}
+
+func isFailure(phase string) bool {
+	return phase == workflow.PhaseFailed || phase == workflow.PhaseError
+}
+
+// resolveInputs computes the value of every input parameter of a template
+// from the caller's arguments, falling back to the declared value or default.
+func resolveInputs(tmpl *workflow.Template, args *workflow.Arguments) (map[string]string, error) {
+	params := make(map[string]string)
+	if tmpl.Inputs == nil {
+		return params, nil
+	}
+
+	supplied := make(map[string]interface{})
+	if args != nil {
+		for _, p := range args.Parameters {
+			if p.Value != nil {
+				supplied[p.Name] = p.Value
+			}
+		}
+	}
+
+	for _, p := range tmpl.Inputs.Parameters {
+		switch {
+		case supplied[p.Name] != nil:
+			params[p.Name] = workflow.FormatValue(supplied[p.Name])
+		case p.Value != nil:
+			params[p.Name] = workflow.FormatValue(p.Value)
+		case p.Default != nil:
+			params[p.Name] = workflow.FormatValue(p.Default)
+		default:
+			return nil, fmt.Errorf("inputs.parameters.%s was not supplied", p.Name)
+		}
+	}
+
+	return params, nil
+}
+
+// resolveInputArtifacts computes the location of every input artifact of a
+// template from the caller's arguments, falling back to the location the
+// input declares. Optional artifacts that are not supplied are left out.
+func resolveInputArtifacts(tmpl *workflow.Template, args *workflow.Arguments) ([]workflow.Artifact, error) {
+	if tmpl.Inputs == nil {
+		return nil, nil
+	}
+
+	supplied := make(map[string]*workflow.Artifact)
+	if args != nil {
+		for i := range args.Artifacts {
+			if args.Artifacts[i].HasLocation() {
+				supplied[args.Artifacts[i].Name] = &args.Artifacts[i]
+			}
+		}
+	}
+
+	var artifacts []workflow.Artifact
+	for _, a := range tmpl.Inputs.Artifacts {
+		switch {
+		case supplied[a.Name] != nil:
+			a.SetLocation(supplied[a.Name])
+		case a.HasLocation():
+		case a.Optional:
+			continue
+		default:
+			return nil, fmt.Errorf("inputs.artifacts.%s was not supplied", a.Name)
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	return artifacts, nil
+}
+
+// collectOutputs builds the node outputs from a runner result.
+// Every output parameter declared by the template must be produced by the
+// runner or carry a default.
+func collectOutputs(tmpl *workflow.Template, res *Result) (*workflow.Outputs, error) {
+	var declared []workflow.Parameter
+	if tmpl.Outputs != nil {
+		declared = tmpl.Outputs.Parameters
+	}
+
+	exitCode := strconv.Itoa(res.ExitCode)
+	outputs := &workflow.Outputs{Result: res.Output, ExitCode: &exitCode}
+	for _, p := range declared {
+		value, ok := res.Parameters[p.Name]
+		switch {
+		case ok:
+		case p.Default != nil:
+			value = workflow.FormatValue(p.Default)
+		default:
+			return nil, fmt.Errorf("outputs.parameters.%s was not produced", p.Name)
+		}
+		outputs.Parameters = append(outputs.Parameters, workflow.Parameter{
+			Name:  p.Name,
+			Value: value,
+		})
+	}
+
+	return outputs, nil
+}
+
+// randomSuffix mimics the five character suffix the API server appends to
+// generateName.
+func randomSuffix() string {
+	const alphabet = "bcdfghjklmnpqrstvwxz2456789"
+	b := make([]byte, 5)
+	for i := range b {
+		b[i] = alphabet[rand.Intn(len(alphabet))]
+	}
+	return string(b)
+}
@@ -0,0 +1,252 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/vjranagit/argo-workflows/pkg/workflow"
+)
+
+// ProcessRunner runs leaf templates as local processes.
+// The container image is ignored: Container.Command and Args are executed
+// directly on the host, and Script.Source is written to a temporary file that
+// is passed as the last argument to Script.Command, the same way the Argo
+// executor invokes scripts inside their container. Only literal environment
+// values are supported.
+//
+// As in a pod, init containers run one after the other before the main
+// process, and sidecars run next to it until it exits. The containers of a
+// containerSet run one at a time in dependency order. Daemons are reported
+// ready as soon as their process has started, at the loopback address.
+//
+// Processes find the artifacts of their template below the directory in
+// $ARGO_ARTIFACT_DIR, at the artifacts' paths.
+type ProcessRunner struct {
+	// Dir is the working directory used when the template does not set one.
+	Dir string
+
+	// env is added to the environment of every process.
+	env []string
+}
+
+// NewProcessRunner creates a runner that executes templates on the host.
+func NewProcessRunner() *ProcessRunner {
+	return &ProcessRunner{}
+}
+
+// loopbackIP is the address local daemons are reached at.
+const loopbackIP = "127.0.0.1"
+
+// Run executes the task's template and captures its stdout as the result.
+func (r *ProcessRunner) Run(ctx context.Context, task *Task) (*Result, error) {
+	tmpl := task.Template
+	if tmpl.Container == nil && tmpl.Script == nil && tmpl.ContainerSet == nil {
+		return nil, fmt.Errorf("template %q is not a container, script or containerSet", tmpl.Name)
+	}
+	if task.ArtifactDir != "" {
+		local := *r
+		local.env = []string{"ARGO_ARTIFACT_DIR=" + task.ArtifactDir}
+		r = &local
+	}
+
+	for i := range tmpl.InitContainers {
+		c := &tmpl.InitContainers[i].Container
+		res, err := r.runContainer(ctx, c, nil)
+		if err != nil {
+			return nil, fmt.Errorf("init container %q: %w", c.Name, err)
+		}
+		if res.ExitCode != 0 {
+			res.Message = fmt.Sprintf("init container %q failed: %s", c.Name, res.failureMessage())
+			return res, nil
+		}
+	}
+
+	stop, err := r.startSidecars(ctx, tmpl.Sidecars)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	switch {
+	case tmpl.Container != nil:
+		if len(tmpl.Container.Command)+len(tmpl.Container.Args) == 0 {
+			return nil, fmt.Errorf("template %q has no command", tmpl.Name)
+		}
+		return r.runContainer(ctx, tmpl.Container, task.Ready)
+	case tmpl.Script != nil:
+		return r.runScript(ctx, tmpl, task.Ready)
+	default:
+		return r.runContainerSet(ctx, tmpl.ContainerSet, task.Ready)
+	}
+}
+
+func (r *ProcessRunner) runScript(ctx context.Context, tmpl *workflow.Template, ready func(string)) (*Result, error) {
+	f, err := os.CreateTemp("", "script-")
+	if err != nil {
+		return nil, fmt.Errorf("create script file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(tmpl.Script.Source); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write script file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("close script file: %w", err)
+	}
+
+	argv := append(append([]string(nil), tmpl.Script.Command...), f.Name())
+	return r.run(ctx, argv, envList(tmpl.Script.Env), tmpl.Script.WorkingDir, ready)
+}
+
+func (r *ProcessRunner) runContainer(ctx context.Context, c *workflow.Container, ready func(string)) (*Result, error) {
+	argv := append(append([]string(nil), c.Command...), c.Args...)
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("container %q has no command", c.Name)
+	}
+	return r.run(ctx, argv, envList(c.Env), c.WorkingDir, ready)
+}
+
+// runContainerSet runs the containers of a set in dependency order, stopping
+// at the first one that fails once its retries are used up. The result is
+// that of the container named "main", or else of the last one.
+func (r *ProcessRunner) runContainerSet(ctx context.Context, cs *workflow.ContainerSet, ready func(string)) (*Result, error) {
+	tasks := make([]workflow.DAGTask, len(cs.Containers))
+	byName := make(map[string]*workflow.Container, len(cs.Containers))
+	for i := range cs.Containers {
+		tasks[i] = workflow.DAGTask{Name: cs.Containers[i].Name, Dependencies: cs.Containers[i].Dependencies}
+		byName[cs.Containers[i].Name] = &cs.Containers[i].Container
+	}
+	order, err := workflow.NewDependencyGraph(tasks).TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	retries, delay := 0, time.Duration(0)
+	if rs := cs.RetryStrategy; rs != nil {
+		if rs.Retries != nil {
+			retries = rs.Retries.IntValue()
+		}
+		if rs.Duration != "" {
+			if delay, err = workflow.ParseDuration(rs.Duration); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var out *Result
+	for _, name := range order {
+		var res *Result
+		for attempt := 0; ; attempt++ {
+			if res, err = r.runContainer(ctx, byName[name], ready); err != nil {
+				return nil, err
+			}
+			ready = nil
+			if res.ExitCode == 0 || attempt >= retries {
+				break
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if res.ExitCode != 0 {
+			res.Message = fmt.Sprintf("container %q failed: %s", name, res.failureMessage())
+			return res, nil
+		}
+		if name == "main" || byName["main"] == nil {
+			out = res
+		}
+	}
+	return out, nil
+}
+
+// startSidecars starts the sidecars of a template and returns a function
+// that stops them and waits for them to exit. Their exit codes do not
+// matter, as for sidecars the controller kills.
+func (r *ProcessRunner) startSidecars(ctx context.Context, sidecars []workflow.UserContainer) (func(), error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var cmds []*exec.Cmd
+	stop := func() {
+		cancel()
+		for _, cmd := range cmds {
+			_ = cmd.Wait()
+		}
+	}
+
+	for i := range sidecars {
+		c := &sidecars[i].Container
+		argv := append(append([]string(nil), c.Command...), c.Args...)
+		if len(argv) == 0 {
+			stop()
+			return nil, fmt.Errorf("sidecar %q has no command", c.Name)
+		}
+		cmd := r.command(ctx, argv, envList(c.Env), c.WorkingDir)
+		if err := cmd.Start(); err != nil {
+			stop()
+			return nil, fmt.Errorf("start sidecar %q: %w", c.Name, err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return stop, nil
+}
+
+func (r *ProcessRunner) command(ctx context.Context, argv, env []string, dir string) *exec.Cmd {
+	if dir == "" {
+		dir = r.Dir
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), r.env...), env...)
+	return cmd
+}
+
+// run runs one process to completion, calling ready, if set, once it has
+// started.
+func (r *ProcessRunner) run(ctx context.Context, argv, env []string, dir string, ready func(string)) (*Result, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := r.command(ctx, argv, env, dir)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Start()
+	if err == nil {
+		if ready != nil {
+			ready(loopbackIP)
+		}
+		err = cmd.Wait()
+	}
+	res := &Result{Output: strings.TrimSpace(stdout.String())}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = exitErr.ExitCode()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			res.Message = fmt.Sprintf("Error (exit code %d): %s", res.ExitCode, msg)
+		}
+		return res, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run %s: %w", argv[0], err)
+	}
+
+	return res, nil
+}
+
+func envList(vars []workflow.EnvVar) []string {
+	env := make([]string, 0, len(vars))
+	for _, v := range vars {
+		if v.ValueFrom != nil {
+			continue
+		}
+		env = append(env, v.Name+"="+v.Value)
+	}
+	return env
+}
@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/vjranagit/argo-workflows/pkg/workflow"
+)
+
+// TaskRunner runs a single leaf template (Container, Script or
+// ContainerSet).
+// The executor owns scheduling and status bookkeeping; a runner only has to
+// turn one resolved template into a Result. Implementations must be safe for
+// concurrent use because independent DAG tasks and parallel steps run at once.
+type TaskRunner interface {
+	Run(ctx context.Context, task *Task) (*Result, error)
+}
+
+// RunnerFunc adapts an ordinary function to the TaskRunner interface.
+type RunnerFunc func(ctx context.Context, task *Task) (*Result, error)
+
+// Run calls f(ctx, task).
+func (f RunnerFunc) Run(ctx context.Context, task *Task) (*Result, error) {
+	return f(ctx, task)
+}
+
+// Task describes one leaf template invocation handed to a TaskRunner.
+type Task struct {
+	// NodeID is the ID of the Pod node recorded in WorkflowStatus.Nodes.
+	NodeID string
+	// NodeName is the fully qualified node name, e.g. "my-wf.build".
+	NodeName string
+	// Template is the template being invoked.
+	Template *workflow.Template
+	// Parameters holds the resolved input parameters keyed by name.
+	Parameters map[string]string
+	// ArtifactDir stands in for the pod's filesystem where artifacts are
+	// concerned: input artifacts are loaded to, and output artifacts saved
+	// from, their paths below it (see ArtifactPath). It is empty if the
+	// template has no artifacts.
+	ArtifactDir string
+	// Ready is set for daemon templates. The runner calls it once the
+	// daemon is ready to serve, with the IP dependents reach it at, and
+	// keeps running until ctx is cancelled.
+	Ready func(ip string)
+}
+
+// ArtifactPath returns where the file at path p in the pod is on the host,
+// for the paths of the template's artifacts.
+func (t *Task) ArtifactPath(p string) string {
+	return filepath.Join(t.ArtifactDir, filepath.FromSlash(p))
+}
+
+// Result is the outcome of running a Task.
+// A non-zero ExitCode marks the node Failed; returning an error from
+// TaskRunner.Run marks it Error, the same distinction the Argo controller
+// makes between a failing container and a pod that could not run.
+type Result struct {
+	ExitCode int
+	// Output becomes outputs.result, like a script template's stdout.
+	Output string
+	// Parameters holds output parameter values keyed by name.
+	Parameters map[string]string
+	// Message overrides the default node message on failure.
+	Message string
+}
+
+// failureMessage returns the node message for a non-zero exit.
+func (r *Result) failureMessage() string {
+	if r.Message != "" {
+		return r.Message
+	}
+	return fmt.Sprintf("Error (exit code %d)", r.ExitCode)
+}
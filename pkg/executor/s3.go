@@ -0,0 +1,446 @@
+package executor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vjranagit/argo-workflows/pkg/workflow"
+)
+
+// S3Driver is an ArtifactDriver for S3 and S3-compatible stores such as
+// MinIO. Objects are addressed path-style and requests are signed with
+// AWS Signature Version 4. The endpoint, bucket, region and insecure
+// setting of an artifact override the driver's; its credential secrets
+// are ignored, since the driver cannot read Kubernetes secrets.
+//
+// A directory saved with the none archive strategy becomes one object per
+// file under the artifact's key, and is loaded back the same way.
+type S3Driver struct {
+	// Endpoint is the host and port of the store, e.g. "localhost:9000".
+	Endpoint string
+	// Bucket is used for artifacts that do not name one.
+	Bucket string
+	// Region defaults to us-east-1.
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Insecure uses HTTP instead of HTTPS.
+	Insecure bool
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewS3Driver creates a driver for the store at endpoint, using the given
+// credentials. An empty access key sends anonymous requests.
+func NewS3Driver(endpoint, bucket, accessKey, secretKey string) *S3Driver {
+	return &S3Driver{
+		Endpoint:  endpoint,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+	}
+}
+
+// emptySHA256 is the hash of an empty payload.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Object is the address of an object: the artifact's settings layered
+// over the driver's.
+type s3Object struct {
+	endpoint string
+	bucket   string
+	key      string
+	region   string
+	insecure bool
+}
+
+func (d *S3Driver) object(a *workflow.Artifact) (s3Object, error) {
+	if a.S3 == nil {
+		return s3Object{}, fmt.Errorf("artifact %q is not an s3 artifact", a.Name)
+	}
+	o := s3Object{
+		endpoint: d.Endpoint,
+		bucket:   d.Bucket,
+		key:      strings.TrimSuffix(a.S3.Key, "/"),
+		region:   d.Region,
+		insecure: d.Insecure,
+	}
+	if a.S3.Endpoint != "" {
+		o.endpoint = a.S3.Endpoint
+	}
+	if a.S3.Bucket != "" {
+		o.bucket = a.S3.Bucket
+	}
+	if a.S3.Region != "" {
+		o.region = a.S3.Region
+	}
+	if a.S3.Insecure != nil {
+		o.insecure = *a.S3.Insecure
+	}
+	if o.region == "" {
+		o.region = "us-east-1"
+	}
+
+	switch {
+	case o.endpoint == "":
+		return s3Object{}, fmt.Errorf("artifact %q: s3 endpoint is required", a.Name)
+	case o.bucket == "":
+		return s3Object{}, fmt.Errorf("artifact %q: s3 bucket is required", a.Name)
+	case o.key == "":
+		return s3Object{}, fmt.Errorf("artifact %q: s3 key is required", a.Name)
+	}
+	return o, nil
+}
+
+// Load downloads the object at the artifact's key to path, or, if there is
+// none, every object below the key into the directory path.
+func (d *S3Driver) Load(ctx context.Context, a *workflow.Artifact, path string) error {
+	o, err := d.object(a)
+	if err != nil {
+		return err
+	}
+
+	err = d.download(ctx, o, o.key, path)
+	if !errors.Is(err, ErrArtifactNotFound) {
+		return err
+	}
+	keys, lerr := d.list(ctx, o, o.key+"/")
+	if lerr != nil {
+		return lerr
+	}
+	if len(keys) == 0 {
+		return err
+	}
+	root := filepath.Clean(path)
+	for _, key := range keys {
+		target := filepath.Join(path, filepath.FromSlash(strings.TrimPrefix(key, o.key+"/")))
+		if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return fmt.Errorf("load s3://%s/%s: invalid key %q", o.bucket, o.key, key)
+		}
+		if err := d.download(ctx, o, key, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save uploads the file at path to the artifact's key, or each file of the
+// directory path below it.
+func (d *S3Driver) Save(ctx context.Context, path string, a *workflow.Artifact) error {
+	o, err := d.object(a)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return d.upload(ctx, o, o.key, path)
+	}
+	return filepath.WalkDir(path, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		return d.upload(ctx, o, o.key+"/"+filepath.ToSlash(rel), p)
+	})
+}
+
+// List returns the artifact's key if the object exists, and the keys of
+// the objects below it.
+func (d *S3Driver) List(ctx context.Context, a *workflow.Artifact) ([]string, error) {
+	o, err := d.object(a)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := d.list(ctx, o, o.key)
+	if err != nil {
+		return nil, err
+	}
+	// The prefix also matches siblings such as key2.
+	var matched []string
+	for _, key := range keys {
+		if key == o.key || strings.HasPrefix(key, o.key+"/") {
+			matched = append(matched, key)
+		}
+	}
+	return matched, nil
+}
+
+// Delete removes the object at the artifact's key and the objects below
+// it.
+func (d *S3Driver) Delete(ctx context.Context, a *workflow.Artifact) error {
+	o, err := d.object(a)
+	if err != nil {
+		return err
+	}
+	keys, err := d.list(ctx, o, o.key+"/")
+	if err != nil {
+		return err
+	}
+	for _, key := range append(keys, o.key) {
+		resp, err := d.do(ctx, http.MethodDelete, o, key, nil, nil, emptySHA256)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+func (d *S3Driver) download(ctx context.Context, o s3Object, key, path string) error {
+	resp, err := d.do(ctx, http.MethodGet, o, key, nil, nil, emptySHA256)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("download s3://%s/%s: %w", o.bucket, key, err)
+	}
+	return f.Close()
+}
+
+func (d *S3Driver) upload(ctx context.Context, o s3Object, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The payload is hashed before it is sent, since it is signed.
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var body io.Reader = f
+	if size == 0 {
+		body = http.NoBody
+	}
+	resp, err := d.do(ctx, http.MethodPut, o, key, nil, &sizedReader{body, size}, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// sizedReader is a request body of known length.
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+// listBucketResult is the response to a ListObjectsV2 request.
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns the keys of the objects whose key starts with prefix.
+func (d *S3Driver) list(ctx context.Context, o s3Object, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := d.do(ctx, http.MethodGet, o, "", query, nil, emptySHA256)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list s3://%s/%s: %w", o.bucket, prefix, err)
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// s3Error is the body of an S3 error response.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do sends a signed request for key, or for the bucket if key is empty,
+// and returns the response if it succeeded.
+func (d *S3Driver) do(ctx context.Context, method string, o s3Object, key string, query url.Values, body *sizedReader, payloadHash string) (*http.Response, error) {
+	scheme := "https"
+	if o.insecure {
+		scheme = "http"
+	}
+	p := "/" + o.bucket
+	if key != "" {
+		p += "/" + key
+	}
+	u := &url.URL{
+		Scheme:   scheme,
+		Host:     o.endpoint,
+		Path:     p,
+		RawPath:  uriEncode(p, false),
+		RawQuery: canonicalQuery(query),
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = body.Reader
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = body.size
+	}
+	if d.AccessKey != "" {
+		signV4(req, payloadHash, o.region, d.AccessKey, d.SecretKey, time.Now())
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	target := fmt.Sprintf("s3://%s/%s", o.bucket, key)
+	if resp.StatusCode == http.StatusNotFound && method != http.MethodDelete && key != "" {
+		return nil, fmt.Errorf("%s %s: %w", method, target, ErrArtifactNotFound)
+	}
+	var e s3Error
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e); err != nil || e.Code == "" {
+		return nil, fmt.Errorf("%s %s: %s", method, target, resp.Status)
+	}
+	return nil, fmt.Errorf("%s %s: %s: %s", method, target, e.Code, e.Message)
+}
+
+// signV4 signs req for the S3 service with AWS Signature Version 4,
+// covering the host and every header already set on req.
+func signV4(req *http.Request, payloadHash, region, accessKey, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n" + req.URL.EscapedPath() + "\n" + req.URL.RawQuery + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + payloadHash)
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by name, as signing requires.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters
+// and, unless encodeSlash is set, "/".
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,394 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/vjranagit/argo-workflows/pkg/workflow"
+)
+
+// LockManager enforces the mutexes and semaphores of workflow and template
+// synchronization, in place of the controller's semaphore and mutex
+// managers. Locks are named the way the controller names them, e.g.
+// "default/Mutex/nightly" or "default/ConfigMap/limits/tables", and are
+// granted in the order they were requested. Executors that share a manager
+// also share its locks, so their workflows exclude one another.
+type LockManager struct {
+	mu     sync.Mutex
+	limits map[string]int
+	locks  map[string]*lockState
+}
+
+type lockState struct {
+	holders []string
+	queue   []*lockWaiter
+}
+
+type lockWaiter struct {
+	holder string
+	ready  chan struct{}
+}
+
+// NewLockManager creates a lock manager with no semaphore limits.
+func NewLockManager() *LockManager {
+	return &LockManager{
+		limits: make(map[string]int),
+		locks:  make(map[string]*lockState),
+	}
+}
+
+// SetSemaphoreLimit sets the limit of the semaphore stored under key in the
+// named ConfigMap, as if the ConfigMap held that value. Raising a limit
+// admits waiting holders at once; lowering it waits for current holders to
+// release. Semaphores without a limit cannot be acquired.
+func (m *LockManager) SetSemaphoreLimit(namespace, configMap, key string, limit int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := semaphoreLockName(namespace, configMap, key)
+	m.limits[name] = limit
+	m.grant(name)
+}
+
+// Holders returns the current holders of a lock.
+func (m *LockManager) Holders(name string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.locks[name]; ok {
+		return slices.Clone(l.holders)
+	}
+	return nil
+}
+
+// Waiting returns the holders waiting for a lock, in the order they will be
+// admitted.
+func (m *LockManager) Waiting(name string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var waiting []string
+	if l, ok := m.locks[name]; ok {
+		for _, w := range l.queue {
+			waiting = append(waiting, w.holder)
+		}
+	}
+	return waiting
+}
+
+// acquire blocks until holder holds the lock or ctx is done. If it has to
+// wait, wait is called first with the current holders.
+func (m *LockManager) acquire(ctx context.Context, name string, semaphore bool, holder string, wait func(holders []string)) error {
+	m.mu.Lock()
+	if _, ok := m.limits[name]; semaphore && !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("semaphore %q has no limit", name)
+	}
+	l := m.lock(name)
+	if len(l.queue) == 0 && len(l.holders) < m.limit(name) {
+		l.holders = append(l.holders, holder)
+		m.mu.Unlock()
+		return nil
+	}
+	w := &lockWaiter{holder: holder, ready: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	holders := slices.Clone(l.holders)
+	m.mu.Unlock()
+
+	wait(holders)
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-w.ready:
+		// Granted while giving up; pass the lock on.
+		m.remove(name, holder)
+	default:
+		l.queue = slices.DeleteFunc(l.queue, func(q *lockWaiter) bool { return q == w })
+	}
+	return ctx.Err()
+}
+
+// release gives up a lock held by holder.
+func (m *LockManager) release(name, holder string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(name, holder)
+}
+
+func (m *LockManager) remove(name, holder string) {
+	l := m.lock(name)
+	if i := slices.Index(l.holders, holder); i >= 0 {
+		l.holders = slices.Delete(l.holders, i, i+1)
+	}
+	m.grant(name)
+}
+
+// grant admits waiting holders while the lock has room.
+func (m *LockManager) grant(name string) {
+	l := m.lock(name)
+	for len(l.queue) > 0 && len(l.holders) < m.limit(name) {
+		w := l.queue[0]
+		l.queue = l.queue[1:]
+		l.holders = append(l.holders, w.holder)
+		close(w.ready)
+	}
+}
+
+func (m *LockManager) lock(name string) *lockState {
+	l, ok := m.locks[name]
+	if !ok {
+		l = &lockState{}
+		m.locks[name] = l
+	}
+	return l
+}
+
+// limit returns how many holders a lock admits: one for a mutex.
+func (m *LockManager) limit(name string) int {
+	if n, ok := m.limits[name]; ok {
+		return n
+	}
+	return 1
+}
+
+func mutexLockName(namespace, name string) string {
+	return namespace + "/Mutex/" + name
+}
+
+func semaphoreLockName(namespace, configMap, key string) string {
+	return namespace + "/ConfigMap/" + configMap + "/" + key
+}
+
+// syncLock is one lock of a Synchronization.
+type syncLock struct {
+	name      string
+	semaphore bool
+}
+
+// locks returns the locks of s sorted by name, so that every holder takes
+// them in the same order.
+func (op *operation) locks(s *workflow.Synchronization) []syncLock {
+	namespace := func(ns string) string {
+		if ns != "" {
+			return ns
+		}
+		return op.namespace()
+	}
+
+	var locks []syncLock
+	if m := s.Mutex; m != nil {
+		locks = append(locks, syncLock{name: mutexLockName(namespace(m.Namespace), m.Name)})
+	}
+	if sem := s.Semaphore; sem != nil && sem.ConfigMapKeyRef != nil {
+		ref := sem.ConfigMapKeyRef
+		locks = append(locks, syncLock{name: semaphoreLockName(namespace(sem.Namespace), ref.Name, ref.Key), semaphore: true})
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].name < locks[j].name })
+	return locks
+}
+
+// namespace returns the workflow's namespace, defaulting to "default" like
+// kubectl.
+func (op *operation) namespace() string {
+	if op.wf.Namespace != "" {
+		return op.wf.Namespace
+	}
+	return "default"
+}
+
+// acquireLocks takes the locks of s for the workflow, or for the node id if
+// it is set, and returns a function that releases them. While waiting, the
+// node, or the workflow, is Pending and the lock is listed in the
+// workflow's synchronization status.
+func (op *operation) acquireLocks(ctx context.Context, s *workflow.Synchronization, id string) (func(), error) {
+	holder, key := op.wf.Name, op.namespace()+"/"+op.wf.Name
+	if id != "" {
+		holder, key = id, key+"/"+id
+	}
+
+	var held []syncLock
+	release := func() {
+		for _, l := range held {
+			op.exec.locks.release(l.name, key)
+			op.updateSyncStatus(func(st *workflow.SynchronizationStatus) { removeHolding(st, l, holder) })
+		}
+	}
+
+	for _, l := range op.locks(s) {
+		waited := false
+		err := op.exec.locks.acquire(ctx, l.name, l.semaphore, key, func(holders []string) {
+			waited = true
+			op.setWaiting(id, l, holders)
+		})
+		if waited {
+			op.updateSyncStatus(func(st *workflow.SynchronizationStatus) { removeWaiting(st, l) })
+		}
+		if err != nil {
+			release()
+			return nil, err
+		}
+		held = append(held, l)
+		op.setHolding(id, l, holder)
+	}
+	return release, nil
+}
+
+// lockErrorPhase returns the phase of a node, or workflow, that could not
+// take its locks: Failed if it gave up waiting, like a pod that exceeded
+// its deadline, and Error if the lock could not be taken at all.
+func lockErrorPhase(ctx context.Context) string {
+	if ctx.Err() != nil {
+		return workflow.PhaseFailed
+	}
+	return workflow.PhaseError
+}
+
+// setWaiting marks the node, or the workflow, as waiting for a lock.
+func (op *operation) setWaiting(id string, l syncLock, holders []string) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	defer op.pruneSyncStatus()
+
+	message := fmt.Sprintf("Waiting for %s lock", l.name)
+	if id == "" {
+		op.wf.Status.Phase = workflow.PhasePending
+		op.wf.Status.Message = message
+	} else {
+		n := op.wf.Status.Nodes[id]
+		n.Phase = workflow.PhasePending
+		n.Message = message
+		n.SynchronizationStatus = &workflow.NodeSynchronizationStatus{Waiting: l.name}
+		op.wf.Status.Nodes[id] = n
+	}
+
+	st := op.syncStatus()
+	if l.semaphore {
+		st.Semaphore.Waiting = append(st.Semaphore.Waiting, workflow.SemaphoreHolding{Semaphore: l.name, Holders: holders})
+	} else {
+		var current string
+		if len(holders) > 0 {
+			current = holders[0]
+		}
+		st.Mutex.Waiting = append(st.Mutex.Waiting, workflow.MutexHolding{Mutex: l.name, Holder: current})
+	}
+}
+
+// setHolding records that holder took a lock and moves a waiting node, or
+// workflow, back to Running.
+func (op *operation) setHolding(id string, l syncLock, holder string) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	defer op.pruneSyncStatus()
+
+	if id == "" {
+		if op.wf.Status.Phase == workflow.PhasePending {
+			op.wf.Status.Phase = workflow.PhaseRunning
+			op.wf.Status.Message = ""
+		}
+	} else if n := op.wf.Status.Nodes[id]; n.SynchronizationStatus != nil {
+		n.Phase = workflow.PhaseRunning
+		n.Message = ""
+		n.SynchronizationStatus = nil
+		op.wf.Status.Nodes[id] = n
+	}
+
+	st := op.syncStatus()
+	if !l.semaphore {
+		st.Mutex.Holding = append(st.Mutex.Holding, workflow.MutexHolding{Mutex: l.name, Holder: holder})
+		return
+	}
+	for i := range st.Semaphore.Holding {
+		if h := &st.Semaphore.Holding[i]; h.Semaphore == l.name {
+			h.Holders = append(h.Holders, holder)
+			return
+		}
+	}
+	st.Semaphore.Holding = append(st.Semaphore.Holding, workflow.SemaphoreHolding{Semaphore: l.name, Holders: []string{holder}})
+}
+
+// updateSyncStatus applies f to the workflow's synchronization status.
+func (op *operation) updateSyncStatus(f func(st *workflow.SynchronizationStatus)) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	defer op.pruneSyncStatus()
+
+	f(op.syncStatus())
+}
+
+// pruneSyncStatus drops the parts of the synchronization status that list
+// nothing. op.mu must be held.
+func (op *operation) pruneSyncStatus() {
+	st := op.wf.Status.Synchronization
+	if st == nil {
+		return
+	}
+	if st.Mutex != nil && len(st.Mutex.Holding)+len(st.Mutex.Waiting) == 0 {
+		st.Mutex = nil
+	}
+	if st.Semaphore != nil && len(st.Semaphore.Holding)+len(st.Semaphore.Waiting) == 0 {
+		st.Semaphore = nil
+	}
+	if st.Mutex == nil && st.Semaphore == nil {
+		op.wf.Status.Synchronization = nil
+	}
+}
+
+// syncStatus returns the workflow's synchronization status with both parts
+// allocated. op.mu must be held.
+func (op *operation) syncStatus() *workflow.SynchronizationStatus {
+	st := op.wf.Status.Synchronization
+	if st == nil {
+		st = &workflow.SynchronizationStatus{}
+		op.wf.Status.Synchronization = st
+	}
+	if st.Mutex == nil {
+		st.Mutex = &workflow.MutexStatus{}
+	}
+	if st.Semaphore == nil {
+		st.Semaphore = &workflow.SemaphoreStatus{}
+	}
+	return st
+}
+
+func removeWaiting(st *workflow.SynchronizationStatus, l syncLock) {
+	if l.semaphore {
+		if i := slices.IndexFunc(st.Semaphore.Waiting, func(h workflow.SemaphoreHolding) bool { return h.Semaphore == l.name }); i >= 0 {
+			st.Semaphore.Waiting = slices.Delete(st.Semaphore.Waiting, i, i+1)
+		}
+		return
+	}
+	if i := slices.IndexFunc(st.Mutex.Waiting, func(h workflow.MutexHolding) bool { return h.Mutex == l.name }); i >= 0 {
+		st.Mutex.Waiting = slices.Delete(st.Mutex.Waiting, i, i+1)
+	}
+}
+
+func removeHolding(st *workflow.SynchronizationStatus, l syncLock, holder string) {
+	if !l.semaphore {
+		st.Mutex.Holding = slices.DeleteFunc(st.Mutex.Holding, func(h workflow.MutexHolding) bool {
+			return h.Mutex == l.name && h.Holder == holder
+		})
+		return
+	}
+	for i := range st.Semaphore.Holding {
+		h := &st.Semaphore.Holding[i]
+		if h.Semaphore != l.name {
+			continue
+		}
+		h.Holders = slices.DeleteFunc(h.Holders, func(s string) bool { return s == holder })
+		if len(h.Holders) == 0 {
+			st.Semaphore.Holding = slices.Delete(st.Semaphore.Holding, i, i+1)
+		}
+		return
+	}
+}
@@ -0,0 +1,450 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// env carries the variables and evaluation mode for a single Eval call.
+type env struct {
+	vars map[string]interface{}
+	// bareWords makes unknown identifiers evaluate to their own name, the
+	// way Argo evaluates substituted "when" conditions such as
+	// "heads == tails".
+	bareWords bool
+}
+
+// lookup resolves a dotted variable name, first as a flat key and then by
+// walking nested maps.
+func (e *env) lookup(name string) (interface{}, bool) {
+	if v, ok := e.vars[name]; ok {
+		return v, true
+	}
+
+	parts := strings.Split(name, ".")
+	var cur interface{} = e.vars
+	for _, part := range parts {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// node is an element of the expression tree.
+type node interface {
+	eval(e *env) (interface{}, error)
+	position() int
+}
+
+// EvalError reports a failure to evaluate a well-formed expression, such as
+// an unknown variable or a type mismatch.
+type EvalError struct {
+	Pos int
+	Msg string
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos+1, e.Msg)
+}
+
+func evalErrorf(n node, format string, args ...interface{}) error {
+	return &EvalError{Pos: n.position(), Msg: fmt.Sprintf(format, args...)}
+}
+
+type literalNode struct {
+	pos   int
+	value interface{}
+}
+
+func (n *literalNode) position() int { return n.pos }
+
+func (n *literalNode) eval(e *env) (interface{}, error) {
+	return n.value, nil
+}
+
+type identNode struct {
+	pos  int
+	name string
+}
+
+func (n *identNode) position() int { return n.pos }
+
+func (n *identNode) eval(e *env) (interface{}, error) {
+	if v, ok := e.lookup(n.name); ok {
+		return normalize(v), nil
+	}
+	if e.bareWords {
+		return n.name, nil
+	}
+	return nil, evalErrorf(n, "unknown variable %q", n.name)
+}
+
+type listNode struct {
+	pos   int
+	items []node
+}
+
+func (n *listNode) position() int { return n.pos }
+
+func (n *listNode) eval(e *env) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+type ternaryNode struct {
+	pos             int
+	cond, then, els node
+}
+
+func (n *ternaryNode) position() int { return n.pos }
+
+func (n *ternaryNode) eval(e *env) (interface{}, error) {
+	c, err := evalBool(n.cond, e)
+	if err != nil {
+		return nil, err
+	}
+	if c {
+		return n.then.eval(e)
+	}
+	return n.els.eval(e)
+}
+
+type unaryNode struct {
+	pos     int
+	op      string
+	operand node
+}
+
+func (n *unaryNode) position() int { return n.pos }
+
+func (n *unaryNode) eval(e *env) (interface{}, error) {
+	switch n.op {
+	case "!":
+		b, err := evalBool(n.operand, e)
+		if err != nil {
+			return nil, err
+		}
+		return !b, nil
+	default:
+		v, err := n.operand.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		f, ok := toNumber(v)
+		if !ok {
+			return nil, evalErrorf(n, "cannot negate %s", describe(v))
+		}
+		return -f, nil
+	}
+}
+
+type binaryNode struct {
+	pos         int
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) position() int { return n.pos }
+
+func (n *binaryNode) eval(e *env) (interface{}, error) {
+	// Logical operators short-circuit.
+	switch n.op {
+	case "&&", "||":
+		l, err := evalBool(n.left, e)
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "&&" && !l) || (n.op == "||" && l) {
+			return l, nil
+		}
+		return evalBool(n.right, e)
+	}
+
+	l, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "<", "<=", ">", ">=":
+		return n.compare(l, r)
+	case "=~", "!~":
+		re, err := regexp.Compile(toString(r))
+		if err != nil {
+			return nil, evalErrorf(n, "invalid regular expression: %v", err)
+		}
+		return re.MatchString(toString(l)) == (n.op == "=~"), nil
+	case "contains":
+		return strings.Contains(toString(l), toString(r)), nil
+	case "startsWith":
+		return strings.HasPrefix(toString(l), toString(r)), nil
+	case "endsWith":
+		return strings.HasSuffix(toString(l), toString(r)), nil
+	case "in":
+		for _, item := range r.([]interface{}) {
+			if equal(l, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "+":
+		lf, lok := toNumber(l)
+		rf, rok := toNumber(r)
+		if lok && rok {
+			return lf + rf, nil
+		}
+		return toString(l) + toString(r), nil
+	default:
+		return n.arithmetic(l, r)
+	}
+}
+
+func (n *binaryNode) compare(l, r interface{}) (interface{}, error) {
+	if lf, ok := toNumber(l); ok {
+		if rf, ok := toNumber(r); ok {
+			return compareOrdered(n.op, lf, rf), nil
+		}
+	}
+
+	ls, lok := l.(string)
+	rs, rok := r.(string)
+	if lok && rok {
+		return compareOrdered(n.op, ls, rs), nil
+	}
+
+	return nil, evalErrorf(n, "cannot compare %s and %s with %q", describe(l), describe(r), n.op)
+}
+
+func (n *binaryNode) arithmetic(l, r interface{}) (interface{}, error) {
+	lf, lok := toNumber(l)
+	rf, rok := toNumber(r)
+	if !lok || !rok {
+		return nil, evalErrorf(n, "cannot apply %q to %s and %s", n.op, describe(l), describe(r))
+	}
+
+	switch n.op {
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, evalErrorf(n, "division by zero")
+		}
+		return lf / rf, nil
+	default:
+		if rf == 0 {
+			return nil, evalErrorf(n, "division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+}
+
+type callNode struct {
+	pos  int
+	name string
+	fn   function
+	args []node
+}
+
+func (n *callNode) position() int { return n.pos }
+
+func (n *callNode) eval(e *env) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	v, err := n.fn.call(args)
+	if err != nil {
+		return nil, evalErrorf(n, "%s: %v", n.name, err)
+	}
+	return v, nil
+}
+
+// function is a built-in callable.
+type function struct {
+	arity int // -1 for variadic
+	call  func(args []interface{}) (interface{}, error)
+}
+
+// functions are the built-ins available to expressions. asInt and asFloat
+// mirror the helpers Argo exposes to retry expressions.
+var functions = map[string]function{
+	"asInt":   {arity: 1, call: asInt},
+	"int":     {arity: 1, call: asInt},
+	"asFloat": {arity: 1, call: asFloat},
+	"float":   {arity: 1, call: asFloat},
+	"string":  {arity: 1, call: func(args []interface{}) (interface{}, error) { return toString(args[0]), nil }},
+	"len": {arity: 1, call: func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			return float64(len(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("cannot take length of %s", describe(args[0]))
+	}},
+}
+
+func asInt(args []interface{}) (interface{}, error) {
+	f, ok := toNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %s to int", describe(args[0]))
+	}
+	return math.Trunc(f), nil
+}
+
+func asFloat(args []interface{}) (interface{}, error) {
+	f, ok := toNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %s to float", describe(args[0]))
+	}
+	return f, nil
+}
+
+func evalBool(n node, e *env) (bool, error) {
+	v, err := n.eval(e)
+	if err != nil {
+		return false, err
+	}
+	b, ok := toBool(v)
+	if !ok {
+		return false, evalErrorf(n, "expected boolean, got %s", describe(v))
+	}
+	return b, nil
+}
+
+// normalize converts Go numeric types from the variable map to float64, the
+// single numeric type used during evaluation.
+func normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case []string:
+		out := make([]interface{}, len(n))
+		for i, s := range n {
+			out[i] = s
+		}
+		return out
+	}
+	return v
+}
+
+// toNumber coerces numbers and numeric strings to float64.
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toBool coerces booleans and the strings "true" and "false". Other
+// spellings such as "1" or "t" are not booleans.
+func toBool(v interface{}) (bool, bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		return b == "true", b == "true" || b == "false"
+	}
+	return false, false
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// equal compares two values, numerically when both sides look like numbers
+// and as booleans when both sides look like booleans, so that "1" == 1 and
+// "true" == true hold as they do after Argo's string substitution.
+func equal(l, r interface{}) bool {
+	if lf, ok := toNumber(l); ok {
+		if rf, ok := toNumber(r); ok {
+			return lf == rf
+		}
+	}
+	if lb, ok := toBool(l); ok {
+		if rb, ok := toBool(r); ok {
+			return lb == rb
+		}
+	}
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	return toString(l) == toString(r)
+}
+
+func compareOrdered[T float64 | string](op string, l, r T) bool {
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+func describe(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return "number"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,146 @@
+// Package expr parses and evaluates the condition expressions used in Argo
+// workflows: DAGTask.When, StepGroup.When and RetryStrategy.Expression.
+//
+// The grammar accepts both the govaluate-style syntax Argo uses for "when"
+// conditions (&&, ||, !, =~, !~) and the expr-style syntax used for retry
+// expressions and HTTP success conditions (and, or, not, matches, in [...],
+// contains, startsWith, endsWith, asInt(...)). Values are
+// strings, numbers, booleans and lists; strings that look like numbers or
+// booleans are coerced when compared against them, since every value in a
+// substituted template starts out as a string.
+package expr
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SyntaxError reports a malformed expression.
+// Pos is the zero-based byte offset of the offending token.
+type SyntaxError struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// Expression is a parsed expression ready for evaluation.
+type Expression struct {
+	src  string
+	root node
+}
+
+// Parse parses an expression.
+// The returned error is a *SyntaxError pointing at the offending token.
+func Parse(src string) (*Expression, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: src, tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, p.errorf(p.peek(), "empty expression")
+	}
+
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t)
+	}
+
+	return &Expression{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.src
+}
+
+// Variables returns the sorted, de-duplicated names of the variables the
+// expression refers to.
+func (e *Expression) Variables() []string {
+	seen := make(map[string]bool)
+	walk(e.root, func(n node) {
+		if id, ok := n.(*identNode); ok {
+			seen[id.name] = true
+		}
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Eval evaluates the expression against vars. Keys may be dotted names such
+// as "lastRetry.exitCode" or nested maps. Referring to a variable that is
+// not in vars is an error.
+func (e *Expression) Eval(vars map[string]interface{}) (interface{}, error) {
+	return e.root.eval(&env{vars: vars})
+}
+
+// EvalBool evaluates the expression and requires a boolean result.
+func (e *Expression) EvalBool(vars map[string]interface{}) (bool, error) {
+	return evalBool(e.root, &env{vars: vars})
+}
+
+// Eval parses and evaluates src against vars.
+func Eval(src string, vars map[string]interface{}) (interface{}, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(vars)
+}
+
+// EvalBool parses and evaluates src against vars, requiring a boolean.
+func EvalBool(src string, vars map[string]interface{}) (bool, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return false, err
+	}
+	return e.EvalBool(vars)
+}
+
+// EvalWhen evaluates a "when" condition whose template placeholders have
+// already been substituted. As in Argo, bare words that are not variables
+// are treated as strings, so "heads == tails" compares two strings.
+func EvalWhen(when string) (bool, error) {
+	e, err := Parse(when)
+	if err != nil {
+		return false, err
+	}
+	return evalBool(e.root, &env{bareWords: true})
+}
+
+// walk visits every node of the tree in depth-first order.
+func walk(n node, fn func(node)) {
+	fn(n)
+	switch v := n.(type) {
+	case *listNode:
+		for _, item := range v.items {
+			walk(item, fn)
+		}
+	case *ternaryNode:
+		walk(v.cond, fn)
+		walk(v.then, fn)
+		walk(v.els, fn)
+	case *unaryNode:
+		walk(v.operand, fn)
+	case *binaryNode:
+		walk(v.left, fn)
+		walk(v.right, fn)
+	case *callNode:
+		for _, a := range v.args {
+			walk(a, fn)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEvalBool(t *testing.T) {
+	vars := map[string]interface{}{
+		"lastRetry.exitCode": 2,
+		"lastRetry.status":   "Failed",
+		"lastRetry.duration": "45",
+		"inputs": map[string]interface{}{
+			"parameters": map[string]interface{}{"env": "prod"},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`lastRetry.exitCode > 1`, true},
+		{`asInt(lastRetry.exitCode) == 2`, true},
+		{`lastRetry.status == "Failed" && lastRetry.exitCode != 0`, true},
+		{`lastRetry.status == 'Error' or lastRetry.exitCode == 2`, true},
+		{`not (lastRetry.status == "Failed")`, false},
+		{`lastRetry.duration < 60`, true},
+		{`lastRetry.status =~ "^Fail"`, true},
+		{`lastRetry.status matches "^Err"`, false},
+		{`lastRetry.status !~ "Err"`, true},
+		{`lastRetry.status in ["Failed", "Error"]`, true},
+		{`lastRetry.status not in ("Failed", "Error")`, false},
+		{`inputs.parameters.env == "prod"`, true},
+		{`1 + 2 * 3 == 7`, true},
+		{`(1 + 2) * 3 == 9`, true},
+		{`10 % 4 == 2 && -1 < 0`, true},
+		{`"1" == 1`, true},
+		{`"true" == true`, true},
+		{`"false" == false`, true},
+		{`"1" == "true"`, false},
+		{`"t" == "true"`, false},
+		{`"0" == "false"`, false},
+		{`"1" == true`, false},
+		{`lastRetry.exitCode > 5 ? false : true`, true},
+		{`len("abc") == 3`, true},
+		{`lastRetry.status contains "ail"`, true},
+		{`lastRetry.status startsWith "Fail" and lastRetry.status endsWith "ed"`, true},
+		{`lastRetry.status not contains "Err"`, true},
+		{`lastRetry.status not startsWith "F"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := EvalBool(tt.expr, vars)
+			if err != nil {
+				t.Fatalf("EvalBool() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EvalBool() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalWhen(t *testing.T) {
+	tests := []struct {
+		when string
+		want bool
+	}{
+		{`heads == heads`, true},
+		{`heads == tails`, false},
+		{`"heads" != tails`, true},
+		{`5 > 3`, true},
+		{`hello =~ "^h"`, true},
+		{`true`, true},
+		{`Succeeded == Succeeded && 3 < 2`, false},
+		{`1 == true`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			got, err := EvalWhen(tt.when)
+			if err != nil {
+				t.Fatalf("EvalWhen() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EvalWhen() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+	}{
+		{`a == `, 5},
+		{`(a == b`, 7},
+		{`a == b)`, 6},
+		{`a # b`, 2},
+		{`"open`, 0},
+		{`nope(1)`, 0},
+		{``, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("Parse() error = %v, want *SyntaxError", err)
+			}
+			if se.Pos != tt.pos {
+				t.Errorf("Pos = %d, want %d (%v)", se.Pos, tt.pos, err)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []string{
+		`missing == 1`,
+		`"abc" > 1`,
+		`1 / 0 == 1`,
+		`"yes" && true`,
+		`"1" && true`,
+		`"a" =~ "("`,
+	}
+
+	for _, src := range tests {
+		t.Run(src, func(t *testing.T) {
+			_, err := EvalBool(src, nil)
+			var ee *EvalError
+			if !errors.As(err, &ee) {
+				t.Fatalf("EvalBool() error = %v, want *EvalError", err)
+			}
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	e, err := Parse(`asInt(lastRetry.exitCode) > 1 && lastRetry.status != "Error" || lastRetry.exitCode == 9`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got := strings.Join(e.Variables(), ",")
+	if got != "lastRetry.exitCode,lastRetry.status" {
+		t.Errorf("Variables() = %v", got)
+	}
+}
@@ -0,0 +1,181 @@
+package expr
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenKind classifies lexical tokens.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOperator
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokQuestion
+	tokColon
+)
+
+// token is a single lexical token with its byte offset in the source.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators lists the symbolic operators, longest first so that the lexer
+// prefers "<=" over "<".
+var operators = []string{
+	"&&", "||", "==", "!=", "<=", ">=", "=~", "!~",
+	"<", ">", "+", "-", "*", "/", "%", "!",
+}
+
+// wordOperators are the expr-style keyword spellings of operators.
+var wordOperators = map[string]string{
+	"and":        "&&",
+	"or":         "||",
+	"not":        "!",
+	"matches":    "=~",
+	"in":         "in",
+	"contains":   "contains",
+	"startsWith": "startsWith",
+	"endsWith":   "endsWith",
+}
+
+// lex splits src into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+
+	for i < len(src) {
+		c := rune(src[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '\'' || c == '"':
+			s, n, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: s, pos: i})
+			i += n
+
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(rune(src[i+1]))):
+			start := i
+			for i < len(src) && (isDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && isDigit(rune(src[i])) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], pos: start})
+
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentPart(rune(src[i])) {
+				i++
+			}
+			word := src[start:i]
+			if op, ok := wordOperators[word]; ok {
+				tokens = append(tokens, token{kind: tokOperator, text: op, pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: start})
+			}
+
+		default:
+			kind, text := tokEOF, ""
+			switch c {
+			case '(':
+				kind, text = tokLParen, "("
+			case ')':
+				kind, text = tokRParen, ")"
+			case '[':
+				kind, text = tokLBracket, "["
+			case ']':
+				kind, text = tokRBracket, "]"
+			case ',':
+				kind, text = tokComma, ","
+			case '?':
+				kind, text = tokQuestion, "?"
+			case ':':
+				kind, text = tokColon, ":"
+			default:
+				for _, op := range operators {
+					if strings.HasPrefix(src[i:], op) {
+						kind, text = tokOperator, op
+						break
+					}
+				}
+			}
+			if text == "" {
+				return nil, &SyntaxError{Expr: src, Pos: i, Msg: "unexpected character " + quoteRune(c)}
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: i})
+			i += len(text)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString scans a quoted string starting at src[start] and returns its
+// unescaped value and the number of bytes consumed.
+func lexString(src string, start int) (string, int, error) {
+	quote := src[start]
+	var b strings.Builder
+
+	for i := start + 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(src[i])
+			}
+		case c == quote:
+			return b.String(), i - start + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", 0, &SyntaxError{Expr: src, Pos: start, Msg: "unterminated string"}
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+// isIdentPart allows dots so that dotted variable names such as
+// lastRetry.exitCode lex as a single identifier.
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.'
+}
+
+func quoteRune(c rune) string {
+	return "'" + string(c) + "'"
+}
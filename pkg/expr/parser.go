@@ -0,0 +1,333 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// parser is a recursive descent parser over the token stream.
+//
+// Precedence, lowest first:
+//
+//	?:
+//	|| or
+//	&& and
+//	== != < <= > >= =~ !~ matches in contains startsWith endsWith
+//	+ -
+//	* / %
+//	! not - (unary)
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &SyntaxError{Expr: p.src, Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokEOF {
+		return p.errorf(t, "unexpected end of expression")
+	}
+	return p.errorf(t, "unexpected %q", t.text)
+}
+
+func (p *parser) expect(kind tokenKind, text string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		if t.kind == tokEOF {
+			return t, p.errorf(t, "expected %q, got end of expression", text)
+		}
+		return t, p.errorf(t, "expected %q, got %q", text, t.text)
+	}
+	return t, nil
+}
+
+func (p *parser) isOperator(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOperator {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseExpression() (node, error) {
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokQuestion {
+		return cond, nil
+	}
+	pos := p.next().pos
+
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokColon, ":"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ternaryNode{pos: pos, cond: cond, then: then, els: els}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		op := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: op.pos, op: op.text, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		op := p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: op.pos, op: op.text, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		// "not in", "not contains" and so on negate the operator after not.
+		if p.isOperator("!") && p.tokens[p.pos+1].kind == tokOperator && negatable[p.tokens[p.pos+1].text] {
+			not := p.next()
+			op := p.next()
+			var right node
+			if op.text == "in" {
+				right, err = p.parseList()
+			} else {
+				right, err = p.parseAdditive()
+			}
+			if err != nil {
+				return nil, err
+			}
+			left = &unaryNode{pos: not.pos, op: "!", operand: &binaryNode{pos: not.pos, op: op.text, left: left, right: right}}
+			continue
+		}
+
+		if !p.isOperator("==", "!=", "<", "<=", ">", ">=", "=~", "!~", "in", "contains", "startsWith", "endsWith") {
+			return left, nil
+		}
+		op := p.next()
+
+		var right node
+		if op.text == "in" {
+			right, err = p.parseList()
+		} else {
+			right, err = p.parseAdditive()
+		}
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: op.pos, op: op.text, left: left, right: right}
+	}
+}
+
+// negatable are the operators that may follow "not".
+var negatable = map[string]bool{"in": true, "contains": true, "startsWith": true, "endsWith": true}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: op.pos, op: op.text, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*", "/", "%") {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: op.pos, op: op.text, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("!", "-") {
+		op := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{pos: op.pos, op: op.text, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %q", t.text)
+		}
+		return &literalNode{pos: t.pos, value: f}, nil
+
+	case tokString:
+		return &literalNode{pos: t.pos, value: t.text}, nil
+
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{pos: t.pos, value: true}, nil
+		case "false":
+			return &literalNode{pos: t.pos, value: false}, nil
+		case "nil", "null":
+			return &literalNode{pos: t.pos, value: nil}, nil
+		}
+		if p.peek().kind == tokLParen {
+			return p.parseCall(t)
+		}
+		return &identNode{pos: t.pos, name: t.text}, nil
+
+	case tokLParen:
+		inner, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	case tokLBracket:
+		p.pos--
+		return p.parseList()
+	}
+
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %q", name.text)
+	}
+	p.next()
+
+	args, err := p.parseArgs(tokRParen, ")")
+	if err != nil {
+		return nil, err
+	}
+	if fn.arity >= 0 && len(args) != fn.arity {
+		return nil, p.errorf(name, "%s expects %d argument(s), got %d", name.text, fn.arity, len(args))
+	}
+
+	return &callNode{pos: name.pos, name: name.text, fn: fn, args: args}, nil
+}
+
+// parseList parses the right-hand side of "in": either an expr-style
+// [a, b] array or a govaluate-style (a, b) tuple.
+func (p *parser) parseList() (node, error) {
+	t := p.next()
+
+	var closing tokenKind
+	var text string
+	switch t.kind {
+	case tokLBracket:
+		closing, text = tokRBracket, "]"
+	case tokLParen:
+		closing, text = tokRParen, ")"
+	default:
+		return nil, p.errorf(t, "expected list after \"in\"")
+	}
+
+	items, err := p.parseArgs(closing, text)
+	if err != nil {
+		return nil, err
+	}
+	return &listNode{pos: t.pos, items: items}, nil
+}
+
+// parseArgs parses a comma-separated expression list up to the closing
+// token, which is consumed.
+func (p *parser) parseArgs(closing tokenKind, text string) ([]node, error) {
+	var items []node
+	if p.peek().kind == closing {
+		p.next()
+		return items, nil
+	}
+
+	for {
+		item, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		t := p.next()
+		switch t.kind {
+		case tokComma:
+			continue
+		case closing:
+			return items, nil
+		}
+		if t.kind == tokEOF {
+			return nil, p.errorf(t, "expected %q, got end of expression", text)
+		}
+		return nil, p.errorf(t, "expected \",\" or %q, got %q", text, t.text)
+	}
+}
@@ -0,0 +1,212 @@
+package workflow
+
+// Artifact defines a workflow artifact. An artifact is read from or saved
+// to at most one location; without one, an input artifact is supplied by
+// its caller through From, and an output artifact is saved to the
+// workflow's artifact repository.
+type Artifact struct {
+	Name        string               `json:"name"`
+	Path        string               `json:"path,omitempty"`
+	From        string               `json:"from,omitempty"`
+	Mode        *int32               `json:"mode,omitempty"` // permissions of the files, e.g. 0755
+	Optional    bool                 `json:"optional,omitempty"`
+	Archive     *ArchiveStrategy     `json:"archive,omitempty"`
+	ArtifactGC  *ArtifactGC          `json:"artifactGC,omitempty"`
+	S3          *S3Artifact          `json:"s3,omitempty"`
+	HTTP        *HTTPArtifact        `json:"http,omitempty"`
+	Git         *GitArtifact         `json:"git,omitempty"`
+	Artifactory *ArtifactoryArtifact `json:"artifactory,omitempty"`
+	HDFS        *HDFSArtifact        `json:"hdfs,omitempty"`
+	Raw         *RawArtifact         `json:"raw,omitempty"`
+	OSS         *OSSArtifact         `json:"oss,omitempty"`
+	GCS         *GCSArtifact         `json:"gcs,omitempty"`
+	Azure       *AzureArtifact       `json:"azure,omitempty"`
+}
+
+// locations returns the names of the locations set on the artifact, in
+// the order Argo lists them.
+func (a *Artifact) locations() []string {
+	var set []string
+	for _, l := range []struct {
+		name string
+		set  bool
+	}{
+		{"s3", a.S3 != nil},
+		{"git", a.Git != nil},
+		{"http", a.HTTP != nil},
+		{"artifactory", a.Artifactory != nil},
+		{"hdfs", a.HDFS != nil},
+		{"raw", a.Raw != nil},
+		{"oss", a.OSS != nil},
+		{"gcs", a.GCS != nil},
+		{"azure", a.Azure != nil},
+	} {
+		if l.set {
+			set = append(set, l.name)
+		}
+	}
+	return set
+}
+
+// HasLocation reports whether the artifact names a location to load it
+// from or save it to.
+func (a *Artifact) HasLocation() bool {
+	return len(a.locations()) > 0
+}
+
+// SetLocation replaces the location of the artifact with that of src,
+// leaving its name, path and other settings as they are.
+func (a *Artifact) SetLocation(src *Artifact) {
+	a.S3, a.HTTP, a.Git, a.Artifactory, a.HDFS = src.S3, src.HTTP, src.Git, src.Artifactory, src.HDFS
+	a.Raw, a.OSS, a.GCS, a.Azure = src.Raw, src.OSS, src.GCS, src.Azure
+}
+
+// S3Artifact defines an S3 artifact location. Without credentials, the
+// ones of the artifact repository are used.
+type S3Artifact struct {
+	Endpoint        string             `json:"endpoint,omitempty"`
+	Bucket          string             `json:"bucket"`
+	Key             string             `json:"key"`
+	Region          string             `json:"region,omitempty"`
+	Insecure        *bool              `json:"insecure,omitempty"` // use HTTP instead of HTTPS
+	AccessKeySecret *SecretKeySelector `json:"accessKeySecret,omitempty"`
+	SecretKeySecret *SecretKeySelector `json:"secretKeySecret,omitempty"`
+}
+
+// HTTPArtifact defines an HTTP artifact location.
+type HTTPArtifact struct {
+	URL     string    `json:"url"`
+	Headers []Header  `json:"headers,omitempty"`
+	Auth    *HTTPAuth `json:"auth,omitempty"`
+}
+
+// Header is a header sent when fetching an HTTP artifact.
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HTTPAuth holds the credentials used to fetch an HTTP artifact.
+type HTTPAuth struct {
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+}
+
+// BasicAuth selects the secrets holding a username and password.
+type BasicAuth struct {
+	UsernameSecret *SecretKeySelector `json:"usernameSecret,omitempty"`
+	PasswordSecret *SecretKeySelector `json:"passwordSecret,omitempty"`
+}
+
+// GitArtifact defines a Git artifact location. Depth makes a shallow clone
+// of that many commits.
+type GitArtifact struct {
+	Repo                  string             `json:"repo"`
+	Revision              string             `json:"revision,omitempty"`
+	Depth                 *uint64            `json:"depth,omitempty"`
+	UsernameSecret        *SecretKeySelector `json:"usernameSecret,omitempty"`
+	PasswordSecret        *SecretKeySelector `json:"passwordSecret,omitempty"`
+	SSHPrivateKeySecret   *SecretKeySelector `json:"sshPrivateKeySecret,omitempty"`
+	InsecureIgnoreHostKey bool               `json:"insecureIgnoreHostKey,omitempty"`
+}
+
+// ArtifactoryArtifact defines an Artifactory artifact location.
+type ArtifactoryArtifact struct {
+	URL            string             `json:"url"`
+	UsernameSecret *SecretKeySelector `json:"usernameSecret,omitempty"`
+	PasswordSecret *SecretKeySelector `json:"passwordSecret,omitempty"`
+}
+
+// HDFSArtifact defines an HDFS artifact location. Force overwrites an
+// existing file when saving.
+type HDFSArtifact struct {
+	Addresses []string `json:"addresses,omitempty"`
+	HDFSUser  string   `json:"hdfsUser,omitempty"`
+	Path      string   `json:"path"`
+	Force     bool     `json:"force,omitempty"`
+}
+
+// RawArtifact is an artifact whose content is given inline.
+type RawArtifact struct {
+	Data string `json:"data"`
+}
+
+// OSSArtifact defines an Alibaba Cloud OSS artifact location.
+type OSSArtifact struct {
+	Endpoint        string             `json:"endpoint,omitempty"`
+	Bucket          string             `json:"bucket,omitempty"`
+	Key             string             `json:"key"`
+	AccessKeySecret *SecretKeySelector `json:"accessKeySecret,omitempty"`
+	SecretKeySecret *SecretKeySelector `json:"secretKeySecret,omitempty"`
+}
+
+// GCSArtifact defines a Google Cloud Storage artifact location.
+type GCSArtifact struct {
+	Bucket                  string             `json:"bucket,omitempty"`
+	Key                     string             `json:"key"`
+	ServiceAccountKeySecret *SecretKeySelector `json:"serviceAccountKeySecret,omitempty"`
+}
+
+// AzureArtifact defines an Azure Blob Storage artifact location.
+// UseSDKCreds authenticates with the environment's credentials instead of
+// an account key.
+type AzureArtifact struct {
+	Endpoint         string             `json:"endpoint,omitempty"`
+	Container        string             `json:"container,omitempty"`
+	Blob             string             `json:"blob"`
+	AccountKeySecret *SecretKeySelector `json:"accountKeySecret,omitempty"`
+	UseSDKCreds      bool               `json:"useSDKCreds,omitempty"`
+}
+
+// ArchiveStrategy decides how an output artifact is packed before it is
+// saved. The default is a gzipped tarball.
+type ArchiveStrategy struct {
+	Tar  *TarStrategy  `json:"tar,omitempty"`
+	None *NoneStrategy `json:"none,omitempty"`
+}
+
+// TarStrategy packs an artifact as a gzipped tarball. CompressionLevel is a
+// gzip level from 0 (none) to 9 (best).
+type TarStrategy struct {
+	CompressionLevel *int32 `json:"compressionLevel,omitempty"`
+}
+
+// NoneStrategy saves an artifact as is. A directory is saved as one
+// object per file, under the artifact's key.
+type NoneStrategy struct{}
+
+// ArtifactGC decides when saved artifacts are deleted. Set on the workflow,
+// it applies to every output artifact that does not set its own.
+type ArtifactGC struct {
+	Strategy           string    `json:"strategy,omitempty"`
+	ServiceAccountName string    `json:"serviceAccountName,omitempty"`
+	PodMetadata        *Metadata `json:"podMetadata,omitempty"`
+}
+
+// Artifact GC strategies.
+const (
+	ArtifactGCOnWorkflowCompletion = "OnWorkflowCompletion"
+	ArtifactGCOnWorkflowDeletion   = "OnWorkflowDeletion"
+	ArtifactGCNever                = "Never"
+)
+
+// ArtifactRepositoryRef selects the artifact repository configured under
+// Key of a ConfigMap in the workflow's namespace. Empty fields default to
+// "artifact-repositories" and the ConfigMap's default repository.
+type ArtifactRepositoryRef struct {
+	ConfigMap string `json:"configMap,omitempty"`
+	Key       string `json:"key,omitempty"`
+}
+
+// WithArtifactRepositoryRef saves the workflow's output artifacts to the
+// repository configured under key of the named ConfigMap.
+func (b *Builder) WithArtifactRepositoryRef(configMap, key string) *Builder {
+	b.artifactRepo = &ArtifactRepositoryRef{ConfigMap: configMap, Key: key}
+	return b
+}
+
+// WithArtifactGC deletes the workflow's output artifacts according to
+// strategy.
+func (b *Builder) WithArtifactGC(strategy string) *Builder {
+	b.artifactGC = &ArtifactGC{Strategy: strategy}
+	return b
+}
@@ -0,0 +1,117 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestArtifacts(t *testing.T) {
+	level, depth := int32(9), uint64(1)
+	wf, err := New("report").
+		WithEntrypoint("main").
+		WithArtifactRepositoryRef("artifact-repositories", "gcs").
+		WithArtifactGC(ArtifactGCOnWorkflowDeletion).
+		WithTemplate(ContainerTemplate("main",
+			WithImage("alpine:3.18"),
+			WithInputs(NewInputs().
+				AddArtifact(Artifact{Name: "config", Path: "/config.yaml", Raw: &RawArtifact{Data: "debug: true\n"}}).
+				AddArtifact(Artifact{Name: "src", Path: "/src", Git: &GitArtifact{
+					Repo:                "git@github.com:example/app.git",
+					Depth:               &depth,
+					SSHPrivateKeySecret: &SecretKeySelector{Name: "git-creds", Key: "ssh-key"},
+				}}).
+				AddArtifact(Artifact{Name: "model", Path: "/model", Optional: true, GCS: &GCSArtifact{Bucket: "models", Key: "latest"}})),
+			WithOutputs(NewOutputs().
+				AddArtifact(Artifact{
+					Name:       "report",
+					Path:       "/out/report.html",
+					Archive:    &ArchiveStrategy{None: &NoneStrategy{}},
+					ArtifactGC: &ArtifactGC{Strategy: ArtifactGCNever},
+					Azure:      &AzureArtifact{Container: "reports", Blob: "report.html", UseSDKCreds: true},
+				}).
+				AddArtifact(Artifact{Name: "logs", Path: "/out/logs", Archive: &ArchiveStrategy{Tar: &TarStrategy{CompressionLevel: &level}}})))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	data, err := wf.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML failed: %v", err)
+	}
+	for _, want := range []string{
+		"artifactRepositoryRef:\n    configMap: artifact-repositories\n    key: gcs",
+		"artifactGC:\n    strategy: OnWorkflowDeletion",
+		"raw:\n          data: |\n            debug: true",
+		"depth: 1",
+		"sshPrivateKeySecret:\n            key: ssh-key\n            name: git-creds",
+		"optional: true",
+		"archive:\n          none: {}",
+		"azure:\n          blob: report.html\n          container: reports\n          useSDKCreds: true",
+		"tar:\n            compressionLevel: 9",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("YAML missing %q:\n%s", want, data)
+		}
+	}
+	loaded, err := FromYAMLStrict(data)
+	if err != nil {
+		t.Fatalf("FromYAMLStrict failed: %v", err)
+	}
+	if ref := loaded.Spec.ArtifactRepositoryRef; ref == nil || ref.Key != "gcs" {
+		t.Errorf("ArtifactRepositoryRef = %+v, want it to round-trip", ref)
+	}
+	outputs := loaded.Spec.Templates[0].Outputs.Artifacts
+	if a := outputs[0]; a.Archive == nil || a.Archive.None == nil || a.ArtifactGC.Strategy != ArtifactGCNever {
+		t.Errorf("output artifact = %+v, want archive and artifactGC to round-trip", a)
+	}
+}
+
+func TestValidateArtifacts(t *testing.T) {
+	mode, level := int32(0o1777), int32(10)
+	wf := &Workflow{}
+	wf.Name = "artifacts"
+	wf.Spec.Entrypoint = "main"
+	wf.Spec.ArtifactRepositoryRef = &ArtifactRepositoryRef{ConfigMap: "Repos"}
+	wf.Spec.ArtifactGC = &ArtifactGC{Strategy: "Weekly"}
+	wf.Spec.Arguments = NewArguments().AddArtifact(Artifact{Name: "data"})
+	wf.Spec.Templates = []Template{
+		NewDAG("main").
+			Task("a", "consume").
+			Task("b", "consume", WithArguments(NewArguments().
+				AddArtifact(Artifact{Name: "data", From: "{{tasks.a.outputs.artifacts.data}}", S3: &S3Artifact{Key: "x"}}))).
+			Build(),
+		ContainerTemplate("consume",
+			WithImage("alpine:3.18"),
+			WithInputs(NewInputs().
+				AddArtifact(Artifact{Name: "data", Path: "/data"}).
+				AddArtifact(Artifact{Name: "data", Path: "/copy", HTTP: &HTTPArtifact{}, GCS: &GCSArtifact{Key: "k"}}).
+				AddArtifact(Artifact{Name: "repo", Git: &GitArtifact{Repo: "r", UsernameSecret: &SecretKeySelector{Name: "creds"}}})),
+			WithOutputs(NewOutputs().
+				AddArtifact(Artifact{Name: "out", Path: "/out", Mode: &mode, Archive: &ArchiveStrategy{Tar: &TarStrategy{CompressionLevel: &level}}}).
+				AddArtifact(Artifact{Name: "blob", Path: "/blob", Archive: &ArchiveStrategy{Tar: &TarStrategy{}, None: &NoneStrategy{}},
+					Azure: &AzureArtifact{UseSDKCreds: true, AccountKeySecret: &SecretKeySelector{Name: "azure", Key: "key"}}}))),
+	}
+
+	errs := Validate(wf).ToAggregate().Error()
+	for _, want := range []string{
+		`spec.artifactRepositoryRef.configMap: Invalid value: "Repos"`,
+		`spec.artifactGC.strategy: Unsupported value: "Weekly"`,
+		"spec.arguments.artifacts[0]: Required value: from or exactly one of s3, git, http, artifactory, hdfs, raw, oss, gcs or azure must be set",
+		`spec.templates[0].dag.tasks[0].arguments.artifacts: Required value: input artifact "data" of template "consume" is not supplied`,
+		"spec.templates[0].dag.tasks[1].arguments.artifacts[0].from: Forbidden: from and a location cannot be used together",
+		`spec.templates[1].inputs.artifacts[1].name: Duplicate value: "data"`,
+		"spec.templates[1].inputs.artifacts[1]: Forbidden: exactly one of s3, git, http, artifactory, hdfs, raw, oss, gcs or azure must be set, got http, gcs",
+		"spec.templates[1].inputs.artifacts[1].http.url: Required value",
+		"spec.templates[1].inputs.artifacts[2].git.usernameSecret.key: Required value",
+		"spec.templates[1].outputs.artifacts[0].mode: Invalid value: 1023: must be between 0 and 0777",
+		"spec.templates[1].outputs.artifacts[0].archive.tar.compressionLevel: Invalid value: 10: must be between 0 and 9",
+		"spec.templates[1].outputs.artifacts[1].archive: Forbidden: exactly one of tar or none must be set",
+		"spec.templates[1].outputs.artifacts[1].azure.blob: Required value",
+		"spec.templates[1].outputs.artifacts[1].azure.accountKeySecret: Forbidden: accountKeySecret and useSDKCreds cannot be used together",
+	} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errors missing %q:\n%s", want, errs)
+		}
+	}
+}
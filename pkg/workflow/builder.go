@@ -21,7 +21,26 @@ type Builder struct {
 	namespace          string
 	generateName       string
 	serviceAccountName string
+	nodeSelector       map[string]string
+	tolerations        []Toleration
+	affinity           *Affinity
+	securityContext    *PodSecurityContext
+	podSpecPatch       string
+	podPriorityClass   string
+	podMetadata        *Metadata
+	volumes            []Volume
+	volumeClaims       []PersistentVolumeClaim
+	artifactRepo       *ArtifactRepositoryRef
+	artifactGC         *ArtifactGC
 	entrypoint         string
+	onExit             string
+	parallelism        *int32
+	activeDeadline     *int64
+	ttl                *int32
+	ttlStrategy        *TTLStrategy
+	podGC              *PodGC
+	synchronization    *Synchronization
+	templateRef        *WorkflowTemplateRef
 	templates          []Template
 	arguments          *Arguments
 	labels             map[string]string
@@ -56,12 +75,130 @@ func (b *Builder) WithServiceAccount(sa string) *Builder {
 	return b
 }
 
+// WithNodeSelector restricts the workflow's pods to nodes with the given
+// labels. Templates may set their own.
+func (b *Builder) WithNodeSelector(labels map[string]string) *Builder {
+	b.nodeSelector = labels
+	return b
+}
+
+// WithToleration lets the workflow's pods run on nodes with a matching
+// taint.
+func (b *Builder) WithToleration(toleration Toleration) *Builder {
+	b.tolerations = append(b.tolerations, toleration)
+	return b
+}
+
+// WithAffinity sets the scheduling affinity of the workflow's pods.
+func (b *Builder) WithAffinity(affinity *Affinity) *Builder {
+	b.affinity = affinity
+	return b
+}
+
+// WithSecurityContext sets the security context of the workflow's pods.
+func (b *Builder) WithSecurityContext(sc *PodSecurityContext) *Builder {
+	b.securityContext = sc
+	return b
+}
+
+// WithPodSpecPatch merges a JSON or YAML patch into the spec of every pod
+// of the workflow.
+func (b *Builder) WithPodSpecPatch(patch string) *Builder {
+	b.podSpecPatch = patch
+	return b
+}
+
+// WithPodPriorityClassName sets the priority class of the workflow's pods.
+func (b *Builder) WithPodPriorityClassName(name string) *Builder {
+	b.podPriorityClass = name
+	return b
+}
+
+// WithPodMetadata adds labels and annotations to the workflow's pods.
+// Unlike WithLabel and WithAnnotation, these do not apply to the Workflow
+// itself.
+func (b *Builder) WithPodMetadata(labels, annotations map[string]string) *Builder {
+	b.podMetadata = &Metadata{Labels: labels, Annotations: annotations}
+	return b
+}
+
+// WithVolumes declares volumes that every template of the workflow can
+// mount.
+func (b *Builder) WithVolumes(volumes ...Volume) *Builder {
+	b.volumes = append(b.volumes, volumes...)
+	return b
+}
+
+// WithVolumeClaimTemplates adds claims created for each run of the
+// workflow. Templates mount them by name.
+func (b *Builder) WithVolumeClaimTemplates(claims ...PersistentVolumeClaim) *Builder {
+	b.volumeClaims = append(b.volumeClaims, claims...)
+	return b
+}
+
 // WithEntrypoint sets the entrypoint template.
 func (b *Builder) WithEntrypoint(name string) *Builder {
 	b.entrypoint = name
 	return b
 }
 
+// WithOnExit sets the exit handler, a template that runs after the
+// entrypoint completes whatever its outcome. It can inspect the outcome
+// through {{workflow.status}} and {{workflow.failures}}.
+func (b *Builder) WithOnExit(template string) *Builder {
+	b.onExit = template
+	return b
+}
+
+// WithParallelism limits how many pods of the workflow run at once.
+func (b *Builder) WithParallelism(n int32) *Builder {
+	b.parallelism = &n
+	return b
+}
+
+// WithActiveDeadline sets how many seconds the workflow may run before it
+// is failed. Unlike a template's timeout, it covers the whole workflow.
+func (b *Builder) WithActiveDeadline(seconds int64) *Builder {
+	b.activeDeadline = &seconds
+	return b
+}
+
+// WithTTL deletes the workflow the given number of seconds after it
+// finishes. Argo deprecates ttlSecondsAfterFinished in favour of
+// WithTTLStrategy.
+func (b *Builder) WithTTL(seconds int32) *Builder {
+	b.ttl = &seconds
+	return b
+}
+
+// WithTTLStrategy deletes the workflow some time after it finishes,
+// depending on how it finished.
+func (b *Builder) WithTTLStrategy(strategy TTLStrategy) *Builder {
+	b.ttlStrategy = &strategy
+	return b
+}
+
+// WithPodGC deletes the workflow's pods according to one of the PodGC
+// strategies, such as PodGCOnPodSuccess.
+func (b *Builder) WithPodGC(strategy string) *Builder {
+	b.podGC = &PodGC{Strategy: strategy}
+	return b
+}
+
+// WithWorkflowTemplateRef runs the named WorkflowTemplate. Its entrypoint,
+// templates and arguments are used unless the workflow sets its own.
+func (b *Builder) WithWorkflowTemplateRef(name string) *Builder {
+	b.templateRef = &WorkflowTemplateRef{Name: name}
+	return b
+}
+
+// WithClusterWorkflowTemplateRef runs the named ClusterWorkflowTemplate;
+// see WithWorkflowTemplateRef.
+func (b *Builder) WithClusterWorkflowTemplateRef(name string) *Builder {
+	b.templateRef = &WorkflowTemplateRef{Name: name, ClusterScope: true}
+	return b
+}
+
 // WithTemplate adds a template to the workflow.
 func (b *Builder) WithTemplate(t Template) *Builder {
 	b.templates = append(b.templates, t)
@@ -88,47 +225,114 @@ func (b *Builder) WithAnnotation(key, value string) *Builder {
 
 // Build constructs the final Workflow object.
 // This method validates the workflow configuration and returns an error
-// if any required fields are missing or invalid.
+// if any required fields are missing or invalid. A workflow that references
+// a WorkflowTemplate may omit its entrypoint and templates.
 func (b *Builder) Build() (*Workflow, error) {
-	if b.entrypoint == "" {
-		return nil, fmt.Errorf("entrypoint is required")
-	}
+	if b.templateRef == nil {
+		if b.entrypoint == "" {
+			return nil, fmt.Errorf("entrypoint is required")
+		}
 
-	if len(b.templates) == 0 {
-		return nil, fmt.Errorf("at least one template is required")
-	}
+		if len(b.templates) == 0 {
+			return nil, fmt.Errorf("at least one template is required")
+		}
 
-	// Validate entrypoint exists
-	found := false
-	for _, t := range b.templates {
-		if t.Name == b.entrypoint {
-			found = true
-			break
+		// Validate entrypoint exists
+		found := false
+		for _, t := range b.templates {
+			if t.Name == b.entrypoint {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("entrypoint template %q not found", b.entrypoint)
 		}
-	}
-	if !found {
-		return nil, fmt.Errorf("entrypoint template %q not found", b.entrypoint)
 	}
 
 	wf := &Workflow{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:         b.name,
-			GenerateName: b.generateName,
-			Namespace:    b.namespace,
-			Labels:       b.labels,
-			Annotations:  b.annotations,
-		},
-		Spec: WorkflowSpec{
-			Entrypoint:         b.entrypoint,
-			Templates:          b.templates,
-			Arguments:          b.arguments,
-			ServiceAccountName: b.serviceAccountName,
-		},
+		ObjectMeta: b.objectMeta(),
+		Spec:       b.spec(),
+	}
+
+	if errs := Validate(wf); len(errs) > 0 {
+		return nil, errs.ToAggregate()
 	}
 
 	return wf, nil
 }
 
+// BuildWorkflowTemplate constructs a WorkflowTemplate from the builder.
+// The entrypoint is optional, since a WorkflowTemplate may only provide
+// templates for others to reference.
+func (b *Builder) BuildWorkflowTemplate() (*WorkflowTemplate, error) {
+	wft := &WorkflowTemplate{
+		ObjectMeta: b.objectMeta(),
+		Spec:       b.spec(),
+	}
+
+	if errs := ValidateWorkflowTemplate(wft); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+
+	return wft, nil
+}
+
+// BuildClusterWorkflowTemplate constructs a ClusterWorkflowTemplate from
+// the builder. Cluster-scoped objects have no namespace, so any namespace
+// set on the builder is ignored.
+func (b *Builder) BuildClusterWorkflowTemplate() (*ClusterWorkflowTemplate, error) {
+	cwft := &ClusterWorkflowTemplate{
+		ObjectMeta: b.objectMeta(),
+		Spec:       b.spec(),
+	}
+	cwft.Namespace = ""
+
+	if errs := ValidateClusterWorkflowTemplate(cwft); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+
+	return cwft, nil
+}
+
+func (b *Builder) objectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:         b.name,
+		GenerateName: b.generateName,
+		Namespace:    b.namespace,
+		Labels:       b.labels,
+		Annotations:  b.annotations,
+	}
+}
+
+func (b *Builder) spec() WorkflowSpec {
+	return WorkflowSpec{
+		Entrypoint:            b.entrypoint,
+		Templates:             b.templates,
+		Arguments:             b.arguments,
+		ServiceAccountName:    b.serviceAccountName,
+		NodeSelector:          b.nodeSelector,
+		Tolerations:           b.tolerations,
+		Affinity:              b.affinity,
+		SecurityContext:       b.securityContext,
+		PodSpecPatch:          b.podSpecPatch,
+		PodPriorityClassName:  b.podPriorityClass,
+		PodMetadata:           b.podMetadata,
+		Volumes:               b.volumes,
+		VolumeClaimTemplates:  b.volumeClaims,
+		ArtifactRepositoryRef: b.artifactRepo,
+		ArtifactGC:            b.artifactGC,
+		Parallelism:           b.parallelism,
+		ActiveDeadline:        b.activeDeadline,
+		TTL:                   b.ttl,
+		TTLStrategy:           b.ttlStrategy,
+		PodGC:                 b.podGC,
+		OnExit:                b.onExit,
+		Synchronization:       b.synchronization,
+		WorkflowTemplateRef:   b.templateRef,
+	}
+}
+
 // Submit builds and submits the workflow to an Argo server.
 // This is different from Hera's w.create() - it uses Go's context
 // for cancellation and proper error handling.
@@ -1,6 +1,7 @@
 package workflow
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -62,3 +63,128 @@ func TestBuilderInvalidEntrypoint(t *testing.T) {
 		t.Fatal("Expected error for invalid entrypoint")
 	}
 }
+
+func TestBuilderInvalidCondition(t *testing.T) {
+	dag := NewDAG("main").
+		Task("A", "test").
+		Task("B", "test", WithDependencies("A"), WithCondition("{{tasks.A.outputs.result}} == ")).
+		Build()
+
+	_, err := New("test-workflow").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("test", WithImage("alpine:3.18"))).
+		WithTemplate(dag).
+		Build()
+
+	if err == nil {
+		t.Fatal("Expected error for invalid when condition")
+	}
+}
+
+func TestCheckCondition(t *testing.T) {
+	if err := CheckCondition(`"{{tasks.flip.outputs.result}}" == heads`); err != nil {
+		t.Errorf("CheckCondition() error = %v", err)
+	}
+	if err := CheckCondition(`{{tasks.flip.outputs.result}} ==`); err == nil {
+		t.Error("Expected syntax error")
+	}
+}
+
+func TestBuilderOnExit(t *testing.T) {
+	wf, err := New("test-workflow").
+		WithEntrypoint("test").
+		WithOnExit("notify").
+		WithTemplate(ContainerTemplate("test", WithImage("alpine:3.18"))).
+		WithTemplate(ContainerTemplate("notify", WithImage("alpine:3.18"), WithArgs("{{workflow.status}}"))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if wf.Spec.OnExit != "notify" {
+		t.Errorf("Expected onExit 'notify', got '%s'", wf.Spec.OnExit)
+	}
+
+	_, err = New("test-workflow").
+		WithEntrypoint("test").
+		WithOnExit("missing").
+		WithTemplate(ContainerTemplate("test", WithImage("alpine:3.18"))).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), "spec.onExit") {
+		t.Errorf("Build() error = %v, want spec.onExit not found", err)
+	}
+}
+
+func TestBuilderHooks(t *testing.T) {
+	dag := NewDAG("main").
+		Task("A", "test",
+			WithHook(ExitHook, LifecycleHook{Template: "test"}),
+			WithHook("failed", LifecycleHook{Template: "missing", Expression: `tasks.A.status == "Failed"`}),
+			WithHook("running", LifecycleHook{Template: "test"}),
+		).
+		Build()
+
+	if len(dag.DAG.Tasks[0].Hooks) != 3 {
+		t.Fatalf("Expected 3 hooks, got %d", len(dag.DAG.Tasks[0].Hooks))
+	}
+
+	_, err := New("test-workflow").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("test", WithImage("alpine:3.18"))).
+		WithTemplate(dag).
+		Build()
+	if err == nil {
+		t.Fatal("Expected error for invalid hooks")
+	}
+	for _, want := range []string{
+		"spec.templates[1].dag.tasks[0].hooks[failed].template",
+		"spec.templates[1].dag.tasks[0].hooks[running].expression",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Build() error = %v, want %s", err, want)
+		}
+	}
+}
+
+func TestBuilderSpecFields(t *testing.T) {
+	after := int32(600)
+	wf, err := New("limits").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("main", WithImage("alpine:3.18"), WithActiveDeadlineSeconds(30))).
+		WithParallelism(2).
+		WithActiveDeadline(3600).
+		WithTTL(60).
+		WithTTLStrategy(TTLStrategy{SecondsAfterSuccess: &after}).
+		WithPodGC(PodGCOnPodSuccess).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	spec := wf.Spec
+	if *spec.Parallelism != 2 || *spec.ActiveDeadline != 3600 || *spec.TTL != 60 {
+		t.Errorf("spec = %d %d %d, want 2 3600 60", *spec.Parallelism, *spec.ActiveDeadline, *spec.TTL)
+	}
+	if *spec.TTLStrategy.SecondsAfterSuccess != 600 || spec.PodGC.Strategy != PodGCOnPodSuccess {
+		t.Errorf("ttlStrategy = %+v, podGC = %+v", spec.TTLStrategy, spec.PodGC)
+	}
+	if spec.Templates[0].ActiveDeadlineSeconds.IntValue() != 30 {
+		t.Errorf("activeDeadlineSeconds = %v, want 30", spec.Templates[0].ActiveDeadlineSeconds)
+	}
+
+	_, err = New("limits").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("main", WithImage("alpine:3.18"), WithActiveDeadlineSeconds(-1))).
+		WithParallelism(-1).
+		WithPodGC("Never").
+		Build()
+	for _, want := range []string{
+		"spec.parallelism: Invalid value: -1: must be non-negative",
+		`spec.podGC.strategy: Unsupported value: "Never"`,
+		"spec.templates[0].activeDeadlineSeconds: Invalid value: -1: must be non-negative",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Build() error = %v, want %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package workflow
+
+import (
+	"strings"
+
+	"github.com/vjranagit/argo-workflows/pkg/expr"
+)
+
+// CheckCondition checks that a when condition or retry expression is
+// well-formed. Template placeholders are substituted before Argo evaluates a
+// condition, so each {{...}} tag is treated as an opaque value here.
+func CheckCondition(cond string) error {
+	// Mask tags with an identifier of the same length so that syntax error
+	// positions still point into the original string.
+	masked := placeholderPattern.ReplaceAllStringFunc(cond, func(tag string) string {
+		return strings.Repeat("_", len(tag))
+	})
+	_, err := expr.Parse(masked)
+	return err
+}
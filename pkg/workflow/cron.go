@@ -0,0 +1,181 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronBuilder configures the schedule of a CronWorkflow. The workflow it
+// submits is described by the Builder that created it.
+type CronBuilder struct {
+	workflow          *Builder
+	schedule          string
+	timezone          string
+	concurrencyPolicy string
+	startingDeadline  *int64
+}
+
+// Cron returns a builder for a CronWorkflow that submits the workflow
+// described by b on the given schedule, e.g. "0 2 * * *" or "@hourly".
+func (b *Builder) Cron(schedule string) *CronBuilder {
+	return &CronBuilder{
+		workflow: b,
+		schedule: schedule,
+	}
+}
+
+// WithTimezone sets the IANA time zone the schedule is evaluated in,
+// e.g. "Europe/Berlin". The controller's local time is used by default.
+func (c *CronBuilder) WithTimezone(tz string) *CronBuilder {
+	c.timezone = tz
+	return c
+}
+
+// WithConcurrencyPolicy sets what happens when a run is due while the
+// previous one is still active: ConcurrencyPolicyAllow,
+// ConcurrencyPolicyForbid or ConcurrencyPolicyReplace.
+func (c *CronBuilder) WithConcurrencyPolicy(policy string) *CronBuilder {
+	c.concurrencyPolicy = policy
+	return c
+}
+
+// WithStartingDeadline sets how many seconds after its scheduled time a
+// missed run may still be started.
+func (c *CronBuilder) WithStartingDeadline(seconds int64) *CronBuilder {
+	c.startingDeadline = &seconds
+	return c
+}
+
+// Build constructs the CronWorkflow and validates it.
+func (c *CronBuilder) Build() (*CronWorkflow, error) {
+	cwf := &CronWorkflow{
+		ObjectMeta: c.workflow.objectMeta(),
+		Spec: CronWorkflowSpec{
+			WorkflowSpec:            c.workflow.spec(),
+			Schedule:                c.schedule,
+			Timezone:                c.timezone,
+			ConcurrencyPolicy:       c.concurrencyPolicy,
+			StartingDeadlineSeconds: c.startingDeadline,
+		},
+	}
+
+	if errs := ValidateCronWorkflow(cwf); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+
+	return cwf, nil
+}
+
+// cronDescriptors are the predefined schedules accepted in place of the
+// five cron fields.
+var cronDescriptors = map[string]bool{
+	"@yearly":   true,
+	"@annually": true,
+	"@monthly":  true,
+	"@weekly":   true,
+	"@daily":    true,
+	"@midnight": true,
+	"@hourly":   true,
+}
+
+// cronField describes the range and names accepted by one schedule field.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 6, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// checkSchedule checks a schedule the way the Argo controller parses it:
+// five space-separated fields, a descriptor such as "@daily", or
+// "@every <duration>".
+func checkSchedule(schedule string) error {
+	if strings.HasPrefix(schedule, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(schedule, "@every ")))
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("@every duration must be positive")
+		}
+		return nil
+	}
+	if strings.HasPrefix(schedule, "@") {
+		if !cronDescriptors[schedule] {
+			return fmt.Errorf("unknown descriptor %s", schedule)
+		}
+		return nil
+	}
+
+	fields := strings.Fields(schedule)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("expected %d fields, found %d", len(cronFields), len(fields))
+	}
+	for i, f := range fields {
+		if err := cronFields[i].check(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check validates a comma-separated list of "*", values and ranges, each
+// optionally followed by "/step".
+func (cf cronField) check(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		rng, step, hasStep := strings.Cut(item, "/")
+		if hasStep {
+			if n, err := strconv.Atoi(step); err != nil || n <= 0 {
+				return fmt.Errorf("%s: invalid step %q", cf.name, step)
+			}
+		}
+
+		if rng == "*" || (rng == "?" && (cf.name == "day of month" || cf.name == "day of week")) {
+			continue
+		}
+
+		lo, hi, isRange := strings.Cut(rng, "-")
+		start, err := cf.value(lo)
+		if err != nil {
+			return err
+		}
+		if isRange {
+			end, err := cf.value(hi)
+			if err != nil {
+				return err
+			}
+			if end < start {
+				return fmt.Errorf("%s: range %s is descending", cf.name, rng)
+			}
+		}
+	}
+	return nil
+}
+
+// value parses a single number or name of the field.
+func (cf cronField) value(s string) (int, error) {
+	for i, name := range cf.names {
+		if strings.EqualFold(s, name) {
+			return cf.min + i, nil
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value %q", cf.name, s)
+	}
+	// Sunday may also be written as 7.
+	if n < cf.min || n > cf.max && !(cf.name == "day of week" && n == 7) {
+		return 0, fmt.Errorf("%s: %d is out of range [%d, %d]", cf.name, n, cf.min, cf.max)
+	}
+	return n, nil
+}
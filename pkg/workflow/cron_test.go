@@ -0,0 +1,243 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestCronBuilder(t *testing.T) {
+	cwf, err := New("nightly").
+		WithNamespace("ci").
+		WithWorkflowTemplateRef("build").
+		Cron("0 2 * * mon-fri").
+		WithTimezone("Europe/Berlin").
+		WithConcurrencyPolicy(ConcurrencyPolicyForbid).
+		WithStartingDeadline(60).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if cwf.Spec.Schedule != "0 2 * * mon-fri" || cwf.Spec.Timezone != "Europe/Berlin" {
+		t.Errorf("Spec = %+v", cwf.Spec)
+	}
+	if cwf.Spec.WorkflowSpec.WorkflowTemplateRef == nil || cwf.Spec.WorkflowSpec.WorkflowTemplateRef.Name != "build" {
+		t.Errorf("workflowTemplateRef = %+v, want build", cwf.Spec.WorkflowSpec.WorkflowTemplateRef)
+	}
+
+	data, err := cwf.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML() error = %v", err)
+	}
+	for _, want := range []string{"kind: CronWorkflow", "workflowSpec:", "concurrencyPolicy: Forbid", "startingDeadlineSeconds: 60"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("ToYAML() missing %q:\n%s", want, data)
+		}
+	}
+
+	back, err := CronWorkflowFromYAML(data)
+	if err != nil {
+		t.Fatalf("CronWorkflowFromYAML() error = %v", err)
+	}
+	if *back.Spec.StartingDeadlineSeconds != 60 || back.Spec.WorkflowSpec.WorkflowTemplateRef.Name != "build" {
+		t.Errorf("round trip = %+v", back.Spec)
+	}
+
+	if _, err := WorkflowTemplateFromYAML(data); err == nil {
+		t.Error("WorkflowTemplateFromYAML() should reject a CronWorkflow")
+	}
+}
+
+func TestValidateCronWorkflow(t *testing.T) {
+	deadline := int64(-1)
+	cwf := &CronWorkflow{
+		Spec: CronWorkflowSpec{
+			Schedule:                "0 25 * * *",
+			Timezone:                "Mars/Olympus",
+			ConcurrencyPolicy:       "Sometimes",
+			StartingDeadlineSeconds: &deadline,
+		},
+	}
+	cwf.Name = "bad"
+
+	want := map[string]field.ErrorType{
+		"spec.schedule":                field.ErrorTypeInvalid,
+		"spec.timezone":                field.ErrorTypeInvalid,
+		"spec.concurrencyPolicy":       field.ErrorTypeNotSupported,
+		"spec.startingDeadlineSeconds": field.ErrorTypeInvalid,
+		"spec.workflowSpec.templates":  field.ErrorTypeRequired,
+		"spec.workflowSpec.entrypoint": field.ErrorTypeRequired,
+	}
+
+	errs := ValidateCronWorkflow(cwf)
+	got := make(map[string]field.ErrorType, len(errs))
+	for _, err := range errs {
+		got[err.Field] = err.Type
+	}
+	for path, typ := range want {
+		if got[path] != typ {
+			t.Errorf("%s: got %q, want %q", path, got[path], typ)
+		}
+	}
+	if len(errs) != len(want) {
+		t.Errorf("got %d errors, want %d:\n%v", len(errs), len(want), errs.ToAggregate())
+	}
+}
+
+func TestCheckSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		wantErr  bool
+	}{
+		{"*/5 * * * *", false},
+		{"0 9-17/2 1,15 jan-jun MON", false},
+		{"0 0 ? * 7", false},
+		{"@daily", false},
+		{"@every 1h30m", false},
+		{"* * * *", true},
+		{"60 * * * *", true},
+		{"0 0 31-1 * *", true},
+		{"*/0 * * * *", true},
+		{"0 0 * foo *", true},
+		{"@fortnightly", true},
+		{"@every soon", true},
+	}
+
+	for _, tt := range tests {
+		if err := checkSchedule(tt.schedule); (err != nil) != tt.wantErr {
+			t.Errorf("checkSchedule(%q) error = %v, wantErr %v", tt.schedule, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWorkflowTemplate(t *testing.T) {
+	wft, err := New("library").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"))).
+		WithTemplate(NewDAG("fan-out").
+			Task("a", "echo").
+			Task("b", "", WithTemplateRef("other", "print")).
+			Build()).
+		BuildWorkflowTemplate()
+	if err != nil {
+		t.Fatalf("BuildWorkflowTemplate() error = %v", err)
+	}
+
+	data, err := wft.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML() error = %v", err)
+	}
+	if !strings.Contains(string(data), "kind: WorkflowTemplate") || strings.Contains(string(data), "entrypoint") {
+		t.Errorf("ToYAML() = %s", data)
+	}
+
+	back, err := WorkflowTemplateFromYAML(data)
+	if err != nil {
+		t.Fatalf("WorkflowTemplateFromYAML() error = %v", err)
+	}
+	ref := back.Spec.Templates[1].DAG.Tasks[1].TemplateRef
+	if ref == nil || ref.Name != "other" || ref.Template != "print" {
+		t.Errorf("templateRef = %+v, want other/print", ref)
+	}
+
+	cwft, err := New("shared").
+		WithNamespace("ignored").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"))).
+		BuildClusterWorkflowTemplate()
+	if err != nil {
+		t.Fatalf("BuildClusterWorkflowTemplate() error = %v", err)
+	}
+	if cwft.Namespace != "" {
+		t.Errorf("Namespace = %q, want none", cwft.Namespace)
+	}
+}
+
+func TestValidateTemplateRef(t *testing.T) {
+	wf := &Workflow{
+		Spec: WorkflowSpec{
+			Entrypoint: "main",
+			Templates: []Template{
+				ContainerTemplate("echo", WithImage("alpine:3.18")),
+				NewSteps("main").
+					Step("both", "echo", WithStepTemplateRef("lib", "echo")).
+					Step("incomplete", "", WithStepClusterTemplateRef("", "echo")).
+					Step("neither", "").
+					Build(),
+			},
+		},
+	}
+	wf.Name = "refs"
+
+	var got []string
+	for _, err := range Validate(wf) {
+		got = append(got, err.Field)
+	}
+	want := []string{
+		"spec.templates[1].steps[0][0].template",
+		"spec.templates[1].steps[1][0].templateRef.name",
+		"spec.templates[1].steps[2][0].template",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Validate() fields = %v, want %v", got, want)
+	}
+
+	ref, err := New("by-ref").WithWorkflowTemplateRef("library").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(ref.Spec.Templates) != 0 || ref.Spec.Entrypoint != "" {
+		t.Errorf("Spec = %+v, want only the reference", ref.Spec)
+	}
+}
+
+func TestJoinWorkflowSpec(t *testing.T) {
+	parallelism := int32(2)
+	base := &WorkflowSpec{
+		Entrypoint:  "main",
+		Parallelism: &parallelism,
+		Templates: []Template{
+			ContainerTemplate("main", WithImage("alpine:3.18")),
+			ContainerTemplate("echo", WithImage("alpine:3.18")),
+		},
+		Arguments: NewArguments().
+			AddParameter(Parameter{Name: "env", Value: "dev"}).
+			AddParameter(Parameter{Name: "region", Value: "eu"}),
+		Volumes:               []Volume{EmptyDirVolume("scratch"), SecretVolume("creds", "dev-creds")},
+		VolumeClaimTemplates:  []PersistentVolumeClaim{VolumeClaimTemplate("workdir", "1Gi")},
+		ArtifactRepositoryRef: &ArtifactRepositoryRef{Key: "s3"},
+	}
+	spec := &WorkflowSpec{
+		WorkflowTemplateRef:   &WorkflowTemplateRef{Name: "base"},
+		Templates:             []Template{ContainerTemplate("echo", WithImage("busybox"))},
+		Arguments:             NewArguments().AddParameter(Parameter{Name: "env", Value: "prod"}),
+		Volumes:               []Volume{SecretVolume("creds", "prod-creds")},
+		VolumeClaimTemplates:  []PersistentVolumeClaim{VolumeClaimTemplate("workdir", "10Gi")},
+		ArtifactRepositoryRef: &ArtifactRepositoryRef{Key: "gcs"},
+	}
+
+	joined := JoinWorkflowSpec(spec, base)
+
+	if joined.Entrypoint != "main" || joined.Parallelism != &parallelism {
+		t.Errorf("joined = %+v, want the template's entrypoint and parallelism", joined)
+	}
+	if len(joined.Templates) != 2 || joined.Templates[0].Container.Image != "busybox" {
+		t.Errorf("Templates = %+v, want the workflow's echo first", joined.Templates)
+	}
+	params := joined.Arguments.Parameters
+	if len(params) != 2 || params[0].Value != "prod" || params[1].Name != "region" {
+		t.Errorf("Parameters = %+v, want env=prod and region", params)
+	}
+	if len(joined.Volumes) != 2 || joined.Volumes[0].Secret.SecretName != "prod-creds" || joined.Volumes[1].Name != "scratch" {
+		t.Errorf("Volumes = %+v, want the workflow's creds and scratch", joined.Volumes)
+	}
+	if claims := joined.VolumeClaimTemplates; len(claims) != 1 || claims[0].Spec.Resources.Requests["storage"] != "10Gi" {
+		t.Errorf("VolumeClaimTemplates = %+v, want the workflow's workdir", claims)
+	}
+	if ref := joined.ArtifactRepositoryRef; ref.Key != "gcs" {
+		t.Errorf("ArtifactRepositoryRef = %+v, want the workflow's", ref)
+	}
+	if len(base.Arguments.Parameters) != 2 || base.Arguments.Parameters[0].Value != "dev" {
+		t.Error("JoinWorkflowSpec modified the template's arguments")
+	}
+}
@@ -1,6 +1,13 @@
 package workflow
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
 
 // DAGBuilder provides a fluent API for constructing DAG templates.
 // Unlike Hera's >> operator for dependencies, this uses explicit methods.
@@ -61,6 +68,15 @@ func WithDependencies(deps ...string) TaskOption {
 	}
 }
 
+// WithDepends sets an enhanced depends expression, such as
+// "(a.Succeeded || b.Failed) && c", in place of a dependencies list.
+// See ParseDepends.
+func WithDepends(expression string) TaskOption {
+	return func(t *DAGTask) {
+		t.Depends = expression
+	}
+}
+
 // WithArguments sets task arguments.
 func WithArguments(args *Arguments) TaskOption {
 	return func(t *DAGTask) {
@@ -75,100 +91,502 @@ func WithCondition(condition string) TaskOption {
 	}
 }
 
+// WithItems runs the task once per item. Each run sees the item as
+// {{item}}, or its fields as {{item.<key>}} when the item is a map.
+func WithItems(items ...interface{}) TaskOption {
+	return func(t *DAGTask) {
+		t.WithItems = items
+	}
+}
+
+// WithParam runs the task once per element of a JSON list, typically the
+// output of an earlier task such as "{{tasks.list.outputs.result}}".
+func WithParam(param string) TaskOption {
+	return func(t *DAGTask) {
+		t.WithParam = param
+	}
+}
+
+// WithSequence runs the task once per number of a sequence.
+func WithSequence(seq *Sequence) TaskOption {
+	return func(t *DAGTask) {
+		t.WithSequence = seq
+	}
+}
+
+// WithHook adds a lifecycle hook to the task. Use ExitHook as the name for
+// a hook that runs when the task completes.
+func WithHook(name string, hook LifecycleHook) TaskOption {
+	return func(t *DAGTask) {
+		if t.Hooks == nil {
+			t.Hooks = make(LifecycleHooks)
+		}
+		t.Hooks[name] = hook
+	}
+}
+
+// WithTemplateRef makes the task call a template of a WorkflowTemplate
+// instead of one of the workflow's own templates. Pass an empty template
+// name to Task when using it.
+func WithTemplateRef(name, template string) TaskOption {
+	return func(t *DAGTask) {
+		t.TemplateRef = &TemplateRef{Name: name, Template: template}
+	}
+}
+
+// WithClusterTemplateRef makes the task call a template of a
+// ClusterWorkflowTemplate; see WithTemplateRef.
+func WithClusterTemplateRef(name, template string) TaskOption {
+	return func(t *DAGTask) {
+		t.TemplateRef = &TemplateRef{Name: name, Template: template, ClusterScope: true}
+	}
+}
+
 // DependencyGraph helps visualize and validate DAG dependencies.
 // This is a helper that Hera doesn't provide - useful for debugging.
+// Every method that returns tasks orders them deterministically, breaking
+// ties by the order in which the tasks were declared.
 type DependencyGraph struct {
 	tasks map[string]*DAGTask
+	order []string
 }
 
 // NewDependencyGraph creates a new dependency graph from DAG tasks.
 func NewDependencyGraph(tasks []DAGTask) *DependencyGraph {
 	graph := &DependencyGraph{
 		tasks: make(map[string]*DAGTask),
+		order: make([]string, 0, len(tasks)),
 	}
 
 	for i := range tasks {
+		if _, dup := graph.tasks[tasks[i].Name]; !dup {
+			graph.order = append(graph.order, tasks[i].Name)
+		}
 		graph.tasks[tasks[i].Name] = &tasks[i]
 	}
 
 	return graph
 }
 
-// Validate checks for cycles and missing dependencies.
+// CycleError reports a dependency cycle. Path lists the tasks in
+// execution order, each one depending on the task before it, and ends with
+// the task it starts with, e.g. a -> b -> c -> a.
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "cycle detected: " + strings.Join(e.Path, " -> ")
+}
+
+// MissingDependencyError reports a dependency on a task that does not
+// exist.
+type MissingDependencyError struct {
+	Task       string
+	Dependency string
+}
+
+func (e *MissingDependencyError) Error() string {
+	return fmt.Sprintf("task %q depends on non-existent task %q", e.Task, e.Dependency)
+}
+
+// Validate checks for cycles and missing dependencies. Every problem is
+// reported, as a *CycleError for each cycle found and a
+// *MissingDependencyError for each missing dependency, joined into one
+// error.
 func (g *DependencyGraph) Validate() error {
-	visited := make(map[string]bool)
-	recStack := make(map[string]bool)
+	cycles, missing := g.problems()
 
-	for name := range g.tasks {
-		if err := g.hasCycle(name, visited, recStack); err != nil {
-			return err
-		}
+	errs := make([]error, 0, len(cycles)+len(missing))
+	for _, err := range cycles {
+		errs = append(errs, err)
 	}
+	for _, err := range missing {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
 
-	// Check for missing dependencies
-	for name, task := range g.tasks {
-		for _, dep := range task.Dependencies {
+// problems returns the graph's cycles and missing dependencies in
+// declaration order.
+func (g *DependencyGraph) problems() ([]*CycleError, []*MissingDependencyError) {
+	var missing []*MissingDependencyError
+	for _, name := range g.order {
+		for _, dep := range g.tasks[name].AllDependencies() {
 			if _, ok := g.tasks[dep]; !ok {
-				return fmt.Errorf("task %q depends on non-existent task %q", name, dep)
+				missing = append(missing, &MissingDependencyError{Task: name, Dependency: dep})
 			}
 		}
 	}
 
-	return nil
+	visited := make(map[string]bool)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles []*CycleError
+	for _, name := range g.order {
+		if !visited[name] {
+			cycles = g.findCycles(name, visited, onStack, &stack, cycles)
+		}
+	}
+
+	return cycles, missing
 }
 
-// hasCycle performs DFS to detect cycles.
-func (g *DependencyGraph) hasCycle(task string, visited, recStack map[string]bool) error {
+// findCycles performs DFS along dependencies and records a cycle for every
+// dependency that leads back onto the current path.
+func (g *DependencyGraph) findCycles(task string, visited, onStack map[string]bool, stack *[]string, cycles []*CycleError) []*CycleError {
 	visited[task] = true
-	recStack[task] = true
+	onStack[task] = true
+	*stack = append(*stack, task)
 
-	if t, ok := g.tasks[task]; ok {
-		for _, dep := range t.Dependencies {
-			if !visited[dep] {
-				if err := g.hasCycle(dep, visited, recStack); err != nil {
-					return err
-				}
-			} else if recStack[dep] {
-				return fmt.Errorf("cycle detected involving tasks %q and %q", task, dep)
-			}
+	for _, dep := range g.tasks[task].AllDependencies() {
+		if _, ok := g.tasks[dep]; !ok {
+			continue
+		}
+		if !visited[dep] {
+			cycles = g.findCycles(dep, visited, onStack, stack, cycles)
+			continue
+		}
+		if !onStack[dep] {
+			continue
 		}
+
+		// The stack runs from dep along dependencies back to task, so the
+		// execution order is the reverse.
+		var at int
+		for at = len(*stack) - 1; (*stack)[at] != dep; at-- {
+		}
+		path := []string{dep}
+		for i := len(*stack) - 1; i >= at; i-- {
+			path = append(path, (*stack)[i])
+		}
+		cycles = append(cycles, &CycleError{Path: path})
 	}
 
-	recStack[task] = false
-	return nil
+	*stack = (*stack)[:len(*stack)-1]
+	onStack[task] = false
+	return cycles
 }
 
 // TopologicalSort returns tasks in execution order.
 // This can help with visualization and understanding workflow execution.
+// The order is stable: of the tasks whose dependencies have all been
+// listed, the one declared first comes next, so tasks declared in a valid
+// execution order are returned as declared.
 func (g *DependencyGraph) TopologicalSort() ([]string, error) {
 	if err := g.Validate(); err != nil {
 		return nil, err
 	}
 
-	visited := make(map[string]bool)
-	stack := make([]string, 0)
+	index := make(map[string]int, len(g.order))
+	for i, name := range g.order {
+		index[name] = i
+	}
 
-	for name := range g.tasks {
-		if !visited[name] {
-			g.topologicalSortUtil(name, visited, &stack)
+	// pending counts the unlisted dependencies of each task; ready holds
+	// the declaration indexes of tasks with none, kept sorted.
+	pending := make(map[string]int, len(g.order))
+	var ready []int
+	for i, name := range g.order {
+		pending[name] = len(g.tasks[name].AllDependencies())
+		if pending[name] == 0 {
+			ready = append(ready, i)
 		}
 	}
+	dependents := g.dependents()
+
+	order := make([]string, 0, len(g.order))
+	for len(ready) > 0 {
+		name := g.order[ready[0]]
+		ready = ready[1:]
+		order = append(order, name)
 
-	// Stack is already in correct topological order (reversed during DFS)
-	// No need to reverse again
-	return stack, nil
+		for _, dependent := range dependents[name] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				i := index[dependent]
+				at := sort.SearchInts(ready, i)
+				ready = append(ready, 0)
+				copy(ready[at+1:], ready[at:])
+				ready[at] = i
+			}
+		}
+	}
+
+	return order, nil
 }
 
-func (g *DependencyGraph) topologicalSortUtil(task string, visited map[string]bool, stack *[]string) {
-	visited[task] = true
+// Levels groups tasks into waves that can run in parallel: the first level
+// holds the tasks without dependencies, and every other task is in the
+// level after its latest dependency. Tasks within a level are in
+// declaration order.
+func (g *DependencyGraph) Levels() ([][]string, error) {
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
 
-	if t, ok := g.tasks[task]; ok {
-		for _, dep := range t.Dependencies {
-			if !visited[dep] {
-				g.topologicalSortUtil(dep, visited, stack)
+	level := make(map[string]int, len(order))
+	depth := 0
+	for _, name := range order {
+		l := 0
+		for _, dep := range g.tasks[name].AllDependencies() {
+			if level[dep]+1 > l {
+				l = level[dep] + 1
 			}
 		}
+		level[name] = l
+		if l+1 > depth {
+			depth = l + 1
+		}
 	}
 
-	*stack = append(*stack, task)
+	levels := make([][]string, depth)
+	for _, name := range g.order {
+		levels[level[name]] = append(levels[level[name]], name)
+	}
+	return levels, nil
+}
+
+// CriticalPath returns the chain of dependent tasks with the longest total
+// duration, from a root task to a leaf, together with that total. It bounds
+// the wall-clock time of the DAG however much parallelism is available.
+// Tasks missing from durations count as taking no time. Of chains with the
+// same total, the one with the most tasks is returned, and of those the one
+// through the earliest declared tasks.
+func (g *DependencyGraph) CriticalPath(durations map[string]time.Duration) ([]string, time.Duration, error) {
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(order) == 0 {
+		return nil, 0, nil
+	}
+
+	index := make(map[string]int, len(g.order))
+	for i, name := range g.order {
+		index[name] = i
+	}
+
+	// finish is the earliest time each task can complete and via the
+	// dependency that determines it; length counts the tasks on the chain,
+	// so that longer chains win when durations are equal.
+	finish := make(map[string]time.Duration, len(order))
+	length := make(map[string]int, len(order))
+	via := make(map[string]string, len(order))
+	longer := func(a, b string) bool {
+		return finish[a] > finish[b] || (finish[a] == finish[b] && length[a] > length[b])
+	}
+	for _, name := range order {
+		deps := g.tasks[name].AllDependencies()
+		sort.Slice(deps, func(a, b int) bool { return index[deps[a]] < index[deps[b]] })
+		for _, dep := range deps {
+			if via[name] == "" || longer(dep, via[name]) {
+				via[name] = dep
+			}
+		}
+		finish[name] = finish[via[name]] + durations[name]
+		length[name] = length[via[name]] + 1
+	}
+
+	end := ""
+	for _, name := range g.order {
+		if end == "" || longer(name, end) {
+			end = name
+		}
+	}
+
+	var path []string
+	for name := end; name != ""; name = via[name] {
+		path = append(path, name)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, finish[end], nil
+}
+
+// Tasks returns the graph's tasks in declaration order.
+func (g *DependencyGraph) Tasks() []DAGTask {
+	tasks := make([]DAGTask, len(g.order))
+	for i, name := range g.order {
+		tasks[i] = *g.tasks[name]
+	}
+	return tasks
+}
+
+// Roots returns the tasks without dependencies, which start the DAG.
+func (g *DependencyGraph) Roots() []string {
+	var roots []string
+	for _, name := range g.order {
+		if len(g.tasks[name].AllDependencies()) == 0 {
+			roots = append(roots, name)
+		}
+	}
+	return roots
+}
+
+// Leaves returns the tasks no other task depends on, which end the DAG.
+func (g *DependencyGraph) Leaves() []string {
+	dependents := g.dependents()
+	var leaves []string
+	for _, name := range g.order {
+		if len(dependents[name]) == 0 {
+			leaves = append(leaves, name)
+		}
+	}
+	return leaves
+}
+
+// Ancestors returns every task that task depends on, directly or through
+// other tasks, in declaration order.
+func (g *DependencyGraph) Ancestors(task string) []string {
+	return g.reachable(task, func(name string) []string {
+		if t, ok := g.tasks[name]; ok {
+			return t.AllDependencies()
+		}
+		return nil
+	})
+}
+
+// Descendants returns every task that depends on task, directly or through
+// other tasks, in declaration order.
+func (g *DependencyGraph) Descendants(task string) []string {
+	dependents := g.dependents()
+	return g.reachable(task, func(name string) []string {
+		return dependents[name]
+	})
+}
+
+// reachable returns the tasks reachable from start through next, excluding
+// start itself unless it lies on a cycle.
+func (g *DependencyGraph) reachable(start string, next func(string) []string) []string {
+	seen := make(map[string]bool)
+	queue := append([]string(nil), next(start)...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		queue = append(queue, next(name)...)
+	}
+
+	var found []string
+	for _, name := range g.order {
+		if seen[name] {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// Subgraph returns the graph of the named tasks alone. Dependencies on
+// tasks outside the subgraph are dropped; unknown names are ignored. A
+// Depends expression that mentions such a task cannot be kept as written,
+// so it is replaced by a dependencies list of the tasks it mentions that
+// remain.
+func (g *DependencyGraph) Subgraph(tasks ...string) *DependencyGraph {
+	keep := make(map[string]bool, len(tasks))
+	for _, name := range tasks {
+		keep[name] = true
+	}
+
+	var sub []DAGTask
+	for _, name := range g.order {
+		if !keep[name] {
+			continue
+		}
+		task := *g.tasks[name]
+		deps := task.AllDependencies()
+		if !slices.ContainsFunc(deps, func(dep string) bool { return !keep[dep] }) {
+			sub = append(sub, task)
+			continue
+		}
+
+		task.Dependencies, task.Depends = nil, ""
+		for _, dep := range deps {
+			if keep[dep] {
+				task.Dependencies = append(task.Dependencies, dep)
+			}
+		}
+		sub = append(sub, task)
+	}
+	return NewDependencyGraph(sub)
+}
+
+// DependencyEdge is a dependency of Task on DependsOn.
+type DependencyEdge struct {
+	Task      string
+	DependsOn string
+}
+
+// RedundantDependencies returns the dependencies that are already implied
+// by others, such as c on a when c depends on b and b on a. They do not
+// change when a task can run and can be removed. Tasks named in a Depends
+// expression are never reported, since the expression may test how they
+// completed.
+func (g *DependencyGraph) RedundantDependencies() ([]DependencyEdge, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	var redundant []DependencyEdge
+	for _, name := range g.order {
+		task := g.tasks[name]
+		deps := task.AllDependencies()
+		for _, dep := range deps {
+			if !slices.Contains(task.Dependencies, dep) {
+				continue
+			}
+			for _, other := range deps {
+				if other != dep && slices.Contains(g.Ancestors(other), dep) {
+					redundant = append(redundant, DependencyEdge{Task: name, DependsOn: dep})
+					break
+				}
+			}
+		}
+	}
+	return redundant, nil
+}
+
+// TransitiveReduction returns a copy of the graph without its redundant
+// dependencies; see RedundantDependencies. Each task keeps exactly the
+// dependencies that no other dependency implies, and its Depends
+// expression as written.
+func (g *DependencyGraph) TransitiveReduction() (*DependencyGraph, error) {
+	redundant, err := g.RedundantDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	drop := make(map[DependencyEdge]bool, len(redundant))
+	for _, e := range redundant {
+		drop[e] = true
+	}
+
+	tasks := g.Tasks()
+	for i := range tasks {
+		var deps []string
+		for _, dep := range tasks[i].AllDependencies() {
+			if slices.Contains(tasks[i].Dependencies, dep) && !drop[DependencyEdge{Task: tasks[i].Name, DependsOn: dep}] {
+				deps = append(deps, dep)
+			}
+		}
+		tasks[i].Dependencies = deps
+	}
+	return NewDependencyGraph(tasks), nil
+}
+
+// dependents maps each task to the tasks that depend on it, in declaration
+// order.
+func (g *DependencyGraph) dependents() map[string][]string {
+	dependents := make(map[string][]string, len(g.order))
+	for _, name := range g.order {
+		for _, dep := range g.tasks[name].AllDependencies() {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+	return dependents
 }
@@ -1,7 +1,10 @@
 package workflow
 
 import (
+	"errors"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDAGBuilder(t *testing.T) {
@@ -103,3 +106,202 @@ func TestTopologicalSort(t *testing.T) {
 		t.Errorf("A should come before D in topological order")
 	}
 }
+
+func TestTopologicalSortStable(t *testing.T) {
+	tasks := []DAGTask{
+		{Name: "deploy", Dependencies: []string{"test", "lint"}},
+		{Name: "lint", Dependencies: []string{"checkout"}},
+		{Name: "test", Dependencies: []string{"build"}},
+		{Name: "checkout"},
+		{Name: "build", Dependencies: []string{"checkout"}},
+		{Name: "docs"},
+	}
+
+	want := "checkout lint build test deploy docs"
+	for i := 0; i < 20; i++ {
+		order, err := NewDependencyGraph(tasks).TopologicalSort()
+		if err != nil {
+			t.Fatalf("TopologicalSort failed: %v", err)
+		}
+		if got := strings.Join(order, " "); got != want {
+			t.Fatalf("TopologicalSort() = %v, want %v", got, want)
+		}
+	}
+
+	inOrder := []DAGTask{{Name: "A"}, {Name: "C"}, {Name: "B", Dependencies: []string{"A"}}}
+	if order, _ := NewDependencyGraph(inOrder).TopologicalSort(); strings.Join(order, " ") != "A C B" {
+		t.Errorf("TopologicalSort() = %v, want declaration order", order)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	tasks := []DAGTask{
+		{Name: "D", Dependencies: []string{"B", "C"}},
+		{Name: "C", Dependencies: []string{"A"}},
+		{Name: "B", Dependencies: []string{"A"}},
+		{Name: "A"},
+		{Name: "E", Dependencies: []string{"A", "A"}},
+	}
+
+	levels, err := NewDependencyGraph(tasks).Levels()
+	if err != nil {
+		t.Fatalf("Levels failed: %v", err)
+	}
+
+	got := make([]string, len(levels))
+	for i, level := range levels {
+		got[i] = strings.Join(level, ",")
+	}
+	if want := "A|C,B,E|D"; strings.Join(got, "|") != want {
+		t.Errorf("Levels() = %v, want %v", strings.Join(got, "|"), want)
+	}
+
+	cyclic := []DAGTask{{Name: "A", Dependencies: []string{"B"}}, {Name: "B", Dependencies: []string{"A"}}}
+	if _, err := NewDependencyGraph(cyclic).Levels(); err == nil {
+		t.Error("Levels() should fail on a cycle")
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	tasks := []DAGTask{
+		{Name: "A"},
+		{Name: "B", Dependencies: []string{"A"}},
+		{Name: "C", Dependencies: []string{"A"}},
+		{Name: "D", Dependencies: []string{"B", "C"}},
+		{Name: "E"},
+	}
+	graph := NewDependencyGraph(tasks)
+
+	path, total, err := graph.CriticalPath(map[string]time.Duration{
+		"A": time.Minute,
+		"B": 2 * time.Minute,
+		"C": 5 * time.Minute,
+		"D": time.Minute,
+		"E": 6 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("CriticalPath failed: %v", err)
+	}
+	if strings.Join(path, " ") != "A C D" || total != 7*time.Minute {
+		t.Errorf("CriticalPath() = %v %v, want A C D 7m", path, total)
+	}
+
+	// Equal chains resolve to the earliest declared tasks.
+	path, total, _ = graph.CriticalPath(nil)
+	if strings.Join(path, " ") != "A B D" || total != 0 {
+		t.Errorf("CriticalPath(nil) = %v %v, want A B D 0s", path, total)
+	}
+
+	if path, total, _ := NewDependencyGraph(nil).CriticalPath(nil); path != nil || total != 0 {
+		t.Errorf("CriticalPath() of no tasks = %v %v", path, total)
+	}
+}
+
+func TestDependencyGraphCyclePath(t *testing.T) {
+	tasks := []DAGTask{
+		{Name: "start"},
+		{Name: "a", Dependencies: []string{"start", "c"}},
+		{Name: "b", Dependencies: []string{"a"}},
+		{Name: "c", Dependencies: []string{"b"}},
+		{Name: "self", Dependencies: []string{"self"}},
+		{Name: "x", Dependencies: []string{"missing", "gone"}},
+	}
+
+	err := NewDependencyGraph(tasks).Validate()
+	if err == nil {
+		t.Fatal("Expected cycle detection error")
+	}
+
+	var cycle *CycleError
+	if !errors.As(err, &cycle) || strings.Join(cycle.Path, " -> ") != "a -> b -> c -> a" {
+		t.Errorf("cycle = %v, want a -> b -> c -> a", cycle)
+	}
+
+	want := []string{
+		"cycle detected: a -> b -> c -> a",
+		"cycle detected: self -> self",
+		`task "x" depends on non-existent task "missing"`,
+		`task "x" depends on non-existent task "gone"`,
+	}
+	if got := err.Error(); got != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%v\nwant\n%v", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestDependencyGraphQueries(t *testing.T) {
+	tasks := []DAGTask{
+		{Name: "A"},
+		{Name: "B", Dependencies: []string{"A"}},
+		{Name: "C", Dependencies: []string{"A"}},
+		{Name: "D", Dependencies: []string{"B", "C", "A"}},
+		{Name: "E"},
+	}
+	graph := NewDependencyGraph(tasks)
+
+	tests := []struct {
+		name string
+		got  []string
+		want string
+	}{
+		{"Roots", graph.Roots(), "A E"},
+		{"Leaves", graph.Leaves(), "D E"},
+		{"Ancestors(D)", graph.Ancestors("D"), "A B C"},
+		{"Ancestors(A)", graph.Ancestors("A"), ""},
+		{"Descendants(A)", graph.Descendants("A"), "B C D"},
+		{"Descendants(unknown)", graph.Descendants("unknown"), ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.got, " "); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	sub := graph.Subgraph("D", "B", "E")
+	var got []string
+	for _, task := range sub.Tasks() {
+		got = append(got, task.Name+":"+strings.Join(task.Dependencies, ","))
+	}
+	if want := "B: D:B E:"; strings.Join(got, " ") != want {
+		t.Errorf("Subgraph() = %v, want %v", got, want)
+	}
+	if len(tasks[3].Dependencies) != 3 {
+		t.Error("Subgraph modified the original tasks")
+	}
+}
+
+func TestTransitiveReduction(t *testing.T) {
+	tasks := []DAGTask{
+		{Name: "A"},
+		{Name: "B", Dependencies: []string{"A"}},
+		{Name: "C", Dependencies: []string{"B", "A"}},
+		{Name: "D", Dependencies: []string{"C", "A", "B"}},
+	}
+	graph := NewDependencyGraph(tasks)
+
+	redundant, err := graph.RedundantDependencies()
+	if err != nil {
+		t.Fatalf("RedundantDependencies failed: %v", err)
+	}
+	var got []string
+	for _, e := range redundant {
+		got = append(got, e.Task+"->"+e.DependsOn)
+	}
+	if want := "C->A D->A D->B"; strings.Join(got, " ") != want {
+		t.Errorf("RedundantDependencies() = %v, want %v", got, want)
+	}
+
+	reduced, err := graph.TransitiveReduction()
+	if err != nil {
+		t.Fatalf("TransitiveReduction failed: %v", err)
+	}
+	got = nil
+	for _, task := range reduced.Tasks() {
+		got = append(got, task.Name+":"+strings.Join(task.Dependencies, ","))
+	}
+	if want := "A: B:A C:B D:C"; strings.Join(got, " ") != want {
+		t.Errorf("TransitiveReduction() = %v, want %v", got, want)
+	}
+	if len(tasks[3].Dependencies) != 3 {
+		t.Error("TransitiveReduction modified the original tasks")
+	}
+}
@@ -0,0 +1,374 @@
+package workflow
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/vjranagit/argo-workflows/pkg/expr"
+)
+
+// Task results a depends expression can test, as in "a.Failed". A bare task
+// name means "a.Succeeded || a.Skipped || a.Daemoned". AnySucceeded and
+// AllFailed look at the iterations of a looped task.
+const (
+	DependsSucceeded    = "Succeeded"
+	DependsFailed       = "Failed"
+	DependsErrored      = "Errored"
+	DependsSkipped      = "Skipped"
+	DependsOmitted      = "Omitted"
+	DependsDaemoned     = "Daemoned"
+	DependsAnySucceeded = "AnySucceeded"
+	DependsAllFailed    = "AllFailed"
+)
+
+var dependsResults = []string{
+	DependsSucceeded, DependsFailed, DependsErrored, DependsSkipped,
+	DependsOmitted, DependsDaemoned, DependsAnySucceeded, DependsAllFailed,
+}
+
+// Depends is a parsed enhanced depends expression such as
+// "(a.Succeeded || b.Failed) && c". Unlike a dependencies list, which only
+// lets a task run once every dependency succeeded or was skipped, it can
+// react to how each dependency completed.
+type Depends struct {
+	src   string
+	root  dependsNode
+	terms []DependsTerm
+}
+
+// DependsTerm is a reference to a task in a depends expression. Result is
+// empty for a bare task name.
+type DependsTerm struct {
+	Task   string
+	Result string
+}
+
+func (t DependsTerm) String() string {
+	if t.Result == "" {
+		return t.Task
+	}
+	return t.Task + "." + t.Result
+}
+
+// TaskResult is how a completed task ended, as seen by a depends
+// expression. Children holds the phases of a looped task's iterations.
+type TaskResult struct {
+	Phase    string
+	Daemoned bool
+	Children []string
+}
+
+// ParseDepends parses a depends expression. Task references may be
+// combined with &&, || and !, and grouped with parentheses.
+// The returned error is a *expr.SyntaxError pointing at the offending token.
+func ParseDepends(src string) (*Depends, error) {
+	tokens, err := lexDepends(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dependsParser{src: src, tokens: tokens}
+	if p.peek().kind == dependsEOF {
+		return nil, p.errorf(p.peek(), "empty expression")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != dependsEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return &Depends{src: src, root: root, terms: p.terms}, nil
+}
+
+func (d *Depends) String() string {
+	return d.src
+}
+
+// Terms returns the task references in the expression in the order they
+// appear, without repeats.
+func (d *Depends) Terms() []DependsTerm {
+	return append([]DependsTerm(nil), d.terms...)
+}
+
+// Tasks returns the names of the referenced tasks in the order they first
+// appear.
+func (d *Depends) Tasks() []string {
+	seen := make(map[string]bool, len(d.terms))
+	var tasks []string
+	for _, t := range d.terms {
+		if !seen[t.Task] {
+			seen[t.Task] = true
+			tasks = append(tasks, t.Task)
+		}
+	}
+	return tasks
+}
+
+// Eval evaluates the expression against the results of the referenced
+// tasks, all of which must have completed.
+func (d *Depends) Eval(results map[string]TaskResult) (bool, error) {
+	for _, task := range d.Tasks() {
+		if _, ok := results[task]; !ok {
+			return false, fmt.Errorf("task %q has not completed", task)
+		}
+	}
+	return d.root.eval(results), nil
+}
+
+// AllDependencies returns the tasks t depends on, from both Dependencies
+// and Depends, without repeats. A Depends expression that does not parse
+// contributes no tasks; validation reports it.
+func (t *DAGTask) AllDependencies() []string {
+	deps := make([]string, 0, len(t.Dependencies))
+	seen := make(map[string]bool, len(t.Dependencies))
+	add := func(names []string) {
+		for _, dep := range names {
+			if !seen[dep] {
+				seen[dep] = true
+				deps = append(deps, dep)
+			}
+		}
+	}
+
+	add(t.Dependencies)
+	if t.Depends != "" {
+		if d, err := ParseDepends(t.Depends); err == nil {
+			add(d.Tasks())
+		}
+	}
+	return deps
+}
+
+type dependsNode interface {
+	eval(results map[string]TaskResult) bool
+}
+
+type dependsRef struct {
+	DependsTerm
+}
+
+func (n dependsRef) eval(results map[string]TaskResult) bool {
+	r := results[n.Task]
+	switch n.Result {
+	case "":
+		return r.Phase == PhaseSucceeded || r.Phase == PhaseSkipped || r.Daemoned
+	case DependsSucceeded:
+		return r.Phase == PhaseSucceeded
+	case DependsFailed:
+		return r.Phase == PhaseFailed
+	case DependsErrored:
+		return r.Phase == PhaseError
+	case DependsSkipped:
+		return r.Phase == PhaseSkipped
+	case DependsOmitted:
+		return r.Phase == PhaseOmitted
+	case DependsDaemoned:
+		return r.Daemoned
+	case DependsAnySucceeded:
+		for _, phase := range r.Children {
+			if phase == PhaseSucceeded {
+				return true
+			}
+		}
+		return false
+	case DependsAllFailed:
+		for _, phase := range r.Children {
+			if phase != PhaseFailed && phase != PhaseError {
+				return false
+			}
+		}
+		return len(r.Children) > 0
+	}
+	return false
+}
+
+type dependsNot struct {
+	x dependsNode
+}
+
+func (n dependsNot) eval(results map[string]TaskResult) bool {
+	return !n.x.eval(results)
+}
+
+type dependsBinary struct {
+	and  bool
+	x, y dependsNode
+}
+
+func (n dependsBinary) eval(results map[string]TaskResult) bool {
+	if n.and {
+		return n.x.eval(results) && n.y.eval(results)
+	}
+	return n.x.eval(results) || n.y.eval(results)
+}
+
+type dependsTokenKind int
+
+const (
+	dependsEOF dependsTokenKind = iota
+	dependsIdent
+	dependsDot
+	dependsAnd
+	dependsOr
+	dependsNotOp
+	dependsLParen
+	dependsRParen
+)
+
+type dependsToken struct {
+	kind dependsTokenKind
+	text string
+	pos  int
+}
+
+// lexDepends splits a depends expression into tokens. Task names follow
+// the DNS label rules of Argo task names, so '-' is part of a name.
+func lexDepends(src string) ([]dependsToken, error) {
+	var tokens []dependsToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isDependsNameChar(c):
+			start := i
+			for i < len(src) && isDependsNameChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, dependsToken{kind: dependsIdent, text: src[start:i], pos: start})
+		case c == '.':
+			tokens = append(tokens, dependsToken{kind: dependsDot, text: ".", pos: i})
+			i++
+		case c == '(':
+			tokens = append(tokens, dependsToken{kind: dependsLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, dependsToken{kind: dependsRParen, text: ")", pos: i})
+			i++
+		case c == '!':
+			tokens = append(tokens, dependsToken{kind: dependsNotOp, text: "!", pos: i})
+			i++
+		case strings.HasPrefix(src[i:], "&&"):
+			tokens = append(tokens, dependsToken{kind: dependsAnd, text: "&&", pos: i})
+			i += 2
+		case strings.HasPrefix(src[i:], "||"):
+			tokens = append(tokens, dependsToken{kind: dependsOr, text: "||", pos: i})
+			i += 2
+		default:
+			return nil, &expr.SyntaxError{Expr: src, Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, dependsToken{kind: dependsEOF, pos: len(src)}), nil
+}
+
+func isDependsNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+type dependsParser struct {
+	src    string
+	tokens []dependsToken
+	pos    int
+	terms  []DependsTerm
+}
+
+func (p *dependsParser) peek() dependsToken {
+	return p.tokens[p.pos]
+}
+
+func (p *dependsParser) next() dependsToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != dependsEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *dependsParser) errorf(tok dependsToken, format string, args ...interface{}) error {
+	return &expr.SyntaxError{Expr: p.src, Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *dependsParser) parseOr() (dependsNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == dependsOr {
+		p.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = dependsBinary{x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *dependsParser) parseAnd() (dependsNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == dependsAnd {
+		p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = dependsBinary{and: true, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *dependsParser) parseUnary() (dependsNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case dependsNotOp:
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return dependsNot{x: x}, nil
+
+	case dependsLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != dependsRParen {
+			return nil, p.errorf(closing, "expected ')'")
+		}
+		return x, nil
+
+	case dependsIdent:
+		term := DependsTerm{Task: tok.text}
+		if p.peek().kind == dependsDot {
+			p.next()
+			result := p.next()
+			if result.kind != dependsIdent {
+				return nil, p.errorf(result, "expected a task result after '.'")
+			}
+			if !slices.Contains(dependsResults, result.text) {
+				return nil, p.errorf(result, "unknown task result %q, want one of %s", result.text, strings.Join(dependsResults, ", "))
+			}
+			term.Result = result.text
+		}
+		p.addTerm(term)
+		return dependsRef{term}, nil
+
+	case dependsEOF:
+		return nil, p.errorf(tok, "unexpected end of expression")
+	}
+	return nil, p.errorf(tok, "unexpected %q", tok.text)
+}
+
+func (p *dependsParser) addTerm(term DependsTerm) {
+	for _, t := range p.terms {
+		if t == term {
+			return
+		}
+	}
+	p.terms = append(p.terms, term)
+}
@@ -0,0 +1,151 @@
+package workflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vjranagit/argo-workflows/pkg/expr"
+)
+
+func TestParseDepends(t *testing.T) {
+	d, err := ParseDepends("(build-a.Succeeded || test.Failed) && deploy && !build-a.Succeeded")
+	if err != nil {
+		t.Fatalf("ParseDepends() error = %v", err)
+	}
+
+	if got := strings.Join(d.Tasks(), " "); got != "build-a test deploy" {
+		t.Errorf("Tasks() = %v, want build-a test deploy", got)
+	}
+	var terms []string
+	for _, term := range d.Terms() {
+		terms = append(terms, term.String())
+	}
+	if got := strings.Join(terms, " "); got != "build-a.Succeeded test.Failed deploy" {
+		t.Errorf("Terms() = %v", got)
+	}
+}
+
+func TestParseDependsErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		pos  int
+		want string
+	}{
+		{"", 0, "empty expression"},
+		{"a &&", 4, "unexpected end of expression"},
+		{"(a || b", 7, "expected ')'"},
+		{"a.Finished", 2, `unknown task result "Finished"`},
+		{"a.", 2, "expected a task result"},
+		{"a & b", 2, "unexpected character '&'"},
+		{"a b", 2, `unexpected "b"`},
+	}
+	for _, tt := range tests {
+		_, err := ParseDepends(tt.src)
+		var serr *expr.SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("ParseDepends(%q) error = %v, want a syntax error", tt.src, err)
+			continue
+		}
+		if serr.Pos != tt.pos || !strings.Contains(serr.Msg, tt.want) {
+			t.Errorf("ParseDepends(%q) = %d %q, want %d %q", tt.src, serr.Pos, serr.Msg, tt.pos, tt.want)
+		}
+	}
+}
+
+func TestDependsEval(t *testing.T) {
+	results := map[string]TaskResult{
+		"ok":      {Phase: PhaseSucceeded},
+		"failed":  {Phase: PhaseFailed},
+		"errored": {Phase: PhaseError},
+		"skipped": {Phase: PhaseSkipped},
+		"omitted": {Phase: PhaseOmitted},
+		"daemon":  {Phase: PhaseRunning, Daemoned: true},
+		"some":    {Phase: PhaseFailed, Children: []string{PhaseFailed, PhaseSucceeded}},
+		"none":    {Phase: PhaseFailed, Children: []string{PhaseFailed, PhaseError}},
+	}
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"ok", true},
+		{"skipped && daemon", true},
+		{"failed", false},
+		{"failed.Failed && errored.Errored", true},
+		{"ok.Failed || omitted.Omitted", true},
+		{"skipped.Skipped && daemon.Daemoned", true},
+		{"!ok.Succeeded", false},
+		{"(ok.Failed || failed.Failed) && ok", true},
+		{"ok.Failed || failed.Failed && ok.Failed", false},
+		{"some.AnySucceeded && !some.AllFailed", true},
+		{"none.AnySucceeded", false},
+		{"none.AllFailed", true},
+		{"ok.AllFailed", false},
+	}
+	for _, tt := range tests {
+		d, err := ParseDepends(tt.src)
+		if err != nil {
+			t.Errorf("ParseDepends(%q) error = %v", tt.src, err)
+			continue
+		}
+		got, err := d.Eval(results)
+		if err != nil || got != tt.want {
+			t.Errorf("Eval(%q) = %v, %v, want %v", tt.src, got, err, tt.want)
+		}
+	}
+
+	d, _ := ParseDepends("ok && pending")
+	if _, err := d.Eval(results); err == nil || !strings.Contains(err.Error(), `"pending"`) {
+		t.Errorf("Eval() error = %v, want pending task error", err)
+	}
+}
+
+func TestDependsGraph(t *testing.T) {
+	tasks := []DAGTask{
+		{Name: "a", Depends: "c.Failed"},
+		{Name: "b", Depends: "a || missing"},
+		{Name: "c", Dependencies: []string{"b"}},
+	}
+
+	err := NewDependencyGraph(tasks).Validate()
+	var cycle *CycleError
+	var missing *MissingDependencyError
+	if !errors.As(err, &cycle) || strings.Join(cycle.Path, " ") != "a b c a" {
+		t.Errorf("Validate() = %v, want cycle a -> b -> c -> a", err)
+	}
+	if !errors.As(err, &missing) || missing.Task != "b" || missing.Dependency != "missing" {
+		t.Errorf("Validate() = %v, want missing dependency of b", err)
+	}
+
+	tasks = []DAGTask{
+		{Name: "a"},
+		{Name: "b", Dependencies: []string{"a"}},
+		{Name: "c", Depends: "a.Failed && b"},
+		{Name: "d", Dependencies: []string{"a", "c"}},
+	}
+	g := NewDependencyGraph(tasks)
+	levels, err := g.Levels()
+	if err != nil {
+		t.Fatalf("Levels() error = %v", err)
+	}
+	if len(levels) != 4 {
+		t.Errorf("Levels() = %v, want 4 levels", levels)
+	}
+
+	redundant, err := g.RedundantDependencies()
+	if err != nil {
+		t.Fatalf("RedundantDependencies() error = %v", err)
+	}
+	if len(redundant) != 1 || redundant[0] != (DependencyEdge{Task: "d", DependsOn: "a"}) {
+		t.Errorf("RedundantDependencies() = %v, want only d on a", redundant)
+	}
+
+	sub := g.Subgraph("b", "c").Tasks()
+	if sub[1].Depends != "" || strings.Join(sub[1].Dependencies, " ") != "b" {
+		t.Errorf("Subgraph() c = %+v, want a plain dependency on b", sub[1])
+	}
+	if sub = g.Subgraph("a", "b", "c").Tasks(); sub[2].Depends != "a.Failed && b" {
+		t.Errorf("Subgraph() c = %+v, want depends kept", sub[2])
+	}
+}
@@ -0,0 +1,457 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph is a renderable view of a DAG or Steps template with every nested
+// DAG or Steps call expanded in place. Nodes are named the way the executor
+// and the Argo controller name the nodes of a run, e.g. "wf.build.compile"
+// or "wf[1].test", so a WorkflowStatus can be laid over it.
+type Graph struct {
+	Name   string
+	Nodes  []GraphNode
+	Edges  []GraphEdge
+	Groups []GraphGroup
+}
+
+// GraphNode is a leaf of the graph: a task or step that runs a container or
+// script, or a templateRef call that is not expanded.
+type GraphNode struct {
+	ID       string
+	Label    string
+	Template string
+	Group    string // ID of the enclosing group, empty at the top level
+	Phase    string
+}
+
+// GraphEdge connects a node to a node that runs after it.
+type GraphEdge struct {
+	From string
+	To   string
+}
+
+// GraphGroup is a task or step whose DAG or Steps template has been
+// expanded; its nodes refer to it through GraphNode.Group.
+type GraphGroup struct {
+	ID       string
+	Label    string
+	Template string
+	Parent   string
+	Phase    string
+}
+
+// GraphOption is a functional option for graph construction.
+type GraphOption func(*graphBuilder)
+
+// WithPhases colors the graph by the phases of a run of the workflow.
+func WithPhases(status *WorkflowStatus) GraphOption {
+	return func(b *graphBuilder) {
+		b.status = status
+	}
+}
+
+// WithTemplateStore expands templateRef calls to DAG or Steps templates of
+// the WorkflowTemplates and ClusterWorkflowTemplates in store, the same way
+// calls to the workflow's own templates are expanded.
+func WithTemplateStore(store *TemplateStore) GraphOption {
+	return func(b *graphBuilder) {
+		b.store = store
+	}
+}
+
+// NewGraph builds the graph of the named DAG or Steps template of wf, or of
+// its entrypoint if template is empty. Calls to other DAG or Steps
+// templates are expanded; a template that calls itself, directly or
+// through others, is drawn as a single node where the recursion starts.
+// templateRef calls are only expanded if the graph is built
+// WithTemplateStore; otherwise each is drawn as a single node labelled with
+// the referenced template.
+func NewGraph(wf *Workflow, template string, opts ...GraphOption) (*Graph, error) {
+	if template == "" {
+		template = wf.Spec.Entrypoint
+	}
+	tmpl := findTemplate(wf.Spec.Templates, template)
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", template)
+	}
+	if tmpl.DAG == nil && tmpl.Steps == nil {
+		return nil, fmt.Errorf("template %q is neither a DAG nor a Steps template", template)
+	}
+
+	name := wf.Name
+	if name == "" {
+		name = template
+	}
+
+	b := &graphBuilder{
+		wf:        wf,
+		templates: wf.Spec.Templates,
+		graph:     &Graph{Name: name},
+		active:    map[string]bool{template: true},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if tmpl.DAG != nil {
+		b.dag(name, "", tmpl)
+	} else {
+		b.steps(name, "", tmpl)
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	b.applyPhases()
+	return b.graph, nil
+}
+
+func findTemplate(templates []Template, name string) *Template {
+	for i := range templates {
+		if templates[i].Name == name {
+			return &templates[i]
+		}
+	}
+	return nil
+}
+
+type graphBuilder struct {
+	wf     *Workflow
+	status *WorkflowStatus
+	store  *TemplateStore
+	graph  *Graph
+	active map[string]bool // templates being expanded, to stop recursion
+	err    error
+
+	// templates are those plain template names are looked up in: the
+	// workflow's, or those of the WorkflowTemplate being expanded. scope
+	// qualifies the names in active by the WorkflowTemplate they belong to.
+	templates []Template
+	scope     string
+}
+
+// call adds a task or step to the graph and returns the IDs of the nodes
+// that run first and last within it.
+func (b *graphBuilder) call(id, label, template string, ref *TemplateRef, looped bool, group string) (entries, exits []string) {
+	if looped {
+		label += " (loop)"
+	}
+	templates, scope, key := b.templates, b.scope, b.scope+template
+	if ref != nil {
+		template = ref.Name + "/" + ref.Template
+		scope = kindOf(ref.ClusterScope) + "/" + ref.Name + "/"
+		key = scope + ref.Template
+	}
+
+	var tmpl *Template
+	if !looped && !b.active[key] {
+		switch {
+		case ref == nil:
+			tmpl = findTemplate(b.templates, template)
+		case b.store != nil:
+			var spec *WorkflowSpec
+			var err error
+			if tmpl, spec, err = b.store.Template(ref); err != nil {
+				if b.err == nil {
+					b.err = fmt.Errorf("task or step %q: %w", label, err)
+				}
+			} else {
+				templates = spec.Templates
+			}
+		}
+	}
+	if tmpl == nil || (tmpl.DAG == nil && tmpl.Steps == nil) {
+		b.graph.Nodes = append(b.graph.Nodes, GraphNode{ID: id, Label: label, Template: template, Group: group})
+		return []string{id}, []string{id}
+	}
+
+	b.graph.Groups = append(b.graph.Groups, GraphGroup{ID: id, Label: label, Template: template, Parent: group})
+	b.active[key] = true
+	outer, outerScope := b.templates, b.scope
+	b.templates, b.scope = templates, scope
+	defer func() {
+		delete(b.active, key)
+		b.templates, b.scope = outer, outerScope
+	}()
+	if tmpl.DAG != nil {
+		return b.dag(id, id, tmpl)
+	}
+	return b.steps(id, id, tmpl)
+}
+
+func (b *graphBuilder) dag(prefix, group string, tmpl *Template) (entries, exits []string) {
+	tasks := tmpl.DAG.Tasks
+	if err := NewDependencyGraph(tasks).Validate(); err != nil && b.err == nil {
+		b.err = fmt.Errorf("template %q: %w", tmpl.Name, err)
+	}
+	first := make(map[string][]string, len(tasks))
+	last := make(map[string][]string, len(tasks))
+	for i := range tasks {
+		t := &tasks[i]
+		first[t.Name], last[t.Name] = b.call(prefix+"."+t.Name, t.Name, t.Template, t.TemplateRef, t.Looped(), group)
+	}
+
+	hasDependents := make(map[string]bool)
+	for i := range tasks {
+		t := &tasks[i]
+		deps := t.AllDependencies()
+		for _, dep := range deps {
+			hasDependents[dep] = true
+			b.connect(last[dep], first[t.Name])
+		}
+		if len(deps) == 0 {
+			entries = append(entries, first[t.Name]...)
+		}
+	}
+	for i := range tasks {
+		if !hasDependents[tasks[i].Name] {
+			exits = append(exits, last[tasks[i].Name]...)
+		}
+	}
+	return entries, exits
+}
+
+func (b *graphBuilder) steps(prefix, group string, tmpl *Template) (entries, exits []string) {
+	for i, parallel := range *tmpl.Steps {
+		var groupFirst, groupLast []string
+		for j := range parallel {
+			s := &parallel[j]
+			f, l := b.call(fmt.Sprintf("%s[%d].%s", prefix, i, s.Name), s.Name, s.Template, s.TemplateRef, s.Looped(), group)
+			groupFirst = append(groupFirst, f...)
+			groupLast = append(groupLast, l...)
+		}
+
+		if i == 0 {
+			entries = groupFirst
+		} else {
+			b.connect(exits, groupFirst)
+		}
+		exits = groupLast
+	}
+	return entries, exits
+}
+
+func (b *graphBuilder) connect(from, to []string) {
+	for _, f := range from {
+		for _, t := range to {
+			b.graph.Edges = append(b.graph.Edges, GraphEdge{From: f, To: t})
+		}
+	}
+}
+
+func (b *graphBuilder) applyPhases() {
+	if b.status == nil {
+		return
+	}
+
+	phases := make(map[string]string, len(b.status.Nodes))
+	for _, n := range b.status.Nodes {
+		phases[n.Name] = n.Phase
+	}
+	for i := range b.graph.Nodes {
+		b.graph.Nodes[i].Phase = phases[b.graph.Nodes[i].ID]
+	}
+	for i := range b.graph.Groups {
+		b.graph.Groups[i].Phase = phases[b.graph.Groups[i].ID]
+	}
+}
+
+// phaseColors are the fill colors used for each phase, matching the Argo
+// UI's palette.
+var phaseColors = map[string]string{
+	PhasePending:   "#f6c343",
+	PhaseRunning:   "#0dadea",
+	PhaseSucceeded: "#18be94",
+	PhaseFailed:    "#e96d76",
+	PhaseError:     "#e96d76",
+	PhaseSkipped:   "#cccccc",
+	PhaseOmitted:   "#cccccc",
+}
+
+// phaseSymbols are the markers the argo CLI prints for each phase.
+var phaseSymbols = map[string]string{
+	PhasePending:   "◷",
+	PhaseRunning:   "●",
+	PhaseSucceeded: "✔",
+	PhaseFailed:    "✖",
+	PhaseError:     "⚠",
+	PhaseSkipped:   "○",
+	PhaseOmitted:   "○",
+}
+
+// children returns the nodes and groups directly inside group.
+func (g *Graph) children(group string) ([]GraphNode, []GraphGroup) {
+	var nodes []GraphNode
+	for _, n := range g.Nodes {
+		if n.Group == group {
+			nodes = append(nodes, n)
+		}
+	}
+	var groups []GraphGroup
+	for _, sub := range g.Groups {
+		if sub.Parent == group {
+			groups = append(groups, sub)
+		}
+	}
+	return nodes, groups
+}
+
+// DOT renders the graph in Graphviz DOT format. Expanded calls are drawn as
+// clusters, and nodes are filled by phase if the graph was built
+// WithPhases.
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %s {\n", dotQuote(g.Name))
+	sb.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+	g.dotGroup(&sb, "", "  ")
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "  %s -> %s;\n", dotQuote(e.From), dotQuote(e.To))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func (g *Graph) dotGroup(sb *strings.Builder, group, indent string) {
+	nodes, groups := g.children(group)
+	for _, sub := range groups {
+		fmt.Fprintf(sb, "%ssubgraph %s {\n", indent, dotQuote("cluster_"+sub.ID))
+		fmt.Fprintf(sb, "%s  label=%s;\n", indent, dotQuote(sub.Label))
+		if color, ok := phaseColors[sub.Phase]; ok {
+			fmt.Fprintf(sb, "%s  color=%s;\n", indent, dotQuote(color))
+		}
+		g.dotGroup(sb, sub.ID, indent+"  ")
+		fmt.Fprintf(sb, "%s}\n", indent)
+	}
+	for _, n := range nodes {
+		attrs := "label=" + dotQuote(n.Label) + ", tooltip=" + dotQuote(n.Template)
+		if color, ok := phaseColors[n.Phase]; ok {
+			attrs += ", fillcolor=" + dotQuote(color)
+		}
+		fmt.Fprintf(sb, "%s%s [%s];\n", indent, dotQuote(n.ID), attrs)
+	}
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// Mermaid renders the graph as a Mermaid flowchart. Expanded calls are
+// drawn as subgraphs, and nodes are styled by phase if the graph was built
+// WithPhases.
+func (g *Graph) Mermaid() string {
+	// Mermaid IDs must be plain words, so nodes and groups are numbered.
+	ids := make(map[string]string, len(g.Nodes)+len(g.Groups))
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+	for i, sub := range g.Groups {
+		ids[sub.ID] = fmt.Sprintf("g%d", i)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	g.mermaidGroup(&sb, "", "  ", ids)
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "  %s --> %s\n", ids[e.From], ids[e.To])
+	}
+
+	byPhase := make(map[string][]string)
+	for _, n := range g.Nodes {
+		if _, ok := phaseColors[n.Phase]; ok {
+			byPhase[n.Phase] = append(byPhase[n.Phase], ids[n.ID])
+		}
+	}
+	phases := make([]string, 0, len(byPhase))
+	for phase := range byPhase {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	for _, phase := range phases {
+		fmt.Fprintf(&sb, "  classDef %s fill:%s\n", phase, phaseColors[phase])
+		fmt.Fprintf(&sb, "  class %s %s\n", strings.Join(byPhase[phase], ","), phase)
+	}
+	return sb.String()
+}
+
+func (g *Graph) mermaidGroup(sb *strings.Builder, group, indent string, ids map[string]string) {
+	nodes, groups := g.children(group)
+	for _, sub := range groups {
+		fmt.Fprintf(sb, "%ssubgraph %s [%s]\n", indent, ids[sub.ID], mermaidQuote(sub.Label))
+		g.mermaidGroup(sb, sub.ID, indent+"  ", ids)
+		fmt.Fprintf(sb, "%send\n", indent)
+	}
+	for _, n := range nodes {
+		fmt.Fprintf(sb, "%s%s[%s]\n", indent, ids[n.ID], mermaidQuote(n.Label))
+	}
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+// ASCII renders the graph for a terminal as numbered waves of nodes that
+// can run in parallel, each with the nodes it waits for. Nodes are named
+// relative to the graph, and marked with the argo CLI's phase symbols if
+// the graph was built WithPhases.
+func (g *Graph) ASCII() string {
+	deps := make(map[string][]string, len(g.Nodes))
+	for _, e := range g.Edges {
+		deps[e.To] = append(deps[e.To], e.From)
+	}
+
+	// A node's wave is one after the latest wave it waits for. DAG tasks
+	// need not be declared in execution order, so waves are relaxed until
+	// they settle, which takes at most one pass per node.
+	level := make(map[string]int, len(g.Nodes))
+	for pass := 0; pass < len(g.Nodes); pass++ {
+		changed := false
+		for _, e := range g.Edges {
+			if level[e.To] < level[e.From]+1 {
+				level[e.To] = level[e.From] + 1
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var waves [][]GraphNode
+	for _, n := range g.Nodes {
+		for len(waves) <= level[n.ID] {
+			waves = append(waves, nil)
+		}
+		waves[level[n.ID]] = append(waves[level[n.ID]], n)
+	}
+
+	name := func(id string) string {
+		if rel := strings.TrimPrefix(id, g.Name); rel != id {
+			return strings.TrimPrefix(rel, ".")
+		}
+		return id
+	}
+
+	var sb strings.Builder
+	sb.WriteString(g.Name + "\n")
+	for i, wave := range waves {
+		prefix := fmt.Sprintf("%3d: ", i+1)
+		for _, n := range wave {
+			line := name(n.ID)
+			if symbol, ok := phaseSymbols[n.Phase]; ok {
+				line = symbol + " " + line
+			}
+			if len(deps[n.ID]) > 0 {
+				from := make([]string, len(deps[n.ID]))
+				for k, d := range deps[n.ID] {
+					from[k] = name(d)
+				}
+				line += " <- " + strings.Join(from, ", ")
+			}
+			sb.WriteString(prefix + line + "\n")
+			prefix = "     "
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,224 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func graphWorkflow(t *testing.T) *Workflow {
+	t.Helper()
+
+	wf, err := New("release").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"))).
+		WithTemplate(NewSteps("verify").
+			Step("unit", "echo").
+			Step("lint", "echo").
+			Parallel("vet", "echo").
+			Build()).
+		WithTemplate(NewDAG("main").
+			Task("build", "echo").
+			Task("check", "verify", WithDependencies("build")).
+			Task("publish", "echo", WithDependencies("check"), WithItems("linux", "darwin")).
+			Build()).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return wf
+}
+
+func TestNewGraph(t *testing.T) {
+	g, err := NewGraph(graphWorkflow(t), "")
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+
+	var nodes []string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	want := "release.build release.check[0].unit release.check[1].lint release.check[1].vet release.publish"
+	if strings.Join(nodes, " ") != want {
+		t.Errorf("nodes = %v, want %v", strings.Join(nodes, " "), want)
+	}
+
+	var edges []string
+	for _, e := range g.Edges {
+		edges = append(edges, e.From+">"+e.To)
+	}
+	want = "release.check[0].unit>release.check[1].lint release.check[0].unit>release.check[1].vet " +
+		"release.build>release.check[0].unit release.check[1].lint>release.publish release.check[1].vet>release.publish"
+	if strings.Join(edges, " ") != want {
+		t.Errorf("edges = %v, want %v", strings.Join(edges, " "), want)
+	}
+
+	if len(g.Groups) != 1 || g.Groups[0].ID != "release.check" || g.Groups[0].Template != "verify" {
+		t.Errorf("groups = %+v, want release.check", g.Groups)
+	}
+
+	if _, err := NewGraph(graphWorkflow(t), "echo"); err == nil {
+		t.Error("NewGraph() should reject a container template")
+	}
+}
+
+func TestGraphRecursion(t *testing.T) {
+	wf := &Workflow{
+		Spec: WorkflowSpec{
+			Templates: []Template{
+				NewSteps("loop").
+					Step("again", "loop", WithStepCondition("{{inputs.parameters.n}} > 0")).
+					Build(),
+			},
+		},
+	}
+
+	g, err := NewGraph(wf, "loop")
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+	if len(g.Nodes) != 1 || g.Nodes[0].ID != "loop[0].again" || len(g.Groups) != 0 {
+		t.Errorf("graph = %+v, want a single node", g)
+	}
+}
+
+func TestGraphTemplateRef(t *testing.T) {
+	lib, err := New("lib").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"))).
+		WithTemplate(NewSteps("inner").Step("say", "echo").Build()).
+		WithTemplate(NewDAG("pair").
+			Task("first", "inner").
+			Task("second", "echo", WithDependencies("first")).
+			Build()).
+		BuildWorkflowTemplate()
+	if err != nil {
+		t.Fatalf("BuildWorkflowTemplate failed: %v", err)
+	}
+	wf, err := New("ref").
+		WithEntrypoint("main").
+		WithTemplate(NewDAG("main").
+			Task("pair", "", WithTemplateRef("lib", "pair")).
+			Build()).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	g, err := NewGraph(wf, "")
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+	if len(g.Nodes) != 1 || g.Nodes[0].ID != "ref.pair" || g.Nodes[0].Template != "lib/pair" {
+		t.Errorf("graph without a store = %+v, want a single lib/pair node", g.Nodes)
+	}
+
+	g, err = NewGraph(wf, "", WithTemplateStore(NewTemplateStore().Add(lib)))
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+	var nodes []string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	if want := "ref.pair.first[0].say ref.pair.second"; strings.Join(nodes, " ") != want {
+		t.Errorf("nodes = %v, want %v", strings.Join(nodes, " "), want)
+	}
+	if len(g.Edges) != 1 || g.Edges[0].From != "ref.pair.first[0].say" || g.Edges[0].To != "ref.pair.second" {
+		t.Errorf("edges = %+v, want first[0].say > second", g.Edges)
+	}
+	if len(g.Groups) != 2 || g.Groups[0].Template != "lib/pair" || g.Groups[1].Template != "inner" {
+		t.Errorf("groups = %+v, want lib/pair and inner", g.Groups)
+	}
+
+	_, err = NewGraph(wf, "", WithTemplateStore(NewTemplateStore()))
+	if err == nil || !strings.Contains(err.Error(), `WorkflowTemplate "lib" not found`) {
+		t.Errorf("NewGraph() error = %v, want WorkflowTemplate not found", err)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	status := &WorkflowStatus{Nodes: map[string]Node{
+		"a": {Name: "release.build", Phase: PhaseSucceeded},
+		"b": {Name: "release.check", Phase: PhaseFailed},
+		"c": {Name: "release.check[0].unit", Phase: PhaseFailed},
+	}}
+	g, err := NewGraph(graphWorkflow(t), "main", WithPhases(status))
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+
+	want := `digraph "release" {
+  node [shape=box, style="rounded,filled", fillcolor=white];
+  subgraph "cluster_release.check" {
+    label="check";
+    color="#e96d76";
+    "release.check[0].unit" [label="unit", tooltip="echo", fillcolor="#e96d76"];
+    "release.check[1].lint" [label="lint", tooltip="echo"];
+    "release.check[1].vet" [label="vet", tooltip="echo"];
+  }
+  "release.build" [label="build", tooltip="echo", fillcolor="#18be94"];
+  "release.publish" [label="publish (loop)", tooltip="echo"];
+  "release.check[0].unit" -> "release.check[1].lint";
+  "release.check[0].unit" -> "release.check[1].vet";
+  "release.build" -> "release.check[0].unit";
+  "release.check[1].lint" -> "release.publish";
+  "release.check[1].vet" -> "release.publish";
+}
+`
+	if got := g.DOT(); got != want {
+		t.Errorf("DOT() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGraphMermaid(t *testing.T) {
+	status := &WorkflowStatus{Nodes: map[string]Node{
+		"a": {Name: "release.build", Phase: PhaseSucceeded},
+		"b": {Name: "release.publish", Phase: PhaseSucceeded},
+	}}
+	g, err := NewGraph(graphWorkflow(t), "main", WithPhases(status))
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+
+	want := `flowchart TD
+  subgraph g0 ["check"]
+    n1["unit"]
+    n2["lint"]
+    n3["vet"]
+  end
+  n0["build"]
+  n4["publish (loop)"]
+  n1 --> n2
+  n1 --> n3
+  n0 --> n1
+  n2 --> n4
+  n3 --> n4
+  classDef Succeeded fill:#18be94
+  class n0,n4 Succeeded
+`
+	if got := g.Mermaid(); got != want {
+		t.Errorf("Mermaid() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGraphASCII(t *testing.T) {
+	status := &WorkflowStatus{Nodes: map[string]Node{
+		"a": {Name: "release.build", Phase: PhaseSucceeded},
+		"b": {Name: "release.check[0].unit", Phase: PhaseRunning},
+	}}
+	g, err := NewGraph(graphWorkflow(t), "main", WithPhases(status))
+	if err != nil {
+		t.Fatalf("NewGraph failed: %v", err)
+	}
+
+	want := `release
+  1: ✔ build
+  2: ● check[0].unit <- build
+  3: check[1].lint <- check[0].unit
+     check[1].vet <- check[0].unit
+  4: publish <- check[1].lint, check[1].vet
+`
+	if got := g.ASCII(); got != want {
+		t.Errorf("ASCII() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,304 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Looped reports whether the task fans out over withItems, withParam or
+// withSequence.
+func (t *DAGTask) Looped() bool {
+	return t.WithItems != nil || t.WithParam != "" || t.WithSequence != nil
+}
+
+// Expand returns one task per loop item, named the way the Argo controller
+// names expanded tasks, e.g. "print(0:hello)". {{item}} references are
+// substituted throughout the task, including artifact locations and hooks;
+// every other reference is left for the caller to resolve. withParam and
+// withSequence values are resolved from scope.
+func (t *DAGTask) Expand(scope Scope) ([]DAGTask, error) {
+	items, err := loopItems(t.WithItems, t.WithParam, t.WithSequence, scope)
+	if err != nil {
+		return nil, fmt.Errorf("task %q: %w", t.Name, err)
+	}
+
+	tasks := make([]DAGTask, len(items))
+	for i, item := range items {
+		task := *t
+		task.WithItems, task.WithParam, task.WithSequence = nil, "", nil
+		substituteItem(&task, item)
+		task.Name = ItemName(t.Name, i, item)
+		tasks[i] = task
+	}
+	return tasks, nil
+}
+
+// Looped reports whether the step fans out over withItems, withParam or
+// withSequence.
+func (s *StepGroup) Looped() bool {
+	return s.WithItems != nil || s.WithParam != "" || s.WithSequence != nil
+}
+
+// Expand returns one step per loop item; see DAGTask.Expand.
+func (s *StepGroup) Expand(scope Scope) ([]StepGroup, error) {
+	items, err := loopItems(s.WithItems, s.WithParam, s.WithSequence, scope)
+	if err != nil {
+		return nil, fmt.Errorf("step %q: %w", s.Name, err)
+	}
+
+	steps := make([]StepGroup, len(items))
+	for i, item := range items {
+		step := *s
+		step.WithItems, step.WithParam, step.WithSequence = nil, "", nil
+		substituteItem(&step, item)
+		step.Name = ItemName(s.Name, i, item)
+		steps[i] = step
+	}
+	return steps, nil
+}
+
+// ItemName returns the name of the index'th expansion of a looped task or
+// step. Map items are described by their key:value pairs in key order, e.g.
+// "deploy(1:arch:amd64,os:linux)".
+func ItemName(name string, index int, item interface{}) string {
+	desc := FormatValue(item)
+	if m, ok := item.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = k + ":" + FormatValue(m[k])
+		}
+		desc = strings.Join(pairs, ",")
+	}
+	return fmt.Sprintf("%s(%d:%s)", name, index, desc)
+}
+
+// SequenceCount returns a sequence of count numbers starting at 0.
+func SequenceCount(count int) *Sequence {
+	c := intstr.FromInt(count)
+	return &Sequence{Count: &c}
+}
+
+// SequenceRange returns a sequence of the numbers from start to end
+// inclusive.
+func SequenceRange(start, end int) *Sequence {
+	s, e := intstr.FromInt(start), intstr.FromInt(end)
+	return &Sequence{Start: &s, End: &e}
+}
+
+// WithFormat sets a printf-style format for the numbers, e.g. "test-%02d".
+func (seq *Sequence) WithFormat(format string) *Sequence {
+	seq.Format = format
+	return seq
+}
+
+// Items returns the values of the sequence, formatted with Format or as
+// plain integers. Placeholders in Count, Start and End are resolved from
+// scope. The sequence counts down when End is less than Start.
+func (seq *Sequence) Items(scope Scope) ([]interface{}, error) {
+	if seq.Count != nil && seq.End != nil {
+		return nil, fmt.Errorf("only one of count or end can be defined in withSequence")
+	}
+
+	start, err := sequenceValue(seq.Start, "start", scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var end int
+	switch {
+	case seq.Count != nil:
+		count, err := sequenceValue(seq.Count, "count", scope)
+		if err != nil {
+			return nil, err
+		}
+		if count <= 0 {
+			return []interface{}{}, nil
+		}
+		end = start + count - 1
+	case seq.End != nil:
+		if end, err = sequenceValue(seq.End, "end", scope); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("either count or end must be defined in withSequence")
+	}
+
+	format := seq.Format
+	if format == "" {
+		format = "%d"
+	}
+
+	step := 1
+	if end < start {
+		step = -1
+	}
+
+	items := make([]interface{}, 0, (end-start)*step+1)
+	for i := start; ; i += step {
+		items = append(items, fmt.Sprintf(format, i))
+		if i == end {
+			break
+		}
+	}
+	return items, nil
+}
+
+func sequenceValue(v *intstr.IntOrString, name string, scope Scope) (int, error) {
+	if v == nil {
+		return 0, nil
+	}
+	if v.Type == intstr.Int {
+		return v.IntValue(), nil
+	}
+
+	s, missing := scope.Replace(v.StrVal)
+	if len(missing) > 0 {
+		return 0, fmt.Errorf("withSequence %s: unresolved reference {{%s}}", name, missing[0])
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("withSequence %s %q is not an integer", name, s)
+	}
+	return n, nil
+}
+
+// loopItems returns the items of whichever loop source is set.
+func loopItems(withItems []interface{}, withParam string, seq *Sequence, scope Scope) ([]interface{}, error) {
+	switch {
+	case withItems != nil:
+		return withItems, nil
+	case withParam != "":
+		value, missing := scope.Replace(withParam)
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("withParam: unresolved reference {{%s}}", missing[0])
+		}
+		var items []interface{}
+		if err := json.Unmarshal([]byte(value), &items); err != nil {
+			return nil, fmt.Errorf("withParam value could not be parsed as a JSON list: %s", value)
+		}
+		return items, nil
+	case seq != nil:
+		return seq.Items(scope)
+	}
+	return nil, nil
+}
+
+// substituteItem replaces {{item}} and {{item.<key>}} in every string of
+// the task or step v points to: its arguments, including each artifact's
+// path and location, its hooks and its when condition. Like the Argo
+// controller, which substitutes the item across the whole task, all other
+// references are left in place. Slices, maps and pointers are copied, so v
+// shares nothing with the task it was copied from.
+func substituteItem(v interface{}, item interface{}) {
+	scope := NewScope().SetItem(item)
+	dst := reflect.ValueOf(v).Elem()
+	dst.Set(scope.replaceDeep(dst))
+}
+
+// replaceDeep returns a deep copy of v with the references in scope replaced
+// in each string it holds.
+func (s Scope) replaceDeep(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		str, _ := s.Replace(v.String())
+		out := reflect.New(v.Type()).Elem()
+		out.SetString(str)
+		return out
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(s.replaceDeep(v.Elem()))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(s.replaceDeep(v.Elem()))
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := out.Field(i); f.CanSet() {
+				f.Set(s.replaceDeep(v.Field(i)))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(s.replaceDeep(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			out.SetMapIndex(iter.Key(), s.replaceDeep(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
+
+// AggregateOutputs combines the outputs of the expansions of a looped task
+// or step the way Argo exposes them to later tasks: outputs.result and each
+// outputs.parameters.<name> become a JSON list with one entry per item.
+func AggregateOutputs(outputs []*Outputs) *Outputs {
+	var results []string
+	values := make(map[string][]string)
+	var names []string
+
+	for _, o := range outputs {
+		if o == nil {
+			continue
+		}
+		if o.Result != "" {
+			results = append(results, o.Result)
+		}
+		for _, p := range o.Parameters {
+			if _, seen := values[p.Name]; !seen {
+				names = append(names, p.Name)
+			}
+			values[p.Name] = append(values[p.Name], FormatValue(p.Value))
+		}
+	}
+
+	if results == nil && names == nil {
+		return nil
+	}
+
+	agg := &Outputs{}
+	if results != nil {
+		agg.Result = FormatValue(results)
+	}
+	for _, name := range names {
+		agg.Parameters = append(agg.Parameters, Parameter{Name: name, Value: FormatValue(values[name])})
+	}
+	return agg
+}
+
+// isItemReference reports whether a placeholder refers to the loop item.
+func isItemReference(name string) bool {
+	return name == "item" || strings.HasPrefix(name, "item.")
+}
@@ -0,0 +1,204 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestDAGTaskExpand(t *testing.T) {
+	task := DAGTask{
+		Name:     "print",
+		Template: "echo",
+		When:     "{{item.os}} != windows",
+		Arguments: NewArguments().
+			AddParameter(Parameter{Name: "message", Value: "{{item.os}}-{{workflow.name}}"}),
+		WithItems: []interface{}{
+			map[string]interface{}{"os": "linux", "arch": "amd64"},
+			map[string]interface{}{"os": "windows", "arch": "arm64"},
+		},
+	}
+
+	got, err := task.Expand(NewScope())
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expand() = %d tasks, want 2", len(got))
+	}
+
+	if got[0].Name != "print(0:arch:amd64,os:linux)" {
+		t.Errorf("Name = %v, want print(0:arch:amd64,os:linux)", got[0].Name)
+	}
+	if got[1].When != "windows != windows" {
+		t.Errorf("When = %v, want 'windows != windows'", got[1].When)
+	}
+	if got[0].Arguments.Parameters[0].Value != "linux-{{workflow.name}}" {
+		t.Errorf("message = %v, want linux-{{workflow.name}}", got[0].Arguments.Parameters[0].Value)
+	}
+	if got[0].Looped() {
+		t.Error("expanded task should not loop")
+	}
+	if task.Arguments.Parameters[0].Value != "{{item.os}}-{{workflow.name}}" {
+		t.Errorf("original task was modified: %v", task.Arguments.Parameters[0].Value)
+	}
+}
+
+func TestExpandArtifactItem(t *testing.T) {
+	step := StepGroup{
+		Name:     "load",
+		Template: "process",
+		Arguments: NewArguments().
+			AddParameter(Parameter{Name: "region", Default: "{{item}}-1"}).
+			AddArtifact(Artifact{Name: "data", S3: &S3Artifact{Bucket: "in", Key: "data/{{item}}.csv"}}),
+		Hooks: LifecycleHooks{ExitHook: {
+			Template:  "notify",
+			Arguments: NewArguments().AddArtifact(Artifact{Name: "log", Raw: &RawArtifact{Data: "{{item}} {{workflow.name}}"}}),
+		}},
+		WithItems: []interface{}{"eu", "us"},
+	}
+
+	got, err := step.Expand(NewScope())
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+
+	if key := got[1].Arguments.Artifacts[0].S3.Key; key != "data/us.csv" {
+		t.Errorf("s3 key = %v, want data/us.csv", key)
+	}
+	if def := got[1].Arguments.Parameters[0].Default; def != "us-1" {
+		t.Errorf("default = %v, want us-1", def)
+	}
+	if data := got[0].Hooks[ExitHook].Arguments.Artifacts[0].Raw.Data; data != "eu {{workflow.name}}" {
+		t.Errorf("hook raw data = %v, want 'eu {{workflow.name}}'", data)
+	}
+	if key := step.Arguments.Artifacts[0].S3.Key; key != "data/{{item}}.csv" {
+		t.Errorf("original step was modified: %v", key)
+	}
+}
+
+func TestStepExpandParam(t *testing.T) {
+	step := StepGroup{Name: "process", Template: "worker", WithParam: "{{steps.list.outputs.result}}"}
+	scope := NewScope().SetStepOutputs("list", &Outputs{Result: `["a", 2, true]`})
+
+	got, err := step.Expand(scope)
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+
+	names := make([]string, len(got))
+	for i, s := range got {
+		names[i] = s.Name
+	}
+	if strings.Join(names, " ") != "process(0:a) process(1:2) process(2:true)" {
+		t.Errorf("names = %v", names)
+	}
+
+	scope.SetStepOutputs("list", &Outputs{Result: "not json"})
+	if _, err := step.Expand(scope); err == nil || !strings.Contains(err.Error(), "JSON list") {
+		t.Errorf("Expand() error = %v, want JSON list error", err)
+	}
+
+	if _, err := step.Expand(NewScope()); err == nil {
+		t.Error("Expand() should fail on an unresolved withParam")
+	}
+}
+
+func TestSequenceItems(t *testing.T) {
+	str := func(s string) *intstr.IntOrString {
+		v := intstr.FromString(s)
+		return &v
+	}
+
+	tests := []struct {
+		name string
+		seq  *Sequence
+		want string
+	}{
+		{"count", SequenceCount(3), "0,1,2"},
+		{"range", SequenceRange(2, 4), "2,3,4"},
+		{"descending", SequenceRange(3, 1), "3,2,1"},
+		{"format", SequenceCount(2).WithFormat("test-%02d"), "test-00,test-01"},
+		{"empty", SequenceCount(0), ""},
+		{"placeholder", &Sequence{Start: str("5"), Count: str("{{inputs.parameters.n}}")}, "5,6"},
+	}
+
+	scope := NewScope().SetInputs(map[string]string{"n": "2"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := tt.seq.Items(scope)
+			if err != nil {
+				t.Fatalf("Items() error = %v", err)
+			}
+			got := make([]string, len(items))
+			for i, item := range items {
+				got[i] = item.(string)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("Items() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	bad := SequenceCount(2)
+	bad.End = str("4")
+	if _, err := bad.Items(scope); err == nil {
+		t.Error("Items() should reject count together with end")
+	}
+}
+
+func TestAggregateOutputs(t *testing.T) {
+	got := AggregateOutputs([]*Outputs{
+		{Result: "a", Parameters: []Parameter{{Name: "size", Value: "1"}}},
+		nil,
+		{Result: "b", Parameters: []Parameter{{Name: "size", Value: "2"}}},
+	})
+
+	if got.Result != `["a","b"]` {
+		t.Errorf("Result = %v, want [\"a\",\"b\"]", got.Result)
+	}
+	if len(got.Parameters) != 1 || got.Parameters[0].Value != `["1","2"]` {
+		t.Errorf("Parameters = %v, want size=[\"1\",\"2\"]", got.Parameters)
+	}
+
+	if AggregateOutputs([]*Outputs{nil}) != nil {
+		t.Error("AggregateOutputs() of no outputs should be nil")
+	}
+}
+
+func TestValidateLoops(t *testing.T) {
+	seq := SequenceCount(3).WithFormat("%s-%s")
+	seq.End = seq.Count
+
+	wf := &Workflow{
+		Spec: WorkflowSpec{
+			Entrypoint: "main",
+			Templates: []Template{
+				ContainerTemplate("echo", WithImage("alpine:3.18")),
+				NewDAG("main").
+					Task("looped", "echo", WithItems("a", "b"), WithCondition("{{item}} == a")).
+					Task("plain", "echo", WithArguments(NewArguments().AddParameter(Parameter{Name: "m", Value: "{{item}}"}))).
+					Task("both", "echo", WithItems("a"), WithParam("[]")).
+					Task("seq", "echo", WithSequence(seq)).
+					Build(),
+			},
+		},
+	}
+	wf.Name = "loops"
+
+	var got []string
+	for _, err := range Validate(wf) {
+		got = append(got, err.Field)
+	}
+
+	want := []string{
+		"spec.templates[1].dag.tasks[1].arguments.parameters[0].value",
+		"spec.templates[1].dag.tasks[2]",
+		"spec.templates[1].dag.tasks[3].withSequence",
+		"spec.templates[1].dag.tasks[3].withSequence.format",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Validate() fields = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,373 @@
+package workflow
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Object is implemented by every kind the manifest loader understands:
+// *Workflow, *WorkflowTemplate, *ClusterWorkflowTemplate and *CronWorkflow.
+type Object interface {
+	metav1.Object
+	GetObjectKind() schema.ObjectKind
+	ToYAML() ([]byte, error)
+}
+
+// Manifest is one object decoded from a manifest, together with where it
+// came from.
+type Manifest struct {
+	Source string // file name, or the name given to LoadReader
+	Index  int    // zero-based position of the document in Source
+	Kind   string
+	Object Object
+}
+
+// Location returns where the manifest was found, e.g. "wf.yaml#3".
+func (m *Manifest) Location() string {
+	return location(m.Source, m.Index)
+}
+
+// ManifestError reports a document that could not be decoded.
+type ManifestError struct {
+	Source string
+	Index  int
+	Err    error
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("%s: %v", location(e.Source, e.Index), e.Err)
+}
+
+func (e *ManifestError) Unwrap() error {
+	return e.Err
+}
+
+func location(source string, index int) string {
+	return fmt.Sprintf("%s#%d", source, index)
+}
+
+// manifestExtensions are the file extensions Load picks up when walking a
+// directory.
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// LoadOptions configures how manifests are decoded.
+type LoadOptions struct {
+	// Strict rejects fields the Go types do not define and duplicate keys,
+	// reporting each unknown field with its path; see FromYAMLStrict.
+	Strict bool
+}
+
+// Load reads every manifest in a file or, if path is a directory, in every
+// .yaml, .yml and .json file below it in lexical order. Hidden directories
+// such as .git are skipped.
+//
+// Unlike FromYAML, which decodes exactly one Workflow, files may hold any
+// number of "---"-separated YAML documents or JSON values of any supported
+// kind. Documents that fail to decode are reported together as
+// *ManifestError values joined into the returned error; the manifests that
+// did decode are returned alongside it.
+func Load(path string) ([]Manifest, error) {
+	return LoadOptions{}.Load(path)
+}
+
+// LoadFile reads every manifest in a single file; see Load.
+func LoadFile(filename string) ([]Manifest, error) {
+	return LoadOptions{}.LoadFile(filename)
+}
+
+// LoadReader reads every manifest from r; see Load. source names r in
+// Manifest.Source and in errors, e.g. "<stdin>".
+func LoadReader(r io.Reader, source string) ([]Manifest, error) {
+	return LoadOptions{}.LoadReader(r, source)
+}
+
+// Load is like the package-level Load, decoding with o.
+func (o LoadOptions) Load(path string) ([]Manifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+	if !info.IsDir() {
+		return o.LoadFile(path)
+	}
+
+	var manifests []Manifest
+	var errs []error
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !manifestExtensions[strings.ToLower(filepath.Ext(p))] {
+			return nil
+		}
+
+		m, err := o.LoadFile(p)
+		manifests = append(manifests, m...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", path, err)
+	}
+
+	return manifests, errors.Join(errs...)
+}
+
+// LoadFile is like the package-level LoadFile, decoding with o.
+func (o LoadOptions) LoadFile(filename string) ([]Manifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	return o.LoadReader(f, filename)
+}
+
+// LoadReader is like the package-level LoadReader, decoding with o.
+func (o LoadOptions) LoadReader(r io.Reader, source string) ([]Manifest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read data: %w", err)
+	}
+
+	docs, err := splitDocuments(data)
+	if err != nil {
+		return nil, &ManifestError{Source: source, Index: len(docs), Err: err}
+	}
+
+	var manifests []Manifest
+	var errs []error
+	for i, doc := range docs {
+		if isEmptyDocument(doc) {
+			continue
+		}
+
+		kind, obj, err := o.decodeObject(doc)
+		if err != nil {
+			errs = append(errs, &ManifestError{Source: source, Index: i, Err: err})
+			continue
+		}
+		manifests = append(manifests, Manifest{
+			Source: source,
+			Index:  i,
+			Kind:   kind,
+			Object: obj,
+		})
+	}
+
+	return manifests, errors.Join(errs...)
+}
+
+// splitDocuments splits a YAML stream on "---" lines or, if it is JSON, into
+// its top-level values. A top-level JSON array contributes one document per
+// element.
+func splitDocuments(data []byte) ([][]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return splitJSON(trimmed)
+	}
+	return splitYAML(data), nil
+}
+
+func splitJSON(data []byte) ([][]byte, error) {
+	var docs [][]byte
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return docs, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		var items []json.RawMessage
+		if raw[0] == '[' && json.Unmarshal(raw, &items) == nil {
+			for _, item := range items {
+				docs = append(docs, item)
+			}
+			continue
+		}
+		docs = append(docs, raw)
+	}
+}
+
+// splitYAML splits a YAML stream on "---" lines. Whatever precedes the first
+// separator is only a document if it holds more than comments, so that a
+// leading "---" does not shift the index of every document after it.
+func splitYAML(data []byte) [][]byte {
+	var docs [][]byte
+	var current bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isDocumentSeparator(line) {
+			docs = append(docs, append([]byte(nil), current.Bytes()...))
+			current.Reset()
+			continue
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	docs = append(docs, current.Bytes())
+	if len(docs) > 1 && isEmptyDocument(docs[0]) {
+		docs = docs[1:]
+	}
+	return docs
+}
+
+// isDocumentSeparator reports whether a line starts a new YAML document,
+// allowing a trailing comment as in "--- # next".
+func isDocumentSeparator(line string) bool {
+	if !strings.HasPrefix(line, "---") {
+		return false
+	}
+	rest := strings.TrimSpace(line[3:])
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// isEmptyDocument reports whether a document holds nothing but comments and
+// whitespace, as before a leading "---".
+func isEmptyDocument(doc []byte) bool {
+	for _, line := range strings.Split(string(doc), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && line != "..." {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeObject decodes a single document into the Go type for its kind.
+func (o LoadOptions) decodeObject(doc []byte) (string, Object, error) {
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(doc, &meta); err != nil {
+		return "", nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	var obj Object
+	switch meta.Kind {
+	case "Workflow":
+		obj = &Workflow{}
+	case "WorkflowTemplate":
+		obj = &WorkflowTemplate{}
+	case "ClusterWorkflowTemplate":
+		obj = &ClusterWorkflowTemplate{}
+	case "CronWorkflow":
+		obj = &CronWorkflow{}
+	case "":
+		return "", nil, fmt.Errorf("kind is required")
+	default:
+		return "", nil, fmt.Errorf("unsupported kind: %s", meta.Kind)
+	}
+
+	if o.Strict {
+		if err := checkStrict(doc, reflect.TypeOf(obj)); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := yaml.Unmarshal(doc, obj); err != nil {
+		return "", nil, fmt.Errorf("unmarshal %s: %w", meta.Kind, err)
+	}
+	return meta.Kind, obj, nil
+}
+
+// kindOrder is the order in which WriteBundle emits kinds: templates before
+// the objects that may reference them.
+var kindOrder = map[string]int{
+	"ClusterWorkflowTemplate": 0,
+	"WorkflowTemplate":        1,
+	"CronWorkflow":            2,
+	"Workflow":                3,
+}
+
+// WriteBundle writes objects to w as a multi-document YAML bundle that
+// Load reads back. The output is stable: objects are ordered by kind, with
+// templates before the objects that reference them, then by namespace and
+// name, and fields are emitted in key order. Objects that compare equal,
+// such as workflows named only through generateName, keep their given
+// order.
+func WriteBundle(w io.Writer, objects ...Object) error {
+	docs := make([][]byte, len(objects))
+	for i, obj := range objects {
+		// ToYAML also sets the kind used for ordering below.
+		data, err := obj.ToYAML()
+		if err != nil {
+			return err
+		}
+		docs[i] = data
+	}
+
+	order := make([]int, len(objects))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := objects[order[a]], objects[order[b]]
+		kx, ky := kindOrder[x.GetObjectKind().GroupVersionKind().Kind], kindOrder[y.GetObjectKind().GroupVersionKind().Kind]
+		if kx != ky {
+			return kx < ky
+		}
+		if x.GetNamespace() != y.GetNamespace() {
+			return x.GetNamespace() < y.GetNamespace()
+		}
+		return x.GetName() < y.GetName()
+	})
+
+	for n, i := range order {
+		if n > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return fmt.Errorf("write bundle: %w", err)
+			}
+		}
+		if _, err := w.Write(docs[i]); err != nil {
+			return fmt.Errorf("write bundle: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteBundleFile writes objects to a file as a multi-document YAML bundle;
+// see WriteBundle.
+func WriteBundleFile(filename string, objects ...Object) error {
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, objects...); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
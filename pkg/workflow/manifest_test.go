@@ -0,0 +1,181 @@
+package workflow
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const multiDoc = `# leading comment
+---
+apiVersion: argoproj.io/v1alpha1
+kind: WorkflowTemplate
+metadata:
+  name: lib
+spec:
+  templates:
+  - name: echo
+    container:
+      image: alpine:3.18
+--- # the workflow
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: run-
+spec:
+  workflowTemplateRef:
+    name: lib
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`
+
+func TestLoadReader(t *testing.T) {
+	manifests, err := LoadReader(strings.NewReader(multiDoc), "bundle.yaml")
+
+	var merr *ManifestError
+	if !errors.As(err, &merr) || merr.Index != 2 || !strings.Contains(err.Error(), "bundle.yaml#2: unsupported kind: ConfigMap") {
+		t.Errorf("error = %v, want unsupported kind at bundle.yaml#2", err)
+	}
+
+	if len(manifests) != 2 {
+		t.Fatalf("got %d manifests, want 2", len(manifests))
+	}
+	if manifests[0].Location() != "bundle.yaml#0" || manifests[0].Kind != "WorkflowTemplate" {
+		t.Errorf("manifests[0] = %s %s", manifests[0].Location(), manifests[0].Kind)
+	}
+	wf, ok := manifests[1].Object.(*Workflow)
+	if !ok || wf.GenerateName != "run-" || wf.Spec.WorkflowTemplateRef.Name != "lib" {
+		t.Errorf("manifests[1] = %+v, want the workflow", manifests[1].Object)
+	}
+}
+
+func TestLoadReaderLeadingSeparator(t *testing.T) {
+	doc := "kind: Workflow\nmetadata:\n  name: once\n"
+	for _, data := range []string{doc, "---\n" + doc, "# comment\n--- # first\n" + doc} {
+		manifests, err := LoadReader(strings.NewReader(data), "f.yaml")
+		if err != nil || len(manifests) != 1 || manifests[0].Location() != "f.yaml#0" {
+			t.Errorf("LoadReader(%q) = %+v, %v, want f.yaml#0", data, manifests, err)
+		}
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	data := `[
+  {"apiVersion": "argoproj.io/v1alpha1", "kind": "CronWorkflow", "metadata": {"name": "nightly"}, "spec": {"schedule": "@daily", "workflowSpec": {"entrypoint": "main"}}},
+  {"apiVersion": "argoproj.io/v1alpha1", "kind": "Workflow", "metadata": {"name": "once"}, "spec": {"entrypoint": "main"}}
+]
+{"kind": "Workflow", "metadata": {"name": "twice"}, "spec": {"entrypoint": "main"}}
+`
+	manifests, err := LoadReader(strings.NewReader(data), "<stdin>")
+	if err != nil {
+		t.Fatalf("LoadReader() error = %v", err)
+	}
+
+	var got []string
+	for _, m := range manifests {
+		got = append(got, m.Location()+" "+m.Object.GetName())
+	}
+	want := "<stdin>#0 nightly|<stdin>#1 once|<stdin>#2 twice"
+	if strings.Join(got, "|") != want {
+		t.Errorf("manifests = %v, want %v", strings.Join(got, "|"), want)
+	}
+	if cwf := manifests[0].Object.(*CronWorkflow); cwf.Spec.Schedule != "@daily" {
+		t.Errorf("schedule = %q, want @daily", cwf.Spec.Schedule)
+	}
+
+	if _, err := LoadReader(strings.NewReader(`{"kind": "Workflow"} {`), "bad.json"); err == nil || !strings.Contains(err.Error(), "bad.json#1") {
+		t.Errorf("error = %v, want invalid JSON at bad.json#1", err)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("b.yaml", "kind: Workflow\nmetadata:\n  name: b\n")
+	write("nested/a.json", `{"kind": "Workflow", "metadata": {"name": "a"}}`)
+	write("notes.txt", "kind: Workflow\n")
+	write(".git/config.yaml", "kind: Workflow\n")
+	write("z.yml", "kind: Workflow\nmetadata: [\n")
+
+	manifests, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "z.yml")+"#0") {
+		t.Errorf("error = %v, want z.yml#0", err)
+	}
+
+	var got []string
+	for _, m := range manifests {
+		rel, _ := filepath.Rel(dir, m.Source)
+		got = append(got, rel+"="+m.Object.GetName())
+	}
+	if want := "b.yaml=b nested/a.json=a"; strings.Join(got, " ") != want {
+		t.Errorf("manifests = %v, want %v", got, want)
+	}
+
+	single, err := Load(filepath.Join(dir, "b.yaml"))
+	if err != nil || len(single) != 1 {
+		t.Errorf("Load(file) = %v, %v", single, err)
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	wf, err := New("run").WithWorkflowTemplateRef("lib").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	lib, err := New("lib").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"))).
+		BuildWorkflowTemplate()
+	if err != nil {
+		t.Fatalf("BuildWorkflowTemplate() error = %v", err)
+	}
+	cwft, err := New("shared").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"))).
+		BuildClusterWorkflowTemplate()
+	if err != nil {
+		t.Fatalf("BuildClusterWorkflowTemplate() error = %v", err)
+	}
+
+	var first, second bytes.Buffer
+	if err := WriteBundle(&first, wf, lib, cwft); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	if err := WriteBundle(&second, lib, cwft, wf); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("bundle depends on argument order:\n%s\nvs\n%s", first.String(), second.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.yaml")
+	if err := WriteBundleFile(path, wf, lib, cwft); err != nil {
+		t.Fatalf("WriteBundleFile() error = %v", err)
+	}
+	manifests, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var kinds []string
+	for _, m := range manifests {
+		kinds = append(kinds, m.Kind)
+	}
+	if want := "ClusterWorkflowTemplate WorkflowTemplate Workflow"; strings.Join(kinds, " ") != want {
+		t.Errorf("kinds = %v, want %v", kinds, want)
+	}
+}
@@ -0,0 +1,102 @@
+package workflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Memoize caches the outputs of a template under a key, so that a later
+// invocation with the same key reuses them instead of running again.
+// The key usually refers to the template's inputs, e.g.
+// "{{inputs.parameters.dataset}}".
+type Memoize struct {
+	Key    string            `json:"key"`
+	Cache  *MemoizationCache `json:"cache"`
+	MaxAge string            `json:"maxAge,omitempty"` // e.g., "24h"; empty never expires
+}
+
+// MemoizationCache names the cache memoized outputs are stored in. The Argo
+// controller keeps each cache in a ConfigMap of that name.
+type MemoizationCache struct {
+	ConfigMap *ConfigMapKeySelector `json:"configMap"`
+}
+
+// WithMemoize caches a template's outputs under key in the named cache.
+// Cached outputs older than maxAge are ignored; an empty maxAge keeps them
+// forever.
+func WithMemoize(key, cache, maxAge string) TemplateOption {
+	return func(t *Template) {
+		t.Memoize = &Memoize{
+			Key:    key,
+			Cache:  &MemoizationCache{ConfigMap: &ConfigMapKeySelector{Name: cache}},
+			MaxAge: maxAge,
+		}
+	}
+}
+
+// templateFingerprint is the part of a template that determines what it
+// computes.
+type templateFingerprint struct {
+	Image      string                 `json:"image,omitempty"`
+	Command    []string               `json:"command,omitempty"`
+	Args       []string               `json:"args,omitempty"`
+	Source     string                 `json:"source,omitempty"`
+	WorkingDir string                 `json:"workingDir,omitempty"`
+	Env        []EnvVar               `json:"env,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Artifacts  map[string]Artifact    `json:"artifacts,omitempty"`
+}
+
+// Fingerprint returns a stable hash of what a template runs: the image,
+// command, args, source, working directory and environment of its container
+// or script, and its input parameter values and artifacts. Names, retry
+// strategies, timeouts and other settings that do not change the result are
+// left out, and input order does not matter.
+//
+// The template should be fully substituted, as returned by
+// Resolver.Resolve, so that invocations with the same inputs share a
+// fingerprint. Memoized results are looked up by Memoize.Key alone, in the
+// local executor as in the controller, and nothing computes a fingerprint
+// on its own: callers that want results to change with what a template
+// runs must derive the key from Fingerprint themselves.
+func Fingerprint(t *Template) (string, error) {
+	var fp templateFingerprint
+	switch {
+	case t.Container != nil:
+		c := t.Container
+		fp.Image, fp.Command, fp.Args, fp.WorkingDir, fp.Env = c.Image, c.Command, c.Args, c.WorkingDir, c.Env
+	case t.Script != nil:
+		s := t.Script
+		fp.Image, fp.Command, fp.Source, fp.WorkingDir, fp.Env = s.Image, s.Command, s.Source, s.WorkingDir, s.Env
+	}
+
+	if t.Inputs != nil {
+		if len(t.Inputs.Parameters) > 0 {
+			fp.Parameters = make(map[string]interface{}, len(t.Inputs.Parameters))
+			for _, p := range t.Inputs.Parameters {
+				value := p.Value
+				if value == nil {
+					value = p.Default
+				}
+				fp.Parameters[p.Name] = value
+			}
+		}
+		if len(t.Inputs.Artifacts) > 0 {
+			fp.Artifacts = make(map[string]Artifact, len(t.Inputs.Artifacts))
+			for _, a := range t.Inputs.Artifacts {
+				fp.Artifacts[a.Name] = a
+			}
+		}
+	}
+
+	// encoding/json writes struct fields in order and map keys sorted, so
+	// equal fingerprints encode to equal bytes.
+	data, err := json.Marshal(fp)
+	if err != nil {
+		return "", fmt.Errorf("fingerprint template %q: %w", t.Name, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
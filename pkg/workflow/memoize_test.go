@@ -0,0 +1,104 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	prep := func(opts ...interface{}) *Template {
+		tmpl := ContainerTemplate("prep", append([]interface{}{
+			WithImage("python:3.11"),
+			WithCommand("python", "prep.py"),
+			WithArgs("--dataset", "sales"),
+			WithInputs(NewInputs().
+				AddParameter(Parameter{Name: "dataset", Value: "sales"}).
+				AddParameter(Parameter{Name: "rows", Default: "100"})),
+		}, opts...)...)
+		return &tmpl
+	}
+
+	base, err := Fingerprint(prep())
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	if len(base) != 64 {
+		t.Errorf("Fingerprint() = %q, want a hex SHA-256", base)
+	}
+
+	same := prep(WithRetryStrategy(3, RetryPolicyAlways), WithTimeout("1h"))
+	same.Name = "other"
+	same.Inputs.Parameters[0], same.Inputs.Parameters[1] = same.Inputs.Parameters[1], same.Inputs.Parameters[0]
+	if got, _ := Fingerprint(same); got != base {
+		t.Errorf("Fingerprint() changed with the name, retry strategy, timeout or input order")
+	}
+
+	changed := map[string]*Template{
+		"image":     prep(WithImage("python:3.12")),
+		"args":      prep(WithArgs("--dataset", "returns")),
+		"parameter": prep(WithInputs(NewInputs().AddParameter(Parameter{Name: "dataset", Value: "returns"}))),
+		"artifact":  prep(WithInputs(NewInputs().AddArtifact(Artifact{Name: "raw", Path: "/tmp/raw"}))),
+	}
+	for name, tmpl := range changed {
+		if got, _ := Fingerprint(tmpl); got == base {
+			t.Errorf("Fingerprint() did not change with the %s", name)
+		}
+	}
+
+	script := ScriptTemplate("prep", WithScriptImage("python:3.11"), WithSource("print(1)"))
+	other := ScriptTemplate("prep", WithScriptImage("python:3.11"), WithSource("print(2)"))
+	a, _ := Fingerprint(&script)
+	b, _ := Fingerprint(&other)
+	if a == b {
+		t.Error("Fingerprint() did not change with the script source")
+	}
+
+	bad := prep(WithInputs(NewInputs().AddParameter(Parameter{Name: "f", Value: func() {}})))
+	if _, err := Fingerprint(bad); err == nil || !strings.Contains(err.Error(), `fingerprint template "prep"`) {
+		t.Errorf("Fingerprint() error = %v, want an encoding error", err)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	wf, err := New("memo").
+		WithEntrypoint("prep").
+		WithTemplate(ContainerTemplate("prep",
+			WithImage("python:3.11"),
+			WithInputs(NewInputs().AddParameter(Parameter{Name: "dataset", Default: "sales"})),
+			WithMemoize("prep-{{inputs.parameters.dataset}}", "prep-cache", "24h"))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	data, err := wf.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML failed: %v", err)
+	}
+	for _, want := range []string{"memoize:", "key: prep-{{inputs.parameters.dataset}}", "name: prep-cache", "maxAge: 24h"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("YAML missing %q:\n%s", want, data)
+		}
+	}
+	loaded, err := FromYAMLStrict(data)
+	if err != nil {
+		t.Fatalf("FromYAMLStrict failed: %v", err)
+	}
+	if m := loaded.Spec.Templates[0].Memoize; m == nil || m.Cache.ConfigMap.Name != "prep-cache" || m.MaxAge != "24h" {
+		t.Errorf("Memoize = %+v, want it to round-trip", m)
+	}
+
+	_, err = New("memo").
+		WithEntrypoint("prep").
+		WithTemplate(ContainerTemplate("prep", WithImage("python:3.11"), WithMemoize("", "Prep_Cache", "soon"))).
+		Build()
+	for _, want := range []string{
+		"spec.templates[0].memoize.key: Required value",
+		`spec.templates[0].memoize.cache.configMap.name: Invalid value: "Prep_Cache"`,
+		`spec.templates[0].memoize.maxAge: Invalid value: "soon"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Build() error = %v, want %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package workflow
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The types below mirror the parts of the Kubernetes pod spec that Argo
+// lets workflows and templates set. A template's settings override the
+// workflow's for the pods it creates.
+
+// Metadata holds labels and annotations added to a pod.
+type Metadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Toleration lets a pod be scheduled onto nodes with a matching taint.
+// An empty Key with the Exists operator tolerates every taint.
+type Toleration struct {
+	Key               string `json:"key,omitempty"`
+	Operator          string `json:"operator,omitempty"` // Exists or Equal (default)
+	Value             string `json:"value,omitempty"`
+	Effect            string `json:"effect,omitempty"` // NoSchedule, PreferNoSchedule, NoExecute; empty matches all
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
+
+// Toleration operators and taint effects.
+const (
+	TolerationOpExists = "Exists"
+	TolerationOpEqual  = "Equal"
+
+	TaintEffectNoSchedule       = "NoSchedule"
+	TaintEffectPreferNoSchedule = "PreferNoSchedule"
+	TaintEffectNoExecute        = "NoExecute"
+)
+
+// Affinity constrains which nodes a pod runs on, relative to node labels
+// and to other pods.
+type Affinity struct {
+	NodeAffinity    *NodeAffinity `json:"nodeAffinity,omitempty"`
+	PodAffinity     *PodAffinity  `json:"podAffinity,omitempty"`
+	PodAntiAffinity *PodAffinity  `json:"podAntiAffinity,omitempty"`
+}
+
+// NodeAffinity selects nodes by their labels and fields.
+type NodeAffinity struct {
+	RequiredDuringSchedulingIgnoredDuringExecution  *NodeSelector             `json:"requiredDuringSchedulingIgnoredDuringExecution,omitempty"`
+	PreferredDuringSchedulingIgnoredDuringExecution []PreferredSchedulingTerm `json:"preferredDuringSchedulingIgnoredDuringExecution,omitempty"`
+}
+
+// NodeSelector matches a node if any of its terms matches.
+type NodeSelector struct {
+	NodeSelectorTerms []NodeSelectorTerm `json:"nodeSelectorTerms"`
+}
+
+// NodeSelectorTerm matches a node if all of its requirements match.
+type NodeSelectorTerm struct {
+	MatchExpressions []NodeSelectorRequirement `json:"matchExpressions,omitempty"`
+	MatchFields      []NodeSelectorRequirement `json:"matchFields,omitempty"`
+}
+
+// NodeSelectorRequirement compares a node label or field with Values.
+type NodeSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"` // In, NotIn, Exists, DoesNotExist, Gt, Lt
+	Values   []string `json:"values,omitempty"`
+}
+
+// Node selector operators.
+const (
+	NodeSelectorOpIn           = "In"
+	NodeSelectorOpNotIn        = "NotIn"
+	NodeSelectorOpExists       = "Exists"
+	NodeSelectorOpDoesNotExist = "DoesNotExist"
+	NodeSelectorOpGt           = "Gt"
+	NodeSelectorOpLt           = "Lt"
+)
+
+// PreferredSchedulingTerm is a node selector term with a weight from 1 to
+// 100 added to the score of matching nodes.
+type PreferredSchedulingTerm struct {
+	Weight     int32            `json:"weight"`
+	Preference NodeSelectorTerm `json:"preference"`
+}
+
+// PodAffinity places a pod near, or for anti-affinity away from, pods
+// matching its terms.
+type PodAffinity struct {
+	RequiredDuringSchedulingIgnoredDuringExecution  []PodAffinityTerm         `json:"requiredDuringSchedulingIgnoredDuringExecution,omitempty"`
+	PreferredDuringSchedulingIgnoredDuringExecution []WeightedPodAffinityTerm `json:"preferredDuringSchedulingIgnoredDuringExecution,omitempty"`
+}
+
+// PodAffinityTerm selects pods by label within a topology domain, such as
+// a node or a zone.
+type PodAffinityTerm struct {
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	Namespaces    []string              `json:"namespaces,omitempty"`
+	TopologyKey   string                `json:"topologyKey"`
+}
+
+// WeightedPodAffinityTerm is a pod affinity term with a weight from 1 to
+// 100.
+type WeightedPodAffinityTerm struct {
+	Weight          int32           `json:"weight"`
+	PodAffinityTerm PodAffinityTerm `json:"podAffinityTerm"`
+}
+
+// PodSecurityContext holds security settings shared by all containers of
+// a pod.
+type PodSecurityContext struct {
+	RunAsUser          *int64  `json:"runAsUser,omitempty"`
+	RunAsGroup         *int64  `json:"runAsGroup,omitempty"`
+	RunAsNonRoot       *bool   `json:"runAsNonRoot,omitempty"`
+	FSGroup            *int64  `json:"fsGroup,omitempty"`
+	SupplementalGroups []int64 `json:"supplementalGroups,omitempty"`
+}
+
+// SecurityContext holds security settings of a single container. They
+// take precedence over the pod's.
+type SecurityContext struct {
+	RunAsUser                *int64        `json:"runAsUser,omitempty"`
+	RunAsGroup               *int64        `json:"runAsGroup,omitempty"`
+	RunAsNonRoot             *bool         `json:"runAsNonRoot,omitempty"`
+	Privileged               *bool         `json:"privileged,omitempty"`
+	ReadOnlyRootFilesystem   *bool         `json:"readOnlyRootFilesystem,omitempty"`
+	AllowPrivilegeEscalation *bool         `json:"allowPrivilegeEscalation,omitempty"`
+	Capabilities             *Capabilities `json:"capabilities,omitempty"`
+}
+
+// Capabilities adds and drops Linux capabilities, e.g. "NET_ADMIN".
+type Capabilities struct {
+	Add  []string `json:"add,omitempty"`
+	Drop []string `json:"drop,omitempty"`
+}
+
+// Volume is a volume pods of the workflow can mount. Exactly one source
+// must be set.
+type Volume struct {
+	Name                  string                             `json:"name"`
+	EmptyDir              *EmptyDirVolumeSource              `json:"emptyDir,omitempty"`
+	ConfigMap             *ConfigMapVolumeSource             `json:"configMap,omitempty"`
+	Secret                *SecretVolumeSource                `json:"secret,omitempty"`
+	PersistentVolumeClaim *PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+}
+
+// EmptyDirVolumeSource is a scratch directory that lives as long as the
+// pod. Medium "Memory" backs it with tmpfs.
+type EmptyDirVolumeSource struct {
+	Medium    string `json:"medium,omitempty"`
+	SizeLimit string `json:"sizeLimit,omitempty"` // e.g., "1Gi"
+}
+
+// ConfigMapVolumeSource exposes the keys of a ConfigMap as files.
+type ConfigMapVolumeSource struct {
+	Name     string      `json:"name"`
+	Items    []KeyToPath `json:"items,omitempty"`
+	Optional *bool       `json:"optional,omitempty"`
+}
+
+// SecretVolumeSource exposes the keys of a Secret as files.
+type SecretVolumeSource struct {
+	SecretName string      `json:"secretName"`
+	Items      []KeyToPath `json:"items,omitempty"`
+	Optional   *bool       `json:"optional,omitempty"`
+}
+
+// KeyToPath maps a ConfigMap or Secret key to a file path in the volume.
+type KeyToPath struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+}
+
+// PersistentVolumeClaimVolumeSource mounts an existing claim.
+type PersistentVolumeClaimVolumeSource struct {
+	ClaimName string `json:"claimName"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// PersistentVolumeClaim is a claim created for each run of a workflow.
+// Templates mount it like a volume of the same name, which lets steps
+// share files.
+type PersistentVolumeClaim struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              PersistentVolumeClaimSpec `json:"spec"`
+}
+
+// PersistentVolumeClaimSpec describes the storage a claim requests.
+type PersistentVolumeClaimSpec struct {
+	AccessModes      []string  `json:"accessModes,omitempty"`
+	StorageClassName *string   `json:"storageClassName,omitempty"`
+	Resources        Resources `json:"resources"` // requests.storage is required
+}
+
+// Persistent volume access modes.
+const (
+	ReadWriteOnce    = "ReadWriteOnce"
+	ReadOnlyMany     = "ReadOnlyMany"
+	ReadWriteMany    = "ReadWriteMany"
+	ReadWriteOncePod = "ReadWriteOncePod"
+)
+
+// VolumeMount mounts a volume, declared by the workflow or the template,
+// into a container.
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	SubPath   string `json:"subPath,omitempty"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// EmptyDirVolume declares a scratch volume that lives as long as the pod.
+func EmptyDirVolume(name string) Volume {
+	return Volume{Name: name, EmptyDir: &EmptyDirVolumeSource{}}
+}
+
+// ConfigMapVolume declares a volume holding the keys of a ConfigMap as
+// files.
+func ConfigMapVolume(name, configMap string) Volume {
+	return Volume{Name: name, ConfigMap: &ConfigMapVolumeSource{Name: configMap}}
+}
+
+// SecretVolume declares a volume holding the keys of a Secret as files.
+func SecretVolume(name, secret string) Volume {
+	return Volume{Name: name, Secret: &SecretVolumeSource{SecretName: secret}}
+}
+
+// PVCVolume declares a volume backed by an existing claim.
+func PVCVolume(name, claim string) Volume {
+	return Volume{Name: name, PersistentVolumeClaim: &PersistentVolumeClaimVolumeSource{ClaimName: claim}}
+}
+
+// VolumeClaimTemplate returns a claim for size of storage, e.g. "1Gi",
+// with the given access modes, ReadWriteOnce if none.
+func VolumeClaimTemplate(name, size string, accessModes ...string) PersistentVolumeClaim {
+	if len(accessModes) == 0 {
+		accessModes = []string{ReadWriteOnce}
+	}
+	return PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: PersistentVolumeClaimSpec{
+			AccessModes: accessModes,
+			Resources:   Resources{Requests: ResourceList{"storage": size}},
+		},
+	}
+}
+
+// WithNodeSelector restricts a template's pods to nodes with the given
+// labels.
+func WithNodeSelector(labels map[string]string) TemplateOption {
+	return func(t *Template) {
+		t.NodeSelector = labels
+	}
+}
+
+// WithToleration lets a template's pods run on nodes with a matching
+// taint.
+func WithToleration(toleration Toleration) TemplateOption {
+	return func(t *Template) {
+		t.Tolerations = append(t.Tolerations, toleration)
+	}
+}
+
+// WithAffinity sets the scheduling affinity of a template's pods.
+func WithAffinity(affinity *Affinity) TemplateOption {
+	return func(t *Template) {
+		t.Affinity = affinity
+	}
+}
+
+// WithPodSecurityContext sets the security context of a template's pods.
+func WithPodSecurityContext(sc *PodSecurityContext) TemplateOption {
+	return func(t *Template) {
+		t.SecurityContext = sc
+	}
+}
+
+// WithPodSpecPatch merges a JSON or YAML patch into the pod spec of a
+// template's pods, for pod fields this package does not model.
+func WithPodSpecPatch(patch string) TemplateOption {
+	return func(t *Template) {
+		t.PodSpecPatch = patch
+	}
+}
+
+// WithPriorityClassName sets the priority class of a template's pods.
+func WithPriorityClassName(name string) TemplateOption {
+	return func(t *Template) {
+		t.PriorityClassName = name
+	}
+}
+
+// WithServiceAccountName runs a template's pods under a different service
+// account than the workflow's.
+func WithServiceAccountName(name string) TemplateOption {
+	return func(t *Template) {
+		t.ServiceAccountName = name
+	}
+}
+
+// WithPodMetadata adds labels and annotations to a template's pods.
+func WithPodMetadata(labels, annotations map[string]string) TemplateOption {
+	return func(t *Template) {
+		t.Metadata = &Metadata{Labels: labels, Annotations: annotations}
+	}
+}
+
+// WithTemplateVolumes declares volumes only the template's pods use.
+func WithTemplateVolumes(volumes ...Volume) TemplateOption {
+	return func(t *Template) {
+		t.Volumes = append(t.Volumes, volumes...)
+	}
+}
+
+// WithSecurityContext sets the security context of the container.
+func WithSecurityContext(sc *SecurityContext) ContainerOption {
+	return func(c *Container) {
+		c.SecurityContext = sc
+	}
+}
+
+// WithVolumeMounts mounts volumes into the container.
+func WithVolumeMounts(mounts ...VolumeMount) ContainerOption {
+	return func(c *Container) {
+		c.VolumeMounts = append(c.VolumeMounts, mounts...)
+	}
+}
+
+// WithScriptVolumeMounts mounts volumes into the script container.
+func WithScriptVolumeMounts(mounts ...VolumeMount) ScriptOption {
+	return func(s *Script) {
+		s.VolumeMounts = append(s.VolumeMounts, mounts...)
+	}
+}
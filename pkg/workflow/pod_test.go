@@ -0,0 +1,226 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPodSettings(t *testing.T) {
+	nonRoot := true
+	user := int64(1000)
+	wf, err := New("train").
+		WithEntrypoint("fit").
+		WithNodeSelector(map[string]string{"pool": "batch"}).
+		WithToleration(Toleration{Key: "dedicated", Operator: TolerationOpEqual, Value: "batch", Effect: TaintEffectNoSchedule}).
+		WithSecurityContext(&PodSecurityContext{RunAsNonRoot: &nonRoot, RunAsUser: &user}).
+		WithPodMetadata(map[string]string{"team": "ml"}, nil).
+		WithVolumes(Volume{Name: "data", PersistentVolumeClaim: &PersistentVolumeClaimVolumeSource{ClaimName: "datasets"}}).
+		WithTemplate(ContainerTemplate("fit",
+			WithImage("python:3.11"),
+			WithVolumeMounts(
+				VolumeMount{Name: "data", MountPath: "/data", ReadOnly: true},
+				VolumeMount{Name: "scratch", MountPath: "/scratch"}),
+			WithSecurityContext(&SecurityContext{Capabilities: &Capabilities{Drop: []string{"ALL"}}}),
+			WithTemplateVolumes(Volume{Name: "scratch", EmptyDir: &EmptyDirVolumeSource{SizeLimit: "1Gi"}}),
+			WithNodeSelector(map[string]string{"accelerator": "gpu"}),
+			WithAffinity(&Affinity{NodeAffinity: &NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &NodeSelector{NodeSelectorTerms: []NodeSelectorTerm{{
+					MatchExpressions: []NodeSelectorRequirement{{Key: "zone", Operator: NodeSelectorOpIn, Values: []string{"a", "b"}}},
+				}}},
+			}}),
+			WithPriorityClassName("high"),
+			WithServiceAccountName("trainer"),
+			WithPodSpecPatch(`{"hostNetwork": true}`))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	tmpl := wf.Spec.Templates[0]
+	if tmpl.NodeSelector["accelerator"] != "gpu" || wf.Spec.NodeSelector["pool"] != "batch" {
+		t.Errorf("node selectors = %v and %v, want both set", wf.Spec.NodeSelector, tmpl.NodeSelector)
+	}
+	if len(tmpl.Container.VolumeMounts) != 2 || len(tmpl.Volumes) != 1 {
+		t.Errorf("template volumes = %+v, mounts = %+v", tmpl.Volumes, tmpl.Container.VolumeMounts)
+	}
+
+	data, err := wf.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML failed: %v", err)
+	}
+	for _, want := range []string{
+		"nodeSelector:\n    pool: batch",
+		"tolerations:\n  - effect: NoSchedule",
+		"securityContext:\n    runAsNonRoot: true",
+		"podMetadata:\n    labels:\n      team: ml",
+		"persistentVolumeClaim:\n      claimName: datasets",
+		"requiredDuringSchedulingIgnoredDuringExecution:",
+		"priorityClassName: high",
+		"serviceAccountName: trainer",
+		"mountPath: /data",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("YAML missing %q:\n%s", want, data)
+		}
+	}
+	loaded, err := FromYAMLStrict(data)
+	if err != nil {
+		t.Fatalf("FromYAMLStrict failed: %v", err)
+	}
+	if sc := loaded.Spec.SecurityContext; sc == nil || *sc.RunAsUser != 1000 {
+		t.Errorf("securityContext = %+v, want it to round-trip", sc)
+	}
+	if c := loaded.Spec.Templates[0].Container; c.SecurityContext == nil || c.SecurityContext.Capabilities.Drop[0] != "ALL" {
+		t.Errorf("container securityContext = %+v, want it to round-trip", c.SecurityContext)
+	}
+}
+
+func TestValidatePodSettings(t *testing.T) {
+	yes, no := true, false
+	root, grace := int64(0), int64(30)
+	wf := &Workflow{}
+	wf.Name = "pods"
+	wf.Spec.Entrypoint = "main"
+	wf.Spec.NodeSelector = map[string]string{"pool": "batch jobs"}
+	wf.Spec.Tolerations = []Toleration{
+		{Operator: TolerationOpEqual, Value: "x"},
+		{Key: "gpu", Operator: TolerationOpExists, Value: "true"},
+		{Key: "spot", Operator: "Like", Effect: "Never", TolerationSeconds: &grace},
+	}
+	wf.Spec.SecurityContext = &PodSecurityContext{RunAsNonRoot: &yes, RunAsUser: &root}
+	wf.Spec.PodSpecPatch = "{hostNetwork"
+	wf.Spec.Volumes = []Volume{
+		{Name: "data", EmptyDir: &EmptyDirVolumeSource{}},
+		{Name: "data", EmptyDir: &EmptyDirVolumeSource{}, Secret: &SecretVolumeSource{SecretName: "creds"}},
+	}
+	wf.Spec.Templates = []Template{
+		ContainerTemplate("main",
+			WithImage("alpine:3.18"),
+			WithSecurityContext(&SecurityContext{Privileged: &yes, AllowPrivilegeEscalation: &no}),
+			WithVolumeMounts(
+				VolumeMount{Name: "data", MountPath: "data"},
+				VolumeMount{Name: "cache", MountPath: "/cache", SubPath: "../etc"}),
+			WithTemplateVolumes(Volume{Name: "config", ConfigMap: &ConfigMapVolumeSource{Name: "cfg", Items: []KeyToPath{{Key: "a", Path: "/a"}}}}),
+			WithAffinity(&Affinity{
+				NodeAffinity: &NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &NodeSelector{NodeSelectorTerms: []NodeSelectorTerm{{
+						MatchExpressions: []NodeSelectorRequirement{
+							{Key: "zone", Operator: NodeSelectorOpIn},
+							{Key: "cores", Operator: NodeSelectorOpGt, Values: []string{"many"}},
+							{Key: "gpu", Operator: NodeSelectorOpExists, Values: []string{"yes"}},
+						},
+					}}},
+					PreferredDuringSchedulingIgnoredDuringExecution: []PreferredSchedulingTerm{{Weight: 0}},
+				},
+				PodAntiAffinity: &PodAffinity{RequiredDuringSchedulingIgnoredDuringExecution: []PodAffinityTerm{{}}},
+			}),
+			WithPriorityClassName("High"),
+			WithPodMetadata(map[string]string{"bad key": "v"}, nil)),
+	}
+
+	errs := Validate(wf).ToAggregate().Error()
+	for _, want := range []string{
+		`spec.nodeSelector[pool]: Invalid value: "batch jobs"`,
+		`spec.tolerations[0].operator: Invalid value: "Equal": must be Exists when key is empty`,
+		`spec.tolerations[1].value: Invalid value: "true": must be empty when operator is Exists`,
+		`spec.tolerations[2].operator: Unsupported value: "Like"`,
+		`spec.tolerations[2].effect: Unsupported value: "Never"`,
+		"spec.tolerations[2].tolerationSeconds: Forbidden: may only be set for the NoExecute effect",
+		"spec.securityContext.runAsNonRoot: Invalid value: true: cannot be true when runAsUser is 0",
+		`spec.podSpecPatch: Invalid value: "{hostNetwork"`,
+		`spec.volumes[1].name: Duplicate value: "data"`,
+		"spec.volumes[1]: Forbidden: exactly one of emptyDir, configMap, secret or persistentVolumeClaim must be set, got emptyDir, secret",
+		"spec.templates[0].container.securityContext.allowPrivilegeEscalation: Invalid value: false",
+		`spec.templates[0].container.volumeMounts[0].mountPath: Invalid value: "data": must be an absolute path`,
+		`spec.templates[0].container.volumeMounts[1].name: Not found: "cache"`,
+		`spec.templates[0].container.volumeMounts[1].subPath: Invalid value: "../etc"`,
+		`spec.templates[0].volumes[0].configMap.items[0].path: Invalid value: "/a": must be a relative path`,
+		"spec.templates[0].affinity.nodeAffinity.requiredDuringSchedulingIgnoredDuringExecution.nodeSelectorTerms[0].matchExpressions[0].values: Required value",
+		`nodeSelectorTerms[0].matchExpressions[1].values[0]: Invalid value: "many": must be an integer`,
+		"nodeSelectorTerms[0].matchExpressions[2].values: Forbidden",
+		"spec.templates[0].affinity.nodeAffinity.preferredDuringSchedulingIgnoredDuringExecution[0].weight: Invalid value: 0: must be between 1 and 100",
+		"spec.templates[0].affinity.podAntiAffinity.requiredDuringSchedulingIgnoredDuringExecution[0].topologyKey: Required value",
+		`spec.templates[0].priorityClassName: Invalid value: "High"`,
+		`spec.templates[0].metadata.labels[bad key]: Invalid value: "bad key"`,
+	} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errors missing %q:\n%s", want, errs)
+		}
+	}
+
+	// A WorkflowTemplate's templates may mount volumes its users declare.
+	wft := &WorkflowTemplate{}
+	wft.Name = "shared"
+	wft.Spec.Templates = []Template{
+		ContainerTemplate("main", WithImage("alpine:3.18"), WithVolumeMounts(VolumeMount{Name: "workdir", MountPath: "/work"})),
+	}
+	if errs := ValidateWorkflowTemplate(wft); len(errs) != 0 {
+		t.Errorf("ValidateWorkflowTemplate() = %v, want no errors", errs)
+	}
+}
+
+func TestVolumes(t *testing.T) {
+	wf, err := New("etl").
+		WithEntrypoint("main").
+		WithVolumeClaimTemplates(VolumeClaimTemplate("workdir", "5Gi", ReadWriteMany)).
+		WithVolumes(ConfigMapVolume("config", "etl-config"), SecretVolume("creds", "etl-creds")).
+		WithTemplate(ScriptTemplate("main",
+			WithScriptImage("python:3.11"),
+			WithSource("print(1)"),
+			WithScriptVolumeMounts(
+				VolumeMount{Name: "workdir", MountPath: "/work"},
+				VolumeMount{Name: "config", MountPath: "/etc/etl", ReadOnly: true}))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	data, err := wf.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML failed: %v", err)
+	}
+	for _, want := range []string{
+		"volumeClaimTemplates:\n  - metadata:\n      creationTimestamp: null\n      name: workdir\n    spec:\n      accessModes:\n      - ReadWriteMany\n      resources:\n        requests:\n          storage: 5Gi",
+		"configMap:\n      name: etl-config",
+		"secret:\n      secretName: etl-creds",
+		"volumeMounts:\n      - mountPath: /work\n        name: workdir",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("YAML missing %q:\n%s", want, data)
+		}
+	}
+	loaded, err := FromYAMLStrict(data)
+	if err != nil {
+		t.Fatalf("FromYAMLStrict failed: %v", err)
+	}
+	if claims := loaded.Spec.VolumeClaimTemplates; len(claims) != 1 || claims[0].Name != "workdir" {
+		t.Errorf("VolumeClaimTemplates = %+v, want workdir to round-trip", claims)
+	}
+
+	_, err = New("etl").
+		WithEntrypoint("main").
+		WithVolumes(EmptyDirVolume("workdir")).
+		WithVolumeClaimTemplates(VolumeClaimTemplate("workdir", "5Gi"), PersistentVolumeClaim{}).
+		WithTemplate(ContainerTemplate("main",
+			WithImage("alpine:3.18"),
+			WithVolumeMounts(
+				VolumeMount{Name: "workdir", MountPath: "/work"},
+				VolumeMount{Name: "workdir", MountPath: "/work/", SubPath: "out"},
+				VolumeMount{Name: "workdir", MountPath: "//work", SubPath: "tmp"},
+				VolumeMount{Name: "workdir", MountPath: "/work/./logs/", SubPath: "logs"},
+				VolumeMount{Name: "workdir", MountPath: "/work/logs", SubPath: "logs2"}))).
+		Build()
+	for _, want := range []string{
+		`spec.volumeClaimTemplates[0].metadata.name: Duplicate value: "workdir"`,
+		"spec.volumeClaimTemplates[1].metadata.name: Required value",
+		"spec.volumeClaimTemplates[1].spec.accessModes: Required value",
+		"spec.volumeClaimTemplates[1].spec.resources.requests[storage]: Required value",
+		`spec.templates[0].container.volumeMounts[1].mountPath: Duplicate value: "/work/"`,
+		`spec.templates[0].container.volumeMounts[2].mountPath: Duplicate value: "//work"`,
+		`spec.templates[0].container.volumeMounts[4].mountPath: Duplicate value: "/work/logs"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Build() error = %v, want %q", err, want)
+		}
+	}
+}
@@ -1,7 +1,18 @@
 package workflow
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
 	"time"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/vjranagit/argo-workflows/pkg/expr"
 )
 
 // RetryStrategy defines how to retry failed steps.
@@ -62,11 +73,22 @@ func WithTimeout(duration string) TemplateOption {
 	}
 }
 
+// WithActiveDeadlineSeconds limits how long each attempt of a template may
+// run. Unlike WithTimeout, which covers the template's node including all
+// of its retries, the deadline applies to every attempt separately.
+func WithActiveDeadlineSeconds(seconds int64) TemplateOption {
+	return func(t *Template) {
+		deadline := intstr.FromInt(int(seconds))
+		t.ActiveDeadlineSeconds = &deadline
+	}
+}
+
 // RetryPolicies defines standard retry policies.
 const (
-	RetryPolicyAlways    = "Always"
-	RetryPolicyOnFailure = "OnFailure"
-	RetryPolicyOnError   = "OnError"
+	RetryPolicyAlways           = "Always"
+	RetryPolicyOnFailure        = "OnFailure"
+	RetryPolicyOnError          = "OnError"
+	RetryPolicyOnTransientError = "OnTransientError"
 )
 
 // StandardRetryStrategy creates a common retry configuration.
@@ -83,6 +105,292 @@ func StandardRetryStrategy(maxAttempts int32) *RetryStrategy {
 	}
 }
 
+// ParseDuration parses an Argo duration string as used in Backoff and
+// TimeoutPolicy. Like Argo, a bare integer is a number of seconds; anything
+// else must be a Go duration such as "90s" or "1h30m".
+func ParseDuration(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// NewBackoff creates a Backoff from Go durations, for code that builds
+// retry strategies without writing duration strings. A zero maxDuration
+// leaves the delay uncapped, and a factor of 0 keeps it constant.
+func NewBackoff(duration time.Duration, factor int32, maxDuration time.Duration) *Backoff {
+	b := &Backoff{Duration: duration.String()}
+	if factor > 0 {
+		b.Factor = &factor
+	}
+	if maxDuration > 0 {
+		b.MaxDuration = maxDuration.String()
+	}
+	return b
+}
+
+// Delay returns the delay before the given retry, counting from 1: Duration
+// multiplied by Factor once for every earlier retry, capped by MaxDuration.
+// Without a Factor the delay stays constant.
+func (b *Backoff) Delay(retry int) (time.Duration, error) {
+	if retry < 1 {
+		return 0, fmt.Errorf("retry must be at least 1, got %d", retry)
+	}
+
+	var base time.Duration
+	var err error
+	if b.Duration != "" {
+		if base, err = ParseDuration(b.Duration); err != nil {
+			return 0, fmt.Errorf("backoff duration: %w", err)
+		}
+	}
+	ceiling := time.Duration(math.MaxInt64)
+	if b.MaxDuration != "" {
+		if ceiling, err = ParseDuration(b.MaxDuration); err != nil {
+			return 0, fmt.Errorf("backoff maxDuration: %w", err)
+		}
+	}
+
+	factor := int64(1)
+	if b.Factor != nil {
+		if *b.Factor < 1 {
+			return 0, fmt.Errorf("backoff factor must be at least 1, got %d", *b.Factor)
+		}
+		factor = int64(*b.Factor)
+	}
+
+	delay := base
+	for i := 1; i < retry && factor > 1 && delay > 0 && delay < ceiling; i++ {
+		if delay > time.Duration(math.MaxInt64/factor) {
+			delay = ceiling
+			break
+		}
+		delay *= time.Duration(factor)
+	}
+	return min(delay, ceiling), nil
+}
+
+// BackoffOption configures how Schedule and NextDelay compute delays.
+type BackoffOption func(*backoffConfig)
+
+type backoffConfig struct {
+	jitter float64
+}
+
+// WithJitter randomizes each delay by up to the given fraction, between 0
+// and 1, in either direction, so that 0.1 turns a 10s delay into one
+// between 9s and 11s.
+// Jittered delays are still capped by MaxDuration. Argo itself does not
+// jitter; this is for services that reuse a strategy's schedule.
+func WithJitter(fraction float64) BackoffOption {
+	return func(c *backoffConfig) {
+		c.jitter = fraction
+	}
+}
+
+// Schedule returns the delay before each retry the strategy allows, in
+// order, so its length is Limit. A strategy without a Backoff retries
+// immediately. It fails if the strategy has no Limit, as Argo then retries
+// indefinitely, or if a backoff duration does not parse.
+func (r *RetryStrategy) Schedule(opts ...BackoffOption) ([]time.Duration, error) {
+	if r.Limit == nil {
+		return nil, errors.New("retry strategy has no limit, so it retries indefinitely")
+	}
+
+	schedule := make([]time.Duration, 0, max(*r.Limit, 0))
+	for retry := 1; retry <= int(*r.Limit); retry++ {
+		delay, _, err := r.NextDelay(retry, opts...)
+		if err != nil {
+			return nil, err
+		}
+		schedule = append(schedule, delay)
+	}
+	return schedule, nil
+}
+
+// NextDelay returns the delay before the given retry, counting from 1 for
+// the retry after the first failed attempt. It reports false once the
+// strategy's Limit has been used up.
+func (r *RetryStrategy) NextDelay(retry int, opts ...BackoffOption) (time.Duration, bool, error) {
+	if retry < 1 {
+		return 0, false, fmt.Errorf("retry must be at least 1, got %d", retry)
+	}
+	if r.Limit != nil && retry > int(*r.Limit) {
+		return 0, false, nil
+	}
+	if r.Backoff == nil {
+		return 0, true, nil
+	}
+
+	delay, err := r.Backoff.Delay(retry)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var cfg backoffConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.jitter > 0 && delay > 0 {
+		jitter := min(cfg.jitter, 1) * float64(delay)
+		delay += time.Duration((rand.Float64()*2 - 1) * jitter)
+		if r.Backoff.MaxDuration != "" {
+			// Delay has already checked that MaxDuration parses.
+			ceiling, _ := ParseDuration(r.Backoff.MaxDuration)
+			delay = min(delay, ceiling)
+		}
+	}
+	return delay, true, nil
+}
+
+// AttemptResult describes a failed attempt of a template with a retry
+// strategy.
+type AttemptResult struct {
+	// Attempt counts the attempts made so far, from 1 for the first.
+	Attempt int
+	// Phase is PhaseFailed when the main container exited non-zero and
+	// PhaseError when it could not run at all.
+	Phase string
+	// ExitCode is the main container's exit code, if it ran.
+	ExitCode *int
+	Message  string
+	Duration time.Duration
+}
+
+// Kinds of failed attempts, as told apart by ClassifyAttempt.
+const (
+	AttemptFailure   = "Failure"
+	AttemptError     = "Error"
+	AttemptTransient = "Transient"
+)
+
+// transientErrorPattern matches the messages of errors Argo treats as
+// transient: network failures and API server throttling or unavailability.
+var transientErrorPattern = regexp.MustCompile(`(?i)connection reset by peer|connection refused|connection timed out|i/o timeout|` +
+	`TLS handshake timeout|http2: client connection lost|unexpected EOF|no such host|temporary failure in name resolution|` +
+	`too many requests|the server is currently unable to handle the request|etcdserver: leader changed|exceeded quota`)
+
+// ClassifyAttempt tells a transient error apart from other failed
+// attempts. An attempt whose message matches the built-in transient errors
+// or extra is transient, whatever its phase; otherwise an Error phase is an
+// error and anything else a failure. extra may be nil.
+func ClassifyAttempt(result AttemptResult, extra *regexp.Regexp) string {
+	switch {
+	case transientErrorPattern.MatchString(result.Message) || (extra != nil && extra.MatchString(result.Message)):
+		return AttemptTransient
+	case result.Phase == PhaseError:
+		return AttemptError
+	default:
+		return AttemptFailure
+	}
+}
+
+// RetryOption configures ShouldRetry.
+type RetryOption func(*retryConfig)
+
+type retryConfig struct {
+	transient    *regexp.Regexp
+	transientSet bool
+	backoff      []BackoffOption
+}
+
+// WithTransientErrorPattern sets the pattern of messages that are
+// transient in addition to the built-in ones. It replaces the
+// TRANSIENT_ERROR_PATTERN environment variable, which Argo reads for the
+// same purpose and ShouldRetry uses by default.
+func WithTransientErrorPattern(pattern *regexp.Regexp) RetryOption {
+	return func(c *retryConfig) {
+		c.transient, c.transientSet = pattern, true
+	}
+}
+
+// WithRetryBackoffOptions passes options such as WithJitter on to the
+// backoff calculation.
+func WithRetryBackoffOptions(opts ...BackoffOption) RetryOption {
+	return func(c *retryConfig) {
+		c.backoff = append(c.backoff, opts...)
+	}
+}
+
+// ShouldRetry decides whether a failed attempt is retried and, if so, how
+// long to wait first. Like the Argo controller, it requires the attempt's
+// kind to match the retry policy, which defaults to OnFailure, or to Always
+// when an expression is set; the expression, evaluated against
+// lastRetry.exitCode, lastRetry.status, lastRetry.duration (in seconds) and
+// lastRetry.message, to hold; and the limit not to be used up. Attempts
+// that did not fail are never retried.
+func ShouldRetry(strategy *RetryStrategy, result AttemptResult, opts ...RetryOption) (bool, time.Duration, error) {
+	if strategy == nil || (result.Phase != PhaseFailed && result.Phase != PhaseError) {
+		return false, 0, nil
+	}
+
+	var cfg retryConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if !cfg.transientSet {
+		if env := os.Getenv("TRANSIENT_ERROR_PATTERN"); env != "" {
+			re, err := regexp.Compile(env)
+			if err != nil {
+				return false, 0, fmt.Errorf("TRANSIENT_ERROR_PATTERN: %w", err)
+			}
+			cfg.transient = re
+		}
+	}
+
+	policy := strategy.RetryPolicy
+	if policy == "" {
+		policy = RetryPolicyOnFailure
+		if strategy.Expression != "" {
+			policy = RetryPolicyAlways
+		}
+	}
+
+	var matches bool
+	switch policy {
+	case RetryPolicyAlways:
+		matches = true
+	case RetryPolicyOnFailure:
+		matches = result.Phase == PhaseFailed
+	case RetryPolicyOnError:
+		matches = result.Phase == PhaseError
+	case RetryPolicyOnTransientError:
+		matches = ClassifyAttempt(result, cfg.transient) == AttemptTransient
+	default:
+		return false, 0, fmt.Errorf("unsupported retry policy %q", policy)
+	}
+	if !matches {
+		return false, 0, nil
+	}
+
+	if strategy.Expression != "" {
+		exitCode := -1
+		if result.ExitCode != nil {
+			exitCode = *result.ExitCode
+		}
+		retry, err := expr.EvalBool(strategy.Expression, map[string]interface{}{
+			"lastRetry.exitCode": strconv.Itoa(exitCode),
+			"lastRetry.status":   result.Phase,
+			"lastRetry.duration": strconv.FormatInt(int64(result.Duration/time.Second), 10),
+			"lastRetry.message":  result.Message,
+		})
+		if err != nil {
+			return false, 0, fmt.Errorf("retry expression %q: %w", strategy.Expression, err)
+		}
+		if !retry {
+			return false, 0, nil
+		}
+	}
+
+	delay, ok, err := strategy.NextDelay(result.Attempt, cfg.backoff...)
+	return ok, delay, err
+}
+
 // TimeoutDuration is a helper to create standard timeout strings.
 type TimeoutDuration time.Duration
 
@@ -1,7 +1,10 @@
 package workflow
 
 import (
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRetryStrategy(t *testing.T) {
@@ -165,3 +168,153 @@ func TestContainerTemplateWithRetry(t *testing.T) {
 		t.Errorf("Duration = %v, want 5m", tmpl.Timeout.Duration)
 	}
 }
+
+func TestRetrySchedule(t *testing.T) {
+	limit := int32(6)
+	rs := &RetryStrategy{Limit: &limit, Backoff: NewBackoff(10*time.Second, 3, 2*time.Minute)}
+
+	schedule, err := rs.Schedule()
+	if err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	want := []time.Duration{10 * time.Second, 30 * time.Second, 90 * time.Second, 2 * time.Minute, 2 * time.Minute, 2 * time.Minute}
+	if len(schedule) != len(want) {
+		t.Fatalf("Schedule() = %v, want %v", schedule, want)
+	}
+	for i := range want {
+		if schedule[i] != want[i] {
+			t.Errorf("Schedule()[%d] = %v, want %v", i, schedule[i], want[i])
+		}
+	}
+
+	if d, ok, err := rs.NextDelay(3); d != 90*time.Second || !ok || err != nil {
+		t.Errorf("NextDelay(3) = %v, %v, %v, want 1m30s", d, ok, err)
+	}
+	if _, ok, err := rs.NextDelay(7); ok || err != nil {
+		t.Errorf("NextDelay(7) = %v, %v, want the limit to be used up", ok, err)
+	}
+
+	constant := &RetryStrategy{Limit: &limit, Backoff: &Backoff{Duration: "5"}}
+	if d, _, _ := constant.NextDelay(6); d != 5*time.Second {
+		t.Errorf("NextDelay() without factor = %v, want 5s", d)
+	}
+
+	huge := &RetryStrategy{Backoff: NewBackoff(time.Hour, 10, 0)}
+	if d, ok, err := huge.NextDelay(100); d <= 0 || !ok || err != nil {
+		t.Errorf("NextDelay(100) = %v, %v, %v, want a positive delay without limit", d, ok, err)
+	}
+	if _, err := huge.Schedule(); err == nil {
+		t.Error("Schedule() should reject a strategy without a limit")
+	}
+
+	bad := &RetryStrategy{Limit: &limit, Backoff: &Backoff{Duration: "soon"}}
+	if _, err := bad.Schedule(); err == nil || !strings.Contains(err.Error(), `backoff duration: invalid duration "soon"`) {
+		t.Errorf("Schedule() error = %v, want invalid duration", err)
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	limit := int32(20)
+	rs := &RetryStrategy{Limit: &limit, Backoff: NewBackoff(10*time.Second, 2, time.Minute)}
+
+	schedule, err := rs.Schedule(WithJitter(0.5))
+	if err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	for i, d := range schedule {
+		want, _, _ := rs.NextDelay(i + 1)
+		if d < want/2 || d > want+want/2 || d > time.Minute {
+			t.Errorf("delay %d = %v, want within 50%% of %v and at most 1m", i+1, d, want)
+		}
+	}
+}
+
+func TestValidateBackoff(t *testing.T) {
+	tmpl := ContainerTemplate("echo", WithImage("alpine:3.18"), WithRetryBackoff("1m", 0, "30s"))
+	errs := Validate(&Workflow{Spec: WorkflowSpec{Entrypoint: "echo", Templates: []Template{tmpl}}})
+
+	got := errs.ToAggregate().Error()
+	for _, want := range []string{
+		"spec.templates[0].retryStrategy.backoff.factor: Invalid value: 0: must be at least 1",
+		`spec.templates[0].retryStrategy.backoff.maxDuration: Invalid value: "30s": must not be less than duration`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("error missing %q:\n%v", want, got)
+		}
+	}
+}
+
+func TestClassifyAttempt(t *testing.T) {
+	extra := regexp.MustCompile(`quota .* busy`)
+	tests := []struct {
+		result AttemptResult
+		want   string
+	}{
+		{AttemptResult{Phase: PhaseFailed, Message: "Error (exit code 1)"}, AttemptFailure},
+		{AttemptResult{Phase: PhaseError, Message: "pod deleted"}, AttemptError},
+		{AttemptResult{Phase: PhaseError, Message: "dial tcp 10.0.0.1:443: connect: connection refused"}, AttemptTransient},
+		{AttemptResult{Phase: PhaseFailed, Message: "the quota service is busy"}, AttemptTransient},
+	}
+	for _, tt := range tests {
+		if got := ClassifyAttempt(tt.result, extra); got != tt.want {
+			t.Errorf("ClassifyAttempt(%q) = %v, want %v", tt.result.Message, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	limit := int32(2)
+	exit := func(code int) *int { return &code }
+	strategy := func(policy, expression string) *RetryStrategy {
+		return &RetryStrategy{Limit: &limit, RetryPolicy: policy, Expression: expression, Backoff: NewBackoff(time.Second, 2, 0)}
+	}
+	failed := AttemptResult{Attempt: 1, Phase: PhaseFailed, ExitCode: exit(2), Message: "Error (exit code 2)", Duration: 90 * time.Second}
+	errored := AttemptResult{Attempt: 1, Phase: PhaseError, Message: "i/o timeout"}
+
+	tests := []struct {
+		name     string
+		strategy *RetryStrategy
+		result   AttemptResult
+		want     bool
+	}{
+		{"default policy retries failures", strategy("", ""), failed, true},
+		{"default policy skips errors", strategy("", ""), errored, false},
+		{"always", strategy(RetryPolicyAlways, ""), errored, true},
+		{"on error", strategy(RetryPolicyOnError, ""), failed, false},
+		{"on transient error", strategy(RetryPolicyOnTransientError, ""), errored, true},
+		{"not transient", strategy(RetryPolicyOnTransientError, ""), failed, false},
+		{"expression implies always", strategy("", `lastRetry.status == "Error"`), errored, true},
+		{"expression on exit code", strategy("", `asInt(lastRetry.exitCode) > 1 && lastRetry.duration >= 90`), failed, true},
+		{"expression false", strategy(RetryPolicyAlways, `lastRetry.message matches "timeout"`), failed, false},
+		{"limit used up", strategy("", ""), AttemptResult{Attempt: 3, Phase: PhaseFailed}, false},
+		{"succeeded", strategy(RetryPolicyAlways, ""), AttemptResult{Attempt: 1, Phase: PhaseSucceeded}, false},
+		{"no strategy", nil, failed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := ShouldRetry(tt.strategy, tt.result)
+			if err != nil || got != tt.want {
+				t.Errorf("ShouldRetry() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	second := failed
+	second.Attempt = 2
+	if retry, delay, err := ShouldRetry(strategy("", ""), second); !retry || delay != 2*time.Second || err != nil {
+		t.Errorf("ShouldRetry() = %v, %v, %v, want a 2s delay", retry, delay, err)
+	}
+
+	pattern := WithTransientErrorPattern(regexp.MustCompile(`exit code 2`))
+	if retry, _, _ := ShouldRetry(strategy(RetryPolicyOnTransientError, ""), failed, pattern); !retry {
+		t.Error("ShouldRetry() should use the transient error pattern")
+	}
+	t.Setenv("TRANSIENT_ERROR_PATTERN", `exit code \d`)
+	if retry, _, _ := ShouldRetry(strategy(RetryPolicyOnTransientError, ""), failed); !retry {
+		t.Error("ShouldRetry() should use TRANSIENT_ERROR_PATTERN")
+	}
+
+	if _, _, err := ShouldRetry(strategy("", "lastRetry.status =="), failed); err == nil {
+		t.Error("ShouldRetry() should reject a malformed expression")
+	}
+}
@@ -0,0 +1,125 @@
+package workflow
+
+// StepsBuilder provides a fluent API for constructing steps templates.
+// Unlike Hera's nested Steps/Parallel context managers, groups are formed
+// by the order of method calls: Step starts a new sequential group and
+// Parallel joins the group started last.
+type StepsBuilder struct {
+	name   string
+	groups [][]StepGroup
+}
+
+// NewSteps creates a new steps builder.
+func NewSteps(name string) *StepsBuilder {
+	return &StepsBuilder{
+		name:   name,
+		groups: make([][]StepGroup, 0),
+	}
+}
+
+// Step adds a step that runs after every step added before it.
+func (s *StepsBuilder) Step(name, template string, options ...StepOption) *StepsBuilder {
+	s.groups = append(s.groups, []StepGroup{newStep(name, template, options)})
+	return s
+}
+
+// Parallel adds a step that runs alongside the steps of the current group.
+// If no group has been started yet, it starts one.
+func (s *StepsBuilder) Parallel(name, template string, options ...StepOption) *StepsBuilder {
+	if len(s.groups) == 0 {
+		return s.Step(name, template, options...)
+	}
+
+	last := len(s.groups) - 1
+	s.groups[last] = append(s.groups[last], newStep(name, template, options))
+	return s
+}
+
+// Build creates a Template with the steps configuration.
+// Template references are checked when the workflow is built.
+func (s *StepsBuilder) Build() Template {
+	groups := s.groups
+	return Template{
+		Name:  s.name,
+		Steps: &groups,
+	}
+}
+
+func newStep(name, template string, options []StepOption) StepGroup {
+	step := StepGroup{
+		Name:     name,
+		Template: template,
+	}
+
+	for _, opt := range options {
+		opt(&step)
+	}
+
+	return step
+}
+
+// StepOption is a functional option for configuring steps.
+type StepOption func(*StepGroup)
+
+// WithStepArguments sets step arguments.
+func WithStepArguments(args *Arguments) StepOption {
+	return func(s *StepGroup) {
+		s.Arguments = args
+	}
+}
+
+// WithStepCondition sets a when condition for the step.
+func WithStepCondition(condition string) StepOption {
+	return func(s *StepGroup) {
+		s.When = condition
+	}
+}
+
+// WithStepItems runs the step once per item. Each run sees the item as
+// {{item}}, or its fields as {{item.<key>}} when the item is a map.
+func WithStepItems(items ...interface{}) StepOption {
+	return func(s *StepGroup) {
+		s.WithItems = items
+	}
+}
+
+// WithStepParam runs the step once per element of a JSON list, typically
+// the output of an earlier step such as "{{steps.list.outputs.result}}".
+func WithStepParam(param string) StepOption {
+	return func(s *StepGroup) {
+		s.WithParam = param
+	}
+}
+
+// WithStepSequence runs the step once per number of a sequence.
+func WithStepSequence(seq *Sequence) StepOption {
+	return func(s *StepGroup) {
+		s.WithSequence = seq
+	}
+}
+
+// WithStepHook adds a lifecycle hook to the step; see WithHook.
+func WithStepHook(name string, hook LifecycleHook) StepOption {
+	return func(s *StepGroup) {
+		if s.Hooks == nil {
+			s.Hooks = make(LifecycleHooks)
+		}
+		s.Hooks[name] = hook
+	}
+}
+
+// WithStepTemplateRef makes the step call a template of a WorkflowTemplate;
+// see WithTemplateRef.
+func WithStepTemplateRef(name, template string) StepOption {
+	return func(s *StepGroup) {
+		s.TemplateRef = &TemplateRef{Name: name, Template: template}
+	}
+}
+
+// WithStepClusterTemplateRef makes the step call a template of a
+// ClusterWorkflowTemplate; see WithTemplateRef.
+func WithStepClusterTemplateRef(name, template string) StepOption {
+	return func(s *StepGroup) {
+		s.TemplateRef = &TemplateRef{Name: name, Template: template, ClusterScope: true}
+	}
+}
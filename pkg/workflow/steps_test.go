@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStepsBuilder(t *testing.T) {
+	steps := NewSteps("pipeline").
+		Step("checkout", "git").
+		Step("test", "go", WithStepArguments(NewArguments().AddParameter(Parameter{Name: "cmd", Value: "test"}))).
+		Parallel("lint", "go", WithStepCondition("{{workflow.parameters.lint}} == true")).
+		Parallel("vet", "go").
+		Step("publish", "upload", WithStepItems("linux", "darwin")).
+		Build()
+
+	if steps.Name != "pipeline" {
+		t.Errorf("Expected name 'pipeline', got '%s'", steps.Name)
+	}
+
+	if steps.Steps == nil {
+		t.Fatal("Steps should not be nil")
+	}
+
+	var got []string
+	for _, group := range *steps.Steps {
+		names := make([]string, len(group))
+		for i, step := range group {
+			names[i] = step.Name
+		}
+		got = append(got, strings.Join(names, ","))
+	}
+	if want := "checkout|test,lint,vet|publish"; strings.Join(got, "|") != want {
+		t.Errorf("groups = %v, want %v", strings.Join(got, "|"), want)
+	}
+
+	groups := *steps.Steps
+	if groups[1][0].Arguments == nil || groups[1][0].Arguments.Parameters[0].Value != "test" {
+		t.Errorf("Arguments = %v, want cmd=test", groups[1][0].Arguments)
+	}
+	if groups[1][1].When != "{{workflow.parameters.lint}} == true" {
+		t.Errorf("When = %v", groups[1][1].When)
+	}
+	if len(groups[2][0].WithItems) != 2 {
+		t.Errorf("WithItems = %v, want 2 items", groups[2][0].WithItems)
+	}
+}
+
+func TestStepsBuilderParallelFirst(t *testing.T) {
+	steps := NewSteps("fan-out").
+		Parallel("a", "echo").
+		Parallel("b", "echo").
+		Build()
+
+	if len(*steps.Steps) != 1 || len((*steps.Steps)[0]) != 2 {
+		t.Errorf("Steps = %v, want one group of two steps", *steps.Steps)
+	}
+}
+
+func TestStepsBuilderUnknownTemplate(t *testing.T) {
+	_, err := New("steps").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"))).
+		WithTemplate(NewSteps("main").
+			Step("one", "echo").
+			Parallel("two", "ehco").
+			Build()).
+		Build()
+
+	if err == nil {
+		t.Fatal("Expected error for unknown template")
+	}
+	if !strings.Contains(err.Error(), "spec.templates[1].steps[0][1].template") {
+		t.Errorf("error = %v, want path of the unknown template", err)
+	}
+}
@@ -0,0 +1,247 @@
+package workflow
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TemplateStore holds the WorkflowTemplates and ClusterWorkflowTemplates
+// that workflows may reference. It stands in for the informers the Argo
+// controller uses to look them up, so references can be resolved without a
+// cluster. Namespaces are not distinguished.
+type TemplateStore struct {
+	mu      sync.RWMutex
+	local   map[string]*WorkflowSpec
+	cluster map[string]*WorkflowSpec
+}
+
+// NewTemplateStore creates an empty template store.
+func NewTemplateStore() *TemplateStore {
+	return &TemplateStore{
+		local:   make(map[string]*WorkflowSpec),
+		cluster: make(map[string]*WorkflowSpec),
+	}
+}
+
+// Add stores a WorkflowTemplate, replacing any with the same name.
+func (s *TemplateStore) Add(wft *WorkflowTemplate) *TemplateStore {
+	spec := wft.Spec
+	s.mu.Lock()
+	s.local[wft.Name] = &spec
+	s.mu.Unlock()
+	return s
+}
+
+// AddCluster stores a ClusterWorkflowTemplate, replacing any with the same
+// name.
+func (s *TemplateStore) AddCluster(cwft *ClusterWorkflowTemplate) *TemplateStore {
+	spec := cwft.Spec
+	s.mu.Lock()
+	s.cluster[cwft.Name] = &spec
+	s.mu.Unlock()
+	return s
+}
+
+// Spec returns the spec of the referenced WorkflowTemplate or
+// ClusterWorkflowTemplate. The returned spec must not be modified.
+func (s *TemplateStore) Spec(ref *WorkflowTemplateRef) (*WorkflowSpec, error) {
+	return s.lookup(ref.Name, ref.ClusterScope)
+}
+
+// Template returns the referenced template together with the spec that
+// holds it. Templates it calls by plain name must be looked up in that spec
+// rather than in the calling workflow.
+func (s *TemplateStore) Template(ref *TemplateRef) (*Template, *WorkflowSpec, error) {
+	spec, err := s.lookup(ref.Name, ref.ClusterScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range spec.Templates {
+		if spec.Templates[i].Name == ref.Template {
+			return &spec.Templates[i], spec, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("template %q not found in %s %q", ref.Template, kindOf(ref.ClusterScope), ref.Name)
+}
+
+func (s *TemplateStore) lookup(name string, clusterScope bool) (*WorkflowSpec, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	specs := s.local
+	if clusterScope {
+		specs = s.cluster
+	}
+	spec, ok := specs[name]
+	if !ok {
+		return nil, fmt.Errorf("%s %q not found", kindOf(clusterScope), name)
+	}
+	return spec, nil
+}
+
+func kindOf(clusterScope bool) string {
+	if clusterScope {
+		return "ClusterWorkflowTemplate"
+	}
+	return "WorkflowTemplate"
+}
+
+// JoinWorkflowSpec returns the spec a workflow referencing a
+// WorkflowTemplate runs with, following the controller's precedence: every
+// field the workflow sets overrides the template's, and templates, volumes,
+// volume claim templates and argument parameters are merged by name with
+// the workflow's taking precedence. Neither input is modified.
+func JoinWorkflowSpec(spec, base *WorkflowSpec) WorkflowSpec {
+	joined := *base
+	joined.WorkflowTemplateRef = spec.WorkflowTemplateRef
+
+	if spec.Entrypoint != "" {
+		joined.Entrypoint = spec.Entrypoint
+	}
+	if spec.ServiceAccountName != "" {
+		joined.ServiceAccountName = spec.ServiceAccountName
+	}
+	if spec.NodeSelector != nil {
+		joined.NodeSelector = spec.NodeSelector
+	}
+	if spec.Tolerations != nil {
+		joined.Tolerations = spec.Tolerations
+	}
+	if spec.Affinity != nil {
+		joined.Affinity = spec.Affinity
+	}
+	if spec.SecurityContext != nil {
+		joined.SecurityContext = spec.SecurityContext
+	}
+	if spec.PodSpecPatch != "" {
+		joined.PodSpecPatch = spec.PodSpecPatch
+	}
+	if spec.PodPriorityClassName != "" {
+		joined.PodPriorityClassName = spec.PodPriorityClassName
+	}
+	if spec.PodMetadata != nil {
+		joined.PodMetadata = spec.PodMetadata
+	}
+	if spec.ArtifactRepositoryRef != nil {
+		joined.ArtifactRepositoryRef = spec.ArtifactRepositoryRef
+	}
+	if spec.ArtifactGC != nil {
+		joined.ArtifactGC = spec.ArtifactGC
+	}
+	if spec.Parallelism != nil {
+		joined.Parallelism = spec.Parallelism
+	}
+	if spec.ActiveDeadline != nil {
+		joined.ActiveDeadline = spec.ActiveDeadline
+	}
+	if spec.TTL != nil {
+		joined.TTL = spec.TTL
+	}
+	if spec.TTLStrategy != nil {
+		joined.TTLStrategy = spec.TTLStrategy
+	}
+	if spec.PodGC != nil {
+		joined.PodGC = spec.PodGC
+	}
+	if spec.OnExit != "" {
+		joined.OnExit = spec.OnExit
+	}
+	if spec.Synchronization != nil {
+		joined.Synchronization = spec.Synchronization
+	}
+
+	joined.Templates = append([]Template(nil), spec.Templates...)
+	for _, t := range base.Templates {
+		if !hasTemplate(spec.Templates, t.Name) {
+			joined.Templates = append(joined.Templates, t)
+		}
+	}
+
+	joined.Volumes = append([]Volume(nil), spec.Volumes...)
+	for _, vol := range base.Volumes {
+		if !hasVolume(spec.Volumes, vol.Name) {
+			joined.Volumes = append(joined.Volumes, vol)
+		}
+	}
+
+	joined.VolumeClaimTemplates = append([]PersistentVolumeClaim(nil), spec.VolumeClaimTemplates...)
+	for _, claim := range base.VolumeClaimTemplates {
+		if !hasVolumeClaim(spec.VolumeClaimTemplates, claim.Name) {
+			joined.VolumeClaimTemplates = append(joined.VolumeClaimTemplates, claim)
+		}
+	}
+
+	joined.Arguments = joinArguments(spec.Arguments, base.Arguments)
+	return joined
+}
+
+func hasVolume(volumes []Volume, name string) bool {
+	for i := range volumes {
+		if volumes[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVolumeClaim(claims []PersistentVolumeClaim, name string) bool {
+	for i := range claims {
+		if claims[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTemplate(templates []Template, name string) bool {
+	for i := range templates {
+		if templates[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func joinArguments(args, base *Arguments) *Arguments {
+	if args == nil {
+		return base
+	}
+	if base == nil {
+		return args
+	}
+
+	joined := &Arguments{
+		Parameters: append([]Parameter(nil), args.Parameters...),
+		Artifacts:  append([]Artifact(nil), args.Artifacts...),
+	}
+	for _, p := range base.Parameters {
+		if !hasParameter(args.Parameters, p.Name) {
+			joined.Parameters = append(joined.Parameters, p)
+		}
+	}
+	for _, a := range base.Artifacts {
+		if !hasArtifact(args.Artifacts, a.Name) {
+			joined.Artifacts = append(joined.Artifacts, a)
+		}
+	}
+	return joined
+}
+
+func hasParameter(params []Parameter, name string) bool {
+	for _, p := range params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasArtifact(artifacts []Artifact, name string) bool {
+	for _, a := range artifacts {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package workflow
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// FromYAMLStrict deserializes a workflow from YAML like FromYAML, but
+// rejects fields the Workflow types do not define and duplicate keys.
+// sigs.k8s.io/yaml drops unknown keys silently, so a typo such as
+// "dependancies:" would otherwise disappear; here every unknown field is
+// reported with its path and, where one is close, the field that was
+// probably meant.
+func FromYAMLStrict(data []byte) (*Workflow, error) {
+	if err := checkStrict(data, reflect.TypeOf(Workflow{})); err != nil {
+		return nil, err
+	}
+	return FromYAML(data)
+}
+
+// checkStrict reports duplicate keys in doc and every field that has no
+// counterpart in t.
+func checkStrict(doc []byte, t reflect.Type) error {
+	data, err := yaml.YAMLToJSONStrict(doc)
+	if err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	if errs := unknownFields(nil, v, t); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	return nil
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unknownFields walks a decoded JSON value alongside the Go type it is
+// decoded into and reports keys that the type does not define. Types with
+// their own unmarshalling, such as metav1.Time or intstr.IntOrString, are
+// not inspected.
+func unknownFields(path *field.Path, v interface{}, t reflect.Type) field.ErrorList {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return nil
+	}
+
+	var errs field.ErrorList
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			ft, ok := fields[k]
+			if !ok {
+				errs = append(errs, field.Forbidden(child(path, k), unknownFieldDetail(k, fields)))
+				continue
+			}
+			errs = append(errs, unknownFields(child(path, k), obj[k], ft)...)
+		}
+
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			errs = append(errs, unknownFields(path.Key(k), obj[k], t.Elem())...)
+		}
+
+	case reflect.Slice, reflect.Array:
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range list {
+			errs = append(errs, unknownFields(path.Index(i), item, t.Elem())...)
+		}
+	}
+	return errs
+}
+
+// child returns the path of a field below path, which is nil at the root.
+func child(path *field.Path, name string) *field.Path {
+	if path == nil {
+		return field.NewPath(name)
+	}
+	return path.Child(name)
+}
+
+// jsonFields returns the JSON names of t's fields mapped to their types,
+// flattening embedded structs and those tagged ",inline".
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if (f.Anonymous && name == "") || hasOption(opts, "inline") {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			for k, v := range jsonFields(ft) {
+				fields[k] = v
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func hasOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownFieldDetail describes an unknown field, suggesting the known field
+// with the closest name if it is close enough to be a likely typo.
+func unknownFieldDetail(name string, fields map[string]reflect.Type) string {
+	best, bestDist := "", -1
+	for known := range fields {
+		d := editDistance(strings.ToLower(name), strings.ToLower(known))
+		if bestDist < 0 || d < bestDist || (d == bestDist && known < best) {
+			best, bestDist = known, d
+		}
+	}
+
+	limit := len(name) / 4
+	if limit < 2 {
+		limit = 2
+	}
+	if bestDist >= 0 && bestDist <= limit {
+		return fmt.Sprintf("unknown field, did you mean %q?", best)
+	}
+	return "unknown field"
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
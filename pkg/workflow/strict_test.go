@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromYAMLStrict(t *testing.T) {
+	data := []byte(`
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: typos
+  labels:
+    any-key: allowed
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: a
+        template: echo
+        dependancies: [b]
+        arguments:
+          parameters:
+          - name: m
+            value: {nested: values are free-form}
+  - name: echo
+    retrystrategy:
+      limit: 3
+    container:
+      image: alpine:3.18
+      imagePullPolicy: Always
+`)
+
+	if _, err := FromYAML(data); err != nil {
+		t.Fatalf("FromYAML() error = %v", err)
+	}
+
+	_, err := FromYAMLStrict(data)
+	if err == nil {
+		t.Fatal("FromYAMLStrict() should reject unknown fields")
+	}
+
+	for _, want := range []string{
+		`spec.templates[0].dag.tasks[0].dependancies: Forbidden: unknown field, did you mean "dependencies"?`,
+		`spec.templates[1].retrystrategy: Forbidden: unknown field, did you mean "retryStrategy"?`,
+		`spec.templates[1].container.imagePullPolicy: Forbidden: unknown field`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), `imagePullPolicy: Forbidden: unknown field, did you mean`) {
+		t.Errorf("imagePullPolicy should have no suggestion:\n%v", err)
+	}
+}
+
+func TestFromYAMLStrictValid(t *testing.T) {
+	wf, err := New("strict").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("main", WithImage("alpine:3.18"), WithRetryStrategy(2, RetryPolicyAlways))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	data, err := wf.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML() error = %v", err)
+	}
+
+	if _, err := FromYAMLStrict(data); err != nil {
+		t.Errorf("FromYAMLStrict() error = %v", err)
+	}
+
+	dup := []byte("kind: Workflow\nmetadata:\n  name: a\n  name: b\n")
+	if _, err := FromYAMLStrict(dup); err == nil || !strings.Contains(err.Error(), "already set") {
+		t.Errorf("FromYAMLStrict() error = %v, want duplicate key error", err)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	data := "kind: Workflow\nmetadata:\n  name: a\n---\nkind: CronWorkflow\nmetadata:\n  name: b\nspec:\n  schedual: '@daily'\n"
+
+	manifests, err := LoadReader(strings.NewReader(data), "cron.yaml")
+	if err != nil || len(manifests) != 2 {
+		t.Fatalf("LoadReader() = %d manifests, %v", len(manifests), err)
+	}
+
+	manifests, err = LoadOptions{Strict: true}.LoadReader(strings.NewReader(data), "cron.yaml")
+	if len(manifests) != 1 {
+		t.Errorf("got %d manifests, want 1", len(manifests))
+	}
+	if err == nil || !strings.Contains(err.Error(), `cron.yaml#1: spec.schedual: Forbidden: unknown field, did you mean "schedule"?`) {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "abc", 3},
+		{"dependancies", "dependencies", 1},
+		{"kitten", "sitting", 3},
+		{"same", "same", 0},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
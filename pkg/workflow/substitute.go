@@ -0,0 +1,540 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// placeholderPattern matches Argo's simple template tags such as
+// {{inputs.parameters.x}}. Expression tags ({{=...}}) are left untouched.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// Placeholders returns the variable names referenced in s, in order of
+// appearance. Expression tags are not included.
+func Placeholders(s string) []string {
+	var names []string
+	for _, m := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+		if strings.HasPrefix(m[1], "=") {
+			continue
+		}
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// FormatValue renders a parameter value the way Argo does: strings are used
+// verbatim, numbers without a trailing ".0", and lists or maps as JSON.
+func FormatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case int, int32, int64, bool:
+		return fmt.Sprint(val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
+
+// Scope holds the values visible to template placeholders, keyed by the
+// full variable name, e.g. "inputs.parameters.message" or
+// "tasks.build.outputs.result".
+type Scope map[string]string
+
+// NewScope creates an empty scope.
+func NewScope() Scope {
+	return make(Scope)
+}
+
+// Clone returns a copy of the scope.
+func (s Scope) Clone() Scope {
+	c := make(Scope, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+// Set adds a single variable to the scope.
+func (s Scope) Set(name, value string) Scope {
+	s[name] = value
+	return s
+}
+
+// SetInputs adds resolved input parameters as inputs.parameters.<name>.
+func (s Scope) SetInputs(params map[string]string) Scope {
+	for name, value := range params {
+		s["inputs.parameters."+name] = value
+	}
+	return s
+}
+
+// SetInputArtifacts adds resolved input artifacts as inputs.artifacts.<name>,
+// which from can refer to, and their paths as inputs.artifacts.<name>.path.
+func (s Scope) SetInputArtifacts(artifacts []Artifact) Scope {
+	for _, a := range artifacts {
+		s["inputs.artifacts."+a.Name] = FormatValue(a)
+		if a.Path != "" {
+			s["inputs.artifacts."+a.Name+".path"] = a.Path
+		}
+	}
+	return s
+}
+
+// SetItem adds the current loop item. Map items additionally expose each
+// field as item.<key>.
+func (s Scope) SetItem(item interface{}) Scope {
+	s["item"] = FormatValue(item)
+	if m, ok := item.(map[string]interface{}); ok {
+		for k, v := range m {
+			s["item."+k] = FormatValue(v)
+		}
+	}
+	return s
+}
+
+// SetTaskOutputs exposes a DAG task's outputs as tasks.<name>.outputs.*.
+func (s Scope) SetTaskOutputs(task string, outputs *Outputs) Scope {
+	return s.setOutputs("tasks."+task, outputs)
+}
+
+// SetStepOutputs exposes a step's outputs as steps.<name>.outputs.*.
+func (s Scope) SetStepOutputs(step string, outputs *Outputs) Scope {
+	return s.setOutputs("steps."+step, outputs)
+}
+
+// SetTaskIP exposes the IP of a DAG task's pod, such as a daemon's, as
+// tasks.<name>.ip.
+func (s Scope) SetTaskIP(task, ip string) Scope {
+	s["tasks."+task+".ip"] = ip
+	return s
+}
+
+// SetStepIP exposes the IP of a step's pod as steps.<name>.ip.
+func (s Scope) SetStepIP(step, ip string) Scope {
+	s["steps."+step+".ip"] = ip
+	return s
+}
+
+func (s Scope) setOutputs(prefix string, outputs *Outputs) Scope {
+	if outputs == nil {
+		return s
+	}
+	if outputs.Result != "" {
+		s[prefix+".outputs.result"] = outputs.Result
+	}
+	for _, p := range outputs.Parameters {
+		s[prefix+".outputs.parameters."+p.Name] = FormatValue(p.Value)
+	}
+	// Artifacts are stored as JSON for from to refer to.
+	for _, a := range outputs.Artifacts {
+		s[prefix+".outputs.artifacts."+a.Name] = FormatValue(a)
+	}
+	return s
+}
+
+// SetWorkflowStatus exposes the workflow phase as workflow.status and its
+// failed nodes as workflow.failures, a JSON list in the format Argo uses,
+// for exit handlers and hooks.
+func (s Scope) SetWorkflowStatus(status *WorkflowStatus) Scope {
+	type failure struct {
+		DisplayName  string `json:"displayName"`
+		Message      string `json:"message"`
+		TemplateName string `json:"templateName"`
+		Phase        string `json:"phase"`
+		PodName      string `json:"podName"`
+		FinishedAt   string `json:"finishedAt"`
+	}
+
+	nodes := make([]Node, 0, len(status.Nodes))
+	for _, n := range status.Nodes {
+		if n.Phase == PhaseFailed || n.Phase == PhaseError {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	var failures []failure
+	for _, n := range nodes {
+		f := failure{
+			DisplayName:  n.DisplayName,
+			Message:      n.Message,
+			TemplateName: n.TemplateName,
+			Phase:        n.Phase,
+		}
+		if n.Type == NodeTypePod {
+			f.PodName = n.ID
+		}
+		if !n.FinishedAt.IsZero() {
+			f.FinishedAt = n.FinishedAt.UTC().Format("2006-01-02T15:04:05Z")
+		}
+		failures = append(failures, f)
+	}
+
+	s["workflow.status"] = status.Phase
+	s["workflow.failures"] = FormatValue(failures)
+	return s
+}
+
+// Replace substitutes every resolvable placeholder in str and returns the
+// names of the ones that could not be resolved.
+func (s Scope) Replace(str string) (string, []string) {
+	var missing []string
+	out := placeholderPattern.ReplaceAllStringFunc(str, func(tag string) string {
+		name := placeholderPattern.FindStringSubmatch(tag)[1]
+		if strings.HasPrefix(name, "=") {
+			return tag
+		}
+		if v, ok := s[name]; ok {
+			return v
+		}
+		missing = append(missing, name)
+		return tag
+	})
+	return out, missing
+}
+
+// Resolver substitutes Argo template variables.
+// The workflow supplies the global variables (workflow.name,
+// workflow.parameters.*, ...); callers supply everything that depends on
+// where the template is invoked through a Scope.
+type Resolver struct {
+	wf     *Workflow
+	global Scope
+}
+
+// NewResolver creates a resolver for templates of the given workflow.
+func NewResolver(wf *Workflow) *Resolver {
+	global := NewScope()
+	global["workflow.name"] = wf.Name
+	global["workflow.namespace"] = wf.Namespace
+	global["workflow.uid"] = string(wf.UID)
+	global["workflow.serviceAccountName"] = wf.Spec.ServiceAccountName
+	if !wf.CreationTimestamp.IsZero() {
+		global["workflow.creationTimestamp"] = wf.CreationTimestamp.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	for k, v := range wf.Labels {
+		global["workflow.labels."+k] = v
+	}
+	for k, v := range wf.Annotations {
+		global["workflow.annotations."+k] = v
+	}
+	if wf.Spec.Arguments != nil {
+		for _, p := range wf.Spec.Arguments.Parameters {
+			value := p.Value
+			if value == nil {
+				value = p.Default
+			}
+			global["workflow.parameters."+p.Name] = FormatValue(value)
+		}
+	}
+
+	return &Resolver{wf: wf, global: global}
+}
+
+// Scope returns a new scope pre-populated with the workflow's global
+// variables.
+func (r *Resolver) Scope() Scope {
+	return r.global.Clone()
+}
+
+// Resolve returns a copy of tmpl with every placeholder in its containers,
+// script, parameter values and artifacts substituted from scope. The workflow's global
+// variables are always visible. References that cannot be resolved are left
+// in place and reported with the path of the field that contains them.
+//
+// Arguments of DAG tasks and steps are not touched, since they can only be
+// resolved once the tasks they refer to have run; see ResolveArguments.
+func (r *Resolver) Resolve(tmpl *Template, scope Scope) (*Template, field.ErrorList) {
+	s := r.merge(scope)
+	out := *tmpl
+	path := r.TemplatePath(tmpl.Name)
+	var errs field.ErrorList
+
+	if tmpl.Container != nil {
+		c := s.replaceContainer(*tmpl.Container, path.Child("container"), &errs)
+		out.Container = &c
+	}
+	out.InitContainers = s.replaceUserContainers(tmpl.InitContainers, path.Child("initContainers"), &errs)
+	out.Sidecars = s.replaceUserContainers(tmpl.Sidecars, path.Child("sidecars"), &errs)
+
+	if tmpl.ContainerSet != nil {
+		cs := *tmpl.ContainerSet
+		p := path.Child("containerSet", "containers")
+		cs.Containers = make([]ContainerNode, len(tmpl.ContainerSet.Containers))
+		for i, node := range tmpl.ContainerSet.Containers {
+			node.Container = s.replaceContainer(node.Container, p.Index(i), &errs)
+			cs.Containers[i] = node
+		}
+		out.ContainerSet = &cs
+	}
+
+	if tmpl.Script != nil {
+		sc := *tmpl.Script
+		p := path.Child("script")
+		sc.Image = s.replaceField(sc.Image, p.Child("image"), &errs)
+		sc.Command = s.replaceAll(sc.Command, p.Child("command"), &errs)
+		sc.Source = s.replaceField(sc.Source, p.Child("source"), &errs)
+		sc.Env = s.replaceEnv(sc.Env, p.Child("env"), &errs)
+		sc.WorkingDir = s.replaceField(sc.WorkingDir, p.Child("workingDir"), &errs)
+		out.Script = &sc
+	}
+
+	if tmpl.Resource != nil {
+		res := *tmpl.Resource
+		p := path.Child("resource")
+		res.Manifest = s.replaceField(res.Manifest, p.Child("manifest"), &errs)
+		res.SuccessCondition = s.replaceField(res.SuccessCondition, p.Child("successCondition"), &errs)
+		res.FailureCondition = s.replaceField(res.FailureCondition, p.Child("failureCondition"), &errs)
+		res.Flags = s.replaceAll(res.Flags, p.Child("flags"), &errs)
+		out.Resource = &res
+	}
+
+	if tmpl.Suspend != nil {
+		out.Suspend = &Suspend{Duration: s.replaceField(tmpl.Suspend.Duration, path.Child("suspend", "duration"), &errs)}
+	}
+
+	if tmpl.HTTP != nil {
+		h := *tmpl.HTTP
+		p := path.Child("http")
+		h.URL = s.replaceField(h.URL, p.Child("url"), &errs)
+		h.Body = s.replaceField(h.Body, p.Child("body"), &errs)
+		if tmpl.HTTP.Headers != nil {
+			h.Headers = make([]HTTPHeader, len(tmpl.HTTP.Headers))
+			for i, header := range tmpl.HTTP.Headers {
+				header.Value = s.replaceField(header.Value, p.Child("headers").Index(i).Child("value"), &errs)
+				h.Headers[i] = header
+			}
+		}
+		out.HTTP = &h
+	}
+
+	if tmpl.Inputs != nil {
+		in := *tmpl.Inputs
+		in.Parameters = s.replaceParameters(in.Parameters, "inputs.parameters.", path.Child("inputs", "parameters"), &errs)
+		in.Artifacts = s.replaceArtifacts(in.Artifacts, "inputs.artifacts.", path.Child("inputs", "artifacts"), &errs)
+		out.Inputs = &in
+	}
+
+	if tmpl.Outputs != nil {
+		o := *tmpl.Outputs
+		o.Parameters = s.replaceParameters(o.Parameters, "", path.Child("outputs", "parameters"), &errs)
+		o.Artifacts = s.replaceArtifacts(o.Artifacts, "", path.Child("outputs", "artifacts"), &errs)
+		out.Outputs = &o
+	}
+
+	return &out, errs
+}
+
+// ResolveArguments returns a copy of args with placeholders in parameter
+// values and artifacts substituted from scope. An artifact whose from
+// refers to another artifact, such as {{tasks.a.outputs.artifacts.x}},
+// takes that artifact's location instead. path is used to report unresolved
+// references, e.g. spec.templates[1].dag.tasks[0].arguments.
+func (r *Resolver) ResolveArguments(args *Arguments, scope Scope, path *field.Path) (*Arguments, field.ErrorList) {
+	if args == nil {
+		return nil, nil
+	}
+
+	s := r.merge(scope)
+	out := *args
+	var errs field.ErrorList
+	out.Parameters = s.replaceParameters(args.Parameters, "", path.Child("parameters"), &errs)
+	out.Artifacts = s.replaceArtifacts(args.Artifacts, "", path.Child("artifacts"), &errs)
+	return &out, errs
+}
+
+// merge layers scope over the global variables.
+func (r *Resolver) merge(scope Scope) Scope {
+	s := r.global.Clone()
+	for k, v := range scope {
+		s[k] = v
+	}
+	return s
+}
+
+// TemplatePath returns the field path of the named template within the
+// workflow spec, e.g. spec.templates[2].
+func (r *Resolver) TemplatePath(name string) *field.Path {
+	for i := range r.wf.Spec.Templates {
+		if r.wf.Spec.Templates[i].Name == name {
+			return field.NewPath("spec", "templates").Index(i)
+		}
+	}
+	return field.NewPath("template")
+}
+
+func (s Scope) replaceField(str string, path *field.Path, errs *field.ErrorList) string {
+	out, missing := s.Replace(str)
+	for _, name := range missing {
+		*errs = append(*errs, field.Invalid(path, "{{"+name+"}}", "unresolved reference"))
+	}
+	return out
+}
+
+func (s Scope) replaceContainer(c Container, path *field.Path, errs *field.ErrorList) Container {
+	c.Image = s.replaceField(c.Image, path.Child("image"), errs)
+	c.Command = s.replaceAll(c.Command, path.Child("command"), errs)
+	c.Args = s.replaceAll(c.Args, path.Child("args"), errs)
+	c.Env = s.replaceEnv(c.Env, path.Child("env"), errs)
+	c.WorkingDir = s.replaceField(c.WorkingDir, path.Child("workingDir"), errs)
+	return c
+}
+
+func (s Scope) replaceUserContainers(containers []UserContainer, path *field.Path, errs *field.ErrorList) []UserContainer {
+	if containers == nil {
+		return nil
+	}
+	out := make([]UserContainer, len(containers))
+	for i, c := range containers {
+		c.Container = s.replaceContainer(c.Container, path.Index(i), errs)
+		out[i] = c
+	}
+	return out
+}
+
+func (s Scope) replaceAll(strs []string, path *field.Path, errs *field.ErrorList) []string {
+	if strs == nil {
+		return nil
+	}
+	out := make([]string, len(strs))
+	for i, str := range strs {
+		out[i] = s.replaceField(str, path.Index(i), errs)
+	}
+	return out
+}
+
+func (s Scope) replaceEnv(env []EnvVar, path *field.Path, errs *field.ErrorList) []EnvVar {
+	if env == nil {
+		return nil
+	}
+	out := make([]EnvVar, len(env))
+	for i, e := range env {
+		e.Value = s.replaceField(e.Value, path.Index(i).Child("value"), errs)
+		out[i] = e
+	}
+	return out
+}
+
+// replaceParameters substitutes parameter values. When prefix is set, a
+// parameter whose prefixed name is in scope takes that value outright, which
+// is how resolved inputs are written back into a rendered template.
+func (s Scope) replaceParameters(params []Parameter, prefix string, path *field.Path, errs *field.ErrorList) []Parameter {
+	if params == nil {
+		return nil
+	}
+	out := make([]Parameter, len(params))
+	for i, p := range params {
+		if v, ok := s[prefix+p.Name]; ok && prefix != "" {
+			p.Value = v
+			out[i] = p
+			continue
+		}
+		if str, ok := p.Value.(string); ok {
+			p.Value = s.replaceField(str, path.Index(i).Child("value"), errs)
+		}
+		if str, ok := p.Default.(string); ok {
+			p.Default = s.replaceField(str, path.Index(i).Child("default"), errs)
+		}
+		out[i] = p
+	}
+	return out
+}
+
+// replaceArtifacts substitutes the paths and locations of artifacts, and
+// resolves from to the location of the artifact it refers to. When prefix
+// is set, an artifact whose prefixed name is in scope takes the location
+// found there, which is how resolved inputs are written back into a
+// rendered template.
+func (s Scope) replaceArtifacts(artifacts []Artifact, prefix string, path *field.Path, errs *field.ErrorList) []Artifact {
+	if artifacts == nil {
+		return nil
+	}
+	out := make([]Artifact, len(artifacts))
+	for i, a := range artifacts {
+		p := path.Index(i)
+		a.Path = s.replaceField(a.Path, p.Child("path"), errs)
+
+		var src Artifact
+		if v, ok := s[prefix+a.Name]; ok && prefix != "" && json.Unmarshal([]byte(v), &src) == nil {
+			a.SetLocation(&src)
+		} else if a.From != "" {
+			from := s.replaceField(a.From, p.Child("from"), errs)
+			switch {
+			case strings.Contains(from, "{{"):
+				// Left in place and reported by replaceField.
+			case json.Unmarshal([]byte(from), &src) != nil || !src.HasLocation():
+				*errs = append(*errs, field.Invalid(p.Child("from"), a.From, "must refer to an artifact"))
+			default:
+				a.SetLocation(&src)
+				a.From = ""
+			}
+		}
+		out[i] = s.replaceLocation(a, p, errs)
+	}
+	return out
+}
+
+// replaceLocation substitutes the fields that identify an artifact within
+// its location.
+func (s Scope) replaceLocation(a Artifact, path *field.Path, errs *field.ErrorList) Artifact {
+	if a.S3 != nil {
+		s3 := *a.S3
+		s3.Key = s.replaceField(s3.Key, path.Child("s3", "key"), errs)
+		a.S3 = &s3
+	}
+	if a.HTTP != nil {
+		h := *a.HTTP
+		h.URL = s.replaceField(h.URL, path.Child("http", "url"), errs)
+		a.HTTP = &h
+	}
+	if a.Git != nil {
+		g := *a.Git
+		g.Revision = s.replaceField(g.Revision, path.Child("git", "revision"), errs)
+		a.Git = &g
+	}
+	if a.Artifactory != nil {
+		af := *a.Artifactory
+		af.URL = s.replaceField(af.URL, path.Child("artifactory", "url"), errs)
+		a.Artifactory = &af
+	}
+	if a.HDFS != nil {
+		h := *a.HDFS
+		h.Path = s.replaceField(h.Path, path.Child("hdfs", "path"), errs)
+		a.HDFS = &h
+	}
+	if a.Raw != nil {
+		a.Raw = &RawArtifact{Data: s.replaceField(a.Raw.Data, path.Child("raw", "data"), errs)}
+	}
+	if a.OSS != nil {
+		o := *a.OSS
+		o.Key = s.replaceField(o.Key, path.Child("oss", "key"), errs)
+		a.OSS = &o
+	}
+	if a.GCS != nil {
+		g := *a.GCS
+		g.Key = s.replaceField(g.Key, path.Child("gcs", "key"), errs)
+		a.GCS = &g
+	}
+	if a.Azure != nil {
+		az := *a.Azure
+		az.Blob = s.replaceField(az.Blob, path.Child("azure", "blob"), errs)
+		a.Azure = &az
+	}
+	return a
+}
@@ -0,0 +1,246 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func substitutionWorkflow(t *testing.T) *Workflow {
+	t.Helper()
+
+	tmpl := ContainerTemplate(
+		"echo",
+		WithImage("alpine:{{inputs.parameters.tag}}"),
+		WithCommand("echo", "{{workflow.name}}"),
+		WithArgs("{{inputs.parameters.message}}", "{{ item }}", "{{tasks.a.outputs.result}}"),
+		WithEnv(EnvVar{Name: "GREETING", Value: "hello {{workflow.parameters.who}}"}),
+	)
+	tmpl.Inputs = NewInputs().
+		AddParameter(Parameter{Name: "message"}).
+		AddParameter(Parameter{Name: "tag", Default: "3.18"})
+
+	wf, err := New("subst").
+		WithNamespace("argo").
+		WithEntrypoint("other").
+		WithArguments(NewArguments().AddParameter(Parameter{Name: "who", Value: "world"})).
+		WithTemplate(ContainerTemplate("other", WithImage("alpine:3.18"))).
+		WithTemplate(tmpl).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return wf
+}
+
+func TestResolve(t *testing.T) {
+	wf := substitutionWorkflow(t)
+	r := NewResolver(wf)
+
+	scope := r.Scope().
+		SetInputs(map[string]string{"message": "hi", "tag": "3.19"}).
+		SetItem(float64(2)).
+		SetTaskOutputs("a", &Outputs{Result: "done"})
+
+	got, errs := r.Resolve(&wf.Spec.Templates[1], scope)
+	if len(errs) > 0 {
+		t.Fatalf("Resolve errors: %v", errs)
+	}
+
+	if got.Container.Image != "alpine:3.19" {
+		t.Errorf("Image = %v, want alpine:3.19", got.Container.Image)
+	}
+	if strings.Join(got.Container.Command, " ") != "echo subst" {
+		t.Errorf("Command = %v, want [echo subst]", got.Container.Command)
+	}
+	if strings.Join(got.Container.Args, " ") != "hi 2 done" {
+		t.Errorf("Args = %v, want [hi 2 done]", got.Container.Args)
+	}
+	if got.Container.Env[0].Value != "hello world" {
+		t.Errorf("Env = %v, want 'hello world'", got.Container.Env[0].Value)
+	}
+	if got.Inputs.Parameters[0].Value != "hi" {
+		t.Errorf("inputs.parameters.message = %v, want hi", got.Inputs.Parameters[0].Value)
+	}
+
+	// The original template must not be modified.
+	if wf.Spec.Templates[1].Container.Args[0] != "{{inputs.parameters.message}}" {
+		t.Errorf("original template was modified: %v", wf.Spec.Templates[1].Container.Args)
+	}
+}
+
+func TestResolveTemplateTypes(t *testing.T) {
+	wf := substitutionWorkflow(t)
+	r := NewResolver(wf)
+	scope := r.Scope().SetInputs(map[string]string{"message": "hi"})
+
+	resource := ResourceTemplate("apply", ResourceActionApply, "kind: ConfigMap\nmetadata:\n  name: {{workflow.name}}\n",
+		WithResourceFlags("--namespace={{workflow.namespace}}"))
+	got, errs := r.Resolve(&resource, scope)
+	if len(errs) > 0 {
+		t.Fatalf("Resolve errors: %v", errs)
+	}
+	if !strings.Contains(got.Resource.Manifest, "name: subst") || got.Resource.Flags[0] != "--namespace=argo" {
+		t.Errorf("Resource = %+v, want the workflow name and namespace", got.Resource)
+	}
+
+	http := HTTPTemplate("notify", "https://example.com/{{workflow.name}}",
+		WithHTTPHeader("X-Message", "{{inputs.parameters.message}}"),
+		WithHTTPBody("{{workflow.parameters.who}}"))
+	got, errs = r.Resolve(&http, scope)
+	if len(errs) > 0 {
+		t.Fatalf("Resolve errors: %v", errs)
+	}
+	if got.HTTP.URL != "https://example.com/subst" || got.HTTP.Headers[0].Value != "hi" || got.HTTP.Body != "world" {
+		t.Errorf("HTTP = %+v, want resolved url, header and body", got.HTTP)
+	}
+	if http.HTTP.Headers[0].Value != "{{inputs.parameters.message}}" {
+		t.Errorf("original template was modified: %v", http.HTTP.Headers)
+	}
+
+	suspend := SuspendTemplate("wait", "{{inputs.parameters.message}}")
+	if got, _ := r.Resolve(&suspend, scope); got.Suspend.Duration != "hi" {
+		t.Errorf("Suspend = %+v, want hi", got.Suspend)
+	}
+
+	test := ContainerTemplate("test",
+		WithImage("golang:1.21"),
+		WithSidecar("proxy", WithImage("envoy"), WithArgs("--upstream={{tasks.db.ip}}")))
+	got, errs = r.Resolve(&test, scope.SetTaskIP("db", "10.0.0.7"))
+	if len(errs) > 0 {
+		t.Fatalf("Resolve errors: %v", errs)
+	}
+	if got.Sidecars[0].Args[0] != "--upstream=10.0.0.7" {
+		t.Errorf("Sidecars = %+v, want the daemon IP", got.Sidecars)
+	}
+
+	set := ContainerSetTemplate("build",
+		WithContainerNode("main", nil, WithImage("alpine:{{inputs.parameters.message}}")))
+	if got, _ := r.Resolve(&set, scope); got.ContainerSet.Containers[0].Image != "alpine:hi" {
+		t.Errorf("ContainerSet = %+v, want alpine:hi", got.ContainerSet.Containers)
+	}
+}
+
+func TestResolveUnresolved(t *testing.T) {
+	wf := substitutionWorkflow(t)
+	r := NewResolver(wf)
+
+	scope := NewScope().SetInputs(map[string]string{"message": "hi", "tag": "3.19"})
+	got, errs := r.Resolve(&wf.Spec.Templates[1], scope)
+
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want 2", errs)
+	}
+	if errs[0].Field != "spec.templates[1].container.args[1]" {
+		t.Errorf("Field = %v, want spec.templates[1].container.args[1]", errs[0].Field)
+	}
+	if errs[1].BadValue != "{{tasks.a.outputs.result}}" {
+		t.Errorf("BadValue = %v, want {{tasks.a.outputs.result}}", errs[1].BadValue)
+	}
+	if got.Container.Args[2] != "{{tasks.a.outputs.result}}" {
+		t.Errorf("unresolved reference should be left in place, got %v", got.Container.Args[2])
+	}
+}
+
+func TestResolveArguments(t *testing.T) {
+	wf := substitutionWorkflow(t)
+	r := NewResolver(wf)
+
+	args := NewArguments().
+		AddParameter(Parameter{Name: "message", Value: "{{steps.gen.outputs.parameters.out}}"}).
+		AddParameter(Parameter{Name: "count", Value: float64(3)})
+
+	scope := NewScope().SetStepOutputs("gen", &Outputs{
+		Parameters: []Parameter{{Name: "out", Value: "generated"}},
+	})
+
+	got, errs := r.ResolveArguments(args, scope, nil)
+	if len(errs) > 0 {
+		t.Fatalf("ResolveArguments errors: %v", errs)
+	}
+	if got.Parameters[0].Value != "generated" {
+		t.Errorf("message = %v, want generated", got.Parameters[0].Value)
+	}
+	if got.Parameters[1].Value != float64(3) {
+		t.Errorf("count = %v, want 3", got.Parameters[1].Value)
+	}
+}
+
+func TestResolveArtifactArguments(t *testing.T) {
+	wf := substitutionWorkflow(t)
+	r := NewResolver(wf)
+
+	args := NewArguments().
+		AddArtifact(Artifact{Name: "data", From: "{{tasks.gen.outputs.artifacts.data}}"}).
+		AddArtifact(Artifact{Name: "report", S3: &S3Artifact{Bucket: "reports", Key: "{{workflow.name}}/report"}}).
+		AddArtifact(Artifact{Name: "bad", From: "{{tasks.gen.outputs.result}}"})
+
+	scope := NewScope().Set("workflow.name", "subst").SetTaskOutputs("gen", &Outputs{
+		Result:    "not an artifact",
+		Artifacts: []Artifact{{Name: "data", Path: "/tmp/data", S3: &S3Artifact{Key: "subst/gen/data.tgz"}}},
+	})
+
+	got, errs := r.ResolveArguments(args, scope, field.NewPath("arguments"))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "arguments.artifacts[2].from") || !strings.Contains(errs[0].Error(), "must refer to an artifact") {
+		t.Errorf("errors = %v, want one for the bad from", errs)
+	}
+	if a := got.Artifacts[0]; a.From != "" || a.S3 == nil || a.S3.Key != "subst/gen/data.tgz" || a.Path != "" {
+		t.Errorf("data = %+v, want the location of the referenced artifact", a)
+	}
+	if key := got.Artifacts[1].S3.Key; key != "subst/report" {
+		t.Errorf("report key = %q, want subst/report", key)
+	}
+	if key := args.Artifacts[1].S3.Key; key != "{{workflow.name}}/report" {
+		t.Errorf("original key = %q, want it left unchanged", key)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	got := Placeholders("{{a}} and {{ b.c }} but not {{=sprig.trim(x)}}")
+	if strings.Join(got, ",") != "a,b.c" {
+		t.Errorf("Placeholders = %v, want [a b.c]", got)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"text", "text"},
+		{float64(3), "3"},
+		{1.5, "1.5"},
+		{true, "true"},
+		{map[string]interface{}{"os": "linux"}, `{"os":"linux"}`},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := FormatValue(tt.value); got != tt.want {
+			t.Errorf("FormatValue(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSetWorkflowStatus(t *testing.T) {
+	status := &WorkflowStatus{
+		Phase: PhaseFailed,
+		Nodes: map[string]Node{
+			"wf":   {ID: "wf", Name: "wf", DisplayName: "wf", Type: NodeTypeDAG, Phase: PhaseFailed, Message: "child 'wf-1' failed"},
+			"wf-1": {ID: "wf-1", Name: "wf.a", DisplayName: "a", Type: NodeTypePod, TemplateName: "echo", Phase: PhaseError, Message: "boom"},
+			"wf-2": {ID: "wf-2", Name: "wf.b", DisplayName: "b", Type: NodeTypePod, Phase: PhaseSucceeded},
+		},
+	}
+
+	scope := NewScope().SetWorkflowStatus(status)
+	if scope["workflow.status"] != PhaseFailed {
+		t.Errorf("workflow.status = %v, want %v", scope["workflow.status"], PhaseFailed)
+	}
+
+	want := `[{"displayName":"wf","message":"child 'wf-1' failed","templateName":"","phase":"Failed","podName":"","finishedAt":""},` +
+		`{"displayName":"a","message":"boom","templateName":"echo","phase":"Error","podName":"wf-1","finishedAt":""}]`
+	if scope["workflow.failures"] != want {
+		t.Errorf("workflow.failures = %v, want %v", scope["workflow.failures"], want)
+	}
+}
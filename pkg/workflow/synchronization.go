@@ -0,0 +1,105 @@
+package workflow
+
+// Synchronization limits how many workflows, or invocations of a template,
+// run at once. A mutex admits one holder at a time; a semaphore admits as
+// many as the value stored under its ConfigMap key. When both are set, both
+// must be acquired.
+type Synchronization struct {
+	Mutex     *Mutex        `json:"mutex,omitempty"`
+	Semaphore *SemaphoreRef `json:"semaphore,omitempty"`
+}
+
+// Mutex is a lock identified by name. Namespace defaults to the workflow's.
+type Mutex struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// SemaphoreRef refers to the ConfigMap key holding a semaphore's limit.
+// Namespace defaults to the workflow's.
+type SemaphoreRef struct {
+	ConfigMapKeyRef *ConfigMapKeySelector `json:"configMapKeyRef"`
+	Namespace       string                `json:"namespace,omitempty"`
+}
+
+// SynchronizationStatus lists the locks a workflow holds and waits for.
+type SynchronizationStatus struct {
+	Mutex     *MutexStatus     `json:"mutex,omitempty"`
+	Semaphore *SemaphoreStatus `json:"semaphore,omitempty"`
+}
+
+// MutexStatus lists held and awaited mutexes. For a held mutex, Holder is
+// the workflow name or node ID holding it; for an awaited one, it is the
+// current holder.
+type MutexStatus struct {
+	Holding []MutexHolding `json:"holding,omitempty"`
+	Waiting []MutexHolding `json:"waiting,omitempty"`
+}
+
+// MutexHolding pairs a mutex with a holder.
+type MutexHolding struct {
+	Mutex  string `json:"mutex,omitempty"`
+	Holder string `json:"holder,omitempty"`
+}
+
+// SemaphoreStatus lists held and awaited semaphores, like MutexStatus.
+type SemaphoreStatus struct {
+	Holding []SemaphoreHolding `json:"holding,omitempty"`
+	Waiting []SemaphoreHolding `json:"waiting,omitempty"`
+}
+
+// SemaphoreHolding pairs a semaphore with its holders.
+type SemaphoreHolding struct {
+	Semaphore string   `json:"semaphore,omitempty"`
+	Holders   []string `json:"holders,omitempty"`
+}
+
+// NodeSynchronizationStatus names the lock a node is waiting for.
+type NodeSynchronizationStatus struct {
+	Waiting string `json:"waiting,omitempty"`
+}
+
+// WithMutex lets only one invocation of a template, across all workflows,
+// run at a time.
+func WithMutex(name string) TemplateOption {
+	return func(t *Template) {
+		t.Synchronization = withMutex(t.Synchronization, name)
+	}
+}
+
+// WithSemaphore limits how many invocations of a template run at a time to
+// the value stored under key in the named ConfigMap.
+func WithSemaphore(configMap, key string) TemplateOption {
+	return func(t *Template) {
+		t.Synchronization = withSemaphore(t.Synchronization, configMap, key)
+	}
+}
+
+// WithMutex lets only one workflow holding the named mutex run at a time.
+func (b *Builder) WithMutex(name string) *Builder {
+	b.synchronization = withMutex(b.synchronization, name)
+	return b
+}
+
+// WithSemaphore limits how many workflows holding the semaphore run at a
+// time to the value stored under key in the named ConfigMap.
+func (b *Builder) WithSemaphore(configMap, key string) *Builder {
+	b.synchronization = withSemaphore(b.synchronization, configMap, key)
+	return b
+}
+
+func withMutex(s *Synchronization, name string) *Synchronization {
+	if s == nil {
+		s = &Synchronization{}
+	}
+	s.Mutex = &Mutex{Name: name}
+	return s
+}
+
+func withSemaphore(s *Synchronization, configMap, key string) *Synchronization {
+	if s == nil {
+		s = &Synchronization{}
+	}
+	s.Semaphore = &SemaphoreRef{ConfigMapKeyRef: &ConfigMapKeySelector{Name: configMap, Key: key}}
+	return s
+}
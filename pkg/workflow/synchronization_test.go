@@ -0,0 +1,69 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSynchronization(t *testing.T) {
+	wf, err := New("nightly").
+		WithEntrypoint("load").
+		WithMutex("sales-table").
+		WithTemplate(ContainerTemplate("load",
+			WithImage("alpine:3.18"),
+			WithSemaphore("limits", "warehouse"),
+			WithMutex("load"))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if m := wf.Spec.Synchronization.Mutex; m == nil || m.Name != "sales-table" {
+		t.Errorf("workflow mutex = %+v, want sales-table", m)
+	}
+	s := wf.Spec.Templates[0].Synchronization
+	if s.Mutex == nil || s.Mutex.Name != "load" || s.Semaphore == nil || s.Semaphore.ConfigMapKeyRef.Key != "warehouse" {
+		t.Errorf("template synchronization = %+v, want a mutex and a semaphore", s)
+	}
+
+	data, err := wf.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML failed: %v", err)
+	}
+	for _, want := range []string{"synchronization:\n    mutex:\n      name: sales-table", "configMapKeyRef:\n          key: warehouse\n          name: limits"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("YAML missing %q:\n%s", want, data)
+		}
+	}
+	loaded, err := FromYAMLStrict(data)
+	if err != nil {
+		t.Fatalf("FromYAMLStrict failed: %v", err)
+	}
+	if s := loaded.Spec.Templates[0].Synchronization; s == nil || s.Semaphore.ConfigMapKeyRef.Name != "limits" {
+		t.Errorf("synchronization = %+v, want it to round-trip", s)
+	}
+}
+
+func TestValidateSynchronization(t *testing.T) {
+	wf := &Workflow{}
+	wf.Name = "sync"
+	wf.Spec.Entrypoint = "main"
+	wf.Spec.Synchronization = &Synchronization{}
+	wf.Spec.Templates = []Template{
+		ContainerTemplate("main", WithImage("alpine:3.18"), WithMutex(""), WithSemaphore("Limits", "a/b")),
+	}
+	wf.Spec.Templates[0].Synchronization.Mutex.Namespace = "Team_A"
+
+	errs := Validate(wf).ToAggregate().Error()
+	for _, want := range []string{
+		"spec.synchronization: Required value: mutex or semaphore must be set",
+		"spec.templates[0].synchronization.mutex.name: Required value",
+		`spec.templates[0].synchronization.mutex.namespace: Invalid value: "Team_A"`,
+		`spec.templates[0].synchronization.semaphore.configMapKeyRef.name: Invalid value: "Limits"`,
+		`spec.templates[0].synchronization.semaphore.configMapKeyRef.key: Invalid value: "a/b"`,
+	} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errors missing %q:\n%s", want, errs)
+		}
+	}
+}
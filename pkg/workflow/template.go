@@ -1,5 +1,9 @@
 package workflow
 
+import (
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
 // TemplateBuilder provides helper functions for creating common template types.
 // Unlike Hera's decorators, these are explicit constructor functions.
 
@@ -123,6 +127,246 @@ func WithScriptResources(resources *Resources) ScriptOption {
 	}
 }
 
+// ResourceTemplate creates a resource template that performs action, such
+// as ResourceActionApply, on manifest.
+func ResourceTemplate(name, action, manifest string, opts ...interface{}) Template {
+	tmpl := Template{
+		Name:     name,
+		Resource: &Resource{Action: action, Manifest: manifest},
+	}
+
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case ResourceOption:
+			o(tmpl.Resource)
+		case TemplateOption:
+			o(&tmpl)
+		}
+	}
+
+	return tmpl
+}
+
+// ResourceOption is a functional option for resource configuration.
+type ResourceOption func(*Resource)
+
+// WithResourceSuccessCondition sets the label selector that marks the
+// resource as succeeded, e.g. "status.succeeded > 0".
+func WithResourceSuccessCondition(condition string) ResourceOption {
+	return func(r *Resource) {
+		r.SuccessCondition = condition
+	}
+}
+
+// WithResourceFailureCondition sets the label selector that marks the
+// resource as failed, e.g. "status.failed > 3".
+func WithResourceFailureCondition(condition string) ResourceOption {
+	return func(r *Resource) {
+		r.FailureCondition = condition
+	}
+}
+
+// WithMergeStrategy sets how a patch action merges the manifest.
+func WithMergeStrategy(strategy string) ResourceOption {
+	return func(r *Resource) {
+		r.MergeStrategy = strategy
+	}
+}
+
+// WithOwnerReference makes the workflow the owner of the resource, so the
+// resource is deleted with it.
+func WithOwnerReference() ResourceOption {
+	return func(r *Resource) {
+		r.SetOwnerReference = true
+	}
+}
+
+// WithResourceFlags passes extra flags to kubectl.
+func WithResourceFlags(flags ...string) ResourceOption {
+	return func(r *Resource) {
+		r.Flags = append(r.Flags, flags...)
+	}
+}
+
+// SuspendTemplate creates a suspend template. An empty duration suspends
+// the workflow until it is resumed, as for a manual approval.
+func SuspendTemplate(name, duration string, opts ...TemplateOption) Template {
+	tmpl := Template{
+		Name:    name,
+		Suspend: &Suspend{Duration: duration},
+	}
+
+	for _, opt := range opts {
+		opt(&tmpl)
+	}
+
+	return tmpl
+}
+
+// HTTPTemplate creates an http template that sends a GET request to url
+// unless WithHTTPMethod says otherwise.
+func HTTPTemplate(name, url string, opts ...interface{}) Template {
+	tmpl := Template{
+		Name: name,
+		HTTP: &HTTP{URL: url},
+	}
+
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case HTTPOption:
+			o(tmpl.HTTP)
+		case TemplateOption:
+			o(&tmpl)
+		}
+	}
+
+	return tmpl
+}
+
+// HTTPOption is a functional option for http configuration.
+type HTTPOption func(*HTTP)
+
+// WithHTTPMethod sets the request method.
+func WithHTTPMethod(method string) HTTPOption {
+	return func(h *HTTP) {
+		h.Method = method
+	}
+}
+
+// WithHTTPHeader adds a request header.
+func WithHTTPHeader(name, value string) HTTPOption {
+	return func(h *HTTP) {
+		h.Headers = append(h.Headers, HTTPHeader{Name: name, Value: value})
+	}
+}
+
+// WithHTTPSecretHeader adds a request header read from a Secret.
+func WithHTTPSecretHeader(name, secret, key string) HTTPOption {
+	return func(h *HTTP) {
+		h.Headers = append(h.Headers, HTTPHeader{
+			Name:      name,
+			ValueFrom: &HTTPHeaderSource{SecretKeyRef: &SecretKeySelector{Name: secret, Key: key}},
+		})
+	}
+}
+
+// WithHTTPBody sets the request body.
+func WithHTTPBody(body string) HTTPOption {
+	return func(h *HTTP) {
+		h.Body = body
+	}
+}
+
+// WithHTTPSuccessCondition sets the expression that decides whether the
+// response counts as success, e.g. "response.statusCode == 201".
+func WithHTTPSuccessCondition(condition string) HTTPOption {
+	return func(h *HTTP) {
+		h.SuccessCondition = condition
+	}
+}
+
+// WithHTTPTimeout limits how many seconds the request may take.
+func WithHTTPTimeout(seconds int64) HTTPOption {
+	return func(h *HTTP) {
+		h.TimeoutSeconds = &seconds
+	}
+}
+
+// DataTemplate creates a data template that lists the paths of artifact
+// and applies each expression in turn to the list.
+func DataTemplate(name string, artifact Artifact, expressions ...string) Template {
+	data := &Data{Source: DataSource{ArtifactPaths: &ArtifactPaths{Artifact: artifact}}}
+	for _, e := range expressions {
+		data.Transformation = append(data.Transformation, TransformationStep{Expression: e})
+	}
+	return Template{Name: name, Data: data}
+}
+
+// ContainerSetTemplate creates a containerSet template, which runs the
+// containers added with WithContainerNode in one pod.
+func ContainerSetTemplate(name string, opts ...interface{}) Template {
+	tmpl := Template{
+		Name:         name,
+		ContainerSet: &ContainerSet{},
+	}
+
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case ContainerSetOption:
+			o(tmpl.ContainerSet)
+		case TemplateOption:
+			o(&tmpl)
+		}
+	}
+
+	return tmpl
+}
+
+// ContainerSetOption is a functional option for containerSet configuration.
+type ContainerSetOption func(*ContainerSet)
+
+// WithContainerNode adds a container that starts once the containers named
+// in dependencies have succeeded.
+func WithContainerNode(name string, dependencies []string, opts ...ContainerOption) ContainerSetOption {
+	return func(cs *ContainerSet) {
+		cs.Containers = append(cs.Containers, ContainerNode{Container: newContainer(name, opts), Dependencies: dependencies})
+	}
+}
+
+// WithContainerSetVolumeMounts mounts volumes into every container of the
+// set.
+func WithContainerSetVolumeMounts(mounts ...VolumeMount) ContainerSetOption {
+	return func(cs *ContainerSet) {
+		cs.VolumeMounts = append(cs.VolumeMounts, mounts...)
+	}
+}
+
+// WithContainerSetRetries retries a failed container up to retries times,
+// waiting duration, e.g. "10s", between attempts.
+func WithContainerSetRetries(retries int, duration string) ContainerSetOption {
+	return func(cs *ContainerSet) {
+		r := intstr.FromInt(retries)
+		cs.RetryStrategy = &ContainerSetRetryStrategy{Duration: duration, Retries: &r}
+	}
+}
+
+// WithInitContainer adds a container that runs to completion before the
+// template's main container starts. Init containers run in the order they
+// are added.
+func WithInitContainer(name string, opts ...ContainerOption) TemplateOption {
+	return func(t *Template) {
+		t.InitContainers = append(t.InitContainers, UserContainer{Container: newContainer(name, opts)})
+	}
+}
+
+// WithSidecar adds a container that runs next to the template's main
+// container, such as a database for integration tests. Sidecars are
+// stopped once the main container exits.
+func WithSidecar(name string, opts ...ContainerOption) TemplateOption {
+	return func(t *Template) {
+		t.Sidecars = append(t.Sidecars, UserContainer{Container: newContainer(name, opts)})
+	}
+}
+
+// WithDaemon runs the template as a daemon: once it is up, the tasks or
+// steps that follow it start while it keeps running, and it is stopped
+// when the template that called it completes. Dependents reach it through
+// {{tasks.<name>.ip}} or {{steps.<name>.ip}}.
+func WithDaemon() TemplateOption {
+	return func(t *Template) {
+		daemon := true
+		t.Daemon = &daemon
+	}
+}
+
+func newContainer(name string, opts []ContainerOption) Container {
+	c := Container{Name: name}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
 // WithInputs adds inputs to a template.
 func WithInputs(inputs *Inputs) TemplateOption {
 	return func(t *Template) {
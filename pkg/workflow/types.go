@@ -2,6 +2,7 @@ package workflow
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // Workflow represents an Argo Workflow resource.
@@ -14,50 +15,300 @@ type Workflow struct {
 }
 
 // WorkflowSpec defines the desired state of a Workflow.
+// A workflow that references a WorkflowTemplate through WorkflowTemplateRef
+// may leave Entrypoint and Templates empty.
 type WorkflowSpec struct {
-	Entrypoint         string      `json:"entrypoint"`
-	Templates          []Template  `json:"templates"`
-	Arguments          *Arguments  `json:"arguments,omitempty"`
-	ServiceAccountName string      `json:"serviceAccountName,omitempty"`
-	Parallelism        *int32      `json:"parallelism,omitempty"`
-	ActiveDeadline     *int64      `json:"activeDeadlineSeconds,omitempty"`
-	TTL                *int32      `json:"ttlSecondsAfterFinished,omitempty"`
+	Entrypoint            string                  `json:"entrypoint,omitempty"`
+	Templates             []Template              `json:"templates,omitempty"`
+	Arguments             *Arguments              `json:"arguments,omitempty"`
+	ServiceAccountName    string                  `json:"serviceAccountName,omitempty"`
+	NodeSelector          map[string]string       `json:"nodeSelector,omitempty"`
+	Tolerations           []Toleration            `json:"tolerations,omitempty"`
+	Affinity              *Affinity               `json:"affinity,omitempty"`
+	SecurityContext       *PodSecurityContext     `json:"securityContext,omitempty"`
+	PodSpecPatch          string                  `json:"podSpecPatch,omitempty"`
+	PodPriorityClassName  string                  `json:"podPriorityClassName,omitempty"`
+	PodMetadata           *Metadata               `json:"podMetadata,omitempty"`
+	Volumes               []Volume                `json:"volumes,omitempty"`
+	VolumeClaimTemplates  []PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
+	ArtifactRepositoryRef *ArtifactRepositoryRef  `json:"artifactRepositoryRef,omitempty"`
+	ArtifactGC            *ArtifactGC             `json:"artifactGC,omitempty"`
+	Parallelism           *int32                  `json:"parallelism,omitempty"`
+	ActiveDeadline        *int64                  `json:"activeDeadlineSeconds,omitempty"`
+	TTL                   *int32                  `json:"ttlSecondsAfterFinished,omitempty"`
+	TTLStrategy           *TTLStrategy            `json:"ttlStrategy,omitempty"`
+	PodGC                 *PodGC                  `json:"podGC,omitempty"`
+	OnExit                string                  `json:"onExit,omitempty"`
+	Synchronization       *Synchronization        `json:"synchronization,omitempty"`
+	WorkflowTemplateRef   *WorkflowTemplateRef    `json:"workflowTemplateRef,omitempty"`
+}
+
+// TTLStrategy limits how long a finished workflow is kept, depending on
+// how it finished. Unset fields keep the workflow.
+type TTLStrategy struct {
+	SecondsAfterCompletion *int32 `json:"secondsAfterCompletion,omitempty"`
+	SecondsAfterSuccess    *int32 `json:"secondsAfterSuccess,omitempty"`
+	SecondsAfterFailure    *int32 `json:"secondsAfterFailure,omitempty"`
+}
+
+// PodGC decides when the pods of a workflow are deleted. LabelSelector
+// restricts deletion to matching pods, and DeleteDelayDuration delays it.
+type PodGC struct {
+	Strategy            string                `json:"strategy,omitempty"`
+	LabelSelector       *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	DeleteDelayDuration string                `json:"deleteDelayDuration,omitempty"`
+}
+
+// Pod GC strategies.
+const (
+	PodGCOnPodCompletion      = "OnPodCompletion"
+	PodGCOnPodSuccess         = "OnPodSuccess"
+	PodGCOnWorkflowCompletion = "OnWorkflowCompletion"
+	PodGCOnWorkflowSuccess    = "OnWorkflowSuccess"
+)
+
+// WorkflowTemplate is a reusable workflow definition stored in a namespace.
+// Workflows run it through WorkflowSpec.WorkflowTemplateRef, or call single
+// templates from it through a task or step TemplateRef.
+type WorkflowTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              WorkflowSpec `json:"spec"`
+}
+
+// ClusterWorkflowTemplate is a WorkflowTemplate shared by all namespaces.
+type ClusterWorkflowTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              WorkflowSpec `json:"spec"`
+}
+
+// CronWorkflow submits a workflow on a cron schedule.
+type CronWorkflow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CronWorkflowSpec `json:"spec"`
+}
+
+// CronWorkflowSpec defines the schedule and the workflow to submit.
+type CronWorkflowSpec struct {
+	WorkflowSpec            WorkflowSpec `json:"workflowSpec"`
+	Schedule                string       `json:"schedule"`
+	Timezone                string       `json:"timezone,omitempty"`
+	ConcurrencyPolicy       string       `json:"concurrencyPolicy,omitempty"`
+	StartingDeadlineSeconds *int64       `json:"startingDeadlineSeconds,omitempty"`
+}
+
+// Concurrency policies for CronWorkflows, deciding what happens when a run
+// is due while the previous one is still active.
+const (
+	ConcurrencyPolicyAllow   = "Allow"
+	ConcurrencyPolicyForbid  = "Forbid"
+	ConcurrencyPolicyReplace = "Replace"
+)
+
+// WorkflowTemplateRef references a WorkflowTemplate, or with ClusterScope a
+// ClusterWorkflowTemplate, to run as a whole.
+type WorkflowTemplateRef struct {
+	Name         string `json:"name"`
+	ClusterScope bool   `json:"clusterScope,omitempty"`
+}
+
+// TemplateRef references a single template of a WorkflowTemplate, or with
+// ClusterScope a ClusterWorkflowTemplate.
+type TemplateRef struct {
+	Name         string `json:"name"`
+	Template     string `json:"template"`
+	ClusterScope bool   `json:"clusterScope,omitempty"`
 }
 
 // Template defines a workflow template.
 // Unlike Hera's class-based approach, we use composition with a union-like structure.
 type Template struct {
-	Name      string         `json:"name"`
-	Inputs    *Inputs        `json:"inputs,omitempty"`
-	Outputs   *Outputs       `json:"outputs,omitempty"`
-	Container *Container     `json:"container,omitempty"`
-	Script    *Script        `json:"script,omitempty"`
-	DAG       *DAG           `json:"dag,omitempty"`
-	Steps          *[][]StepGroup  `json:"steps,omitempty"`
-	RetryStrategy  *RetryStrategy  `json:"retryStrategy,omitempty"`
-	Timeout        *TimeoutPolicy  `json:"timeout,omitempty"`
+	Name                  string              `json:"name"`
+	Inputs                *Inputs             `json:"inputs,omitempty"`
+	Outputs               *Outputs            `json:"outputs,omitempty"`
+	Container             *Container          `json:"container,omitempty"`
+	Script                *Script             `json:"script,omitempty"`
+	DAG                   *DAG                `json:"dag,omitempty"`
+	Steps                 *[][]StepGroup      `json:"steps,omitempty"`
+	Resource              *Resource           `json:"resource,omitempty"`
+	Suspend               *Suspend            `json:"suspend,omitempty"`
+	HTTP                  *HTTP               `json:"http,omitempty"`
+	Data                  *Data               `json:"data,omitempty"`
+	ContainerSet          *ContainerSet       `json:"containerSet,omitempty"`
+	RetryStrategy         *RetryStrategy      `json:"retryStrategy,omitempty"`
+	Timeout               *TimeoutPolicy      `json:"timeout,omitempty"`
+	ActiveDeadlineSeconds *intstr.IntOrString `json:"activeDeadlineSeconds,omitempty"`
+	Memoize               *Memoize            `json:"memoize,omitempty"`
+	Synchronization       *Synchronization    `json:"synchronization,omitempty"`
+	NodeSelector          map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations           []Toleration        `json:"tolerations,omitempty"`
+	Affinity              *Affinity           `json:"affinity,omitempty"`
+	SecurityContext       *PodSecurityContext `json:"securityContext,omitempty"`
+	PodSpecPatch          string              `json:"podSpecPatch,omitempty"`
+	PriorityClassName     string              `json:"priorityClassName,omitempty"`
+	ServiceAccountName    string              `json:"serviceAccountName,omitempty"`
+	Metadata              *Metadata           `json:"metadata,omitempty"`
+	Volumes               []Volume            `json:"volumes,omitempty"`
+	InitContainers        []UserContainer     `json:"initContainers,omitempty"`
+	Sidecars              []UserContainer     `json:"sidecars,omitempty"`
+	Daemon                *bool               `json:"daemon,omitempty"`
 }
 
 // Container defines a container template.
 type Container struct {
-	Name       string        `json:"name,omitempty"`
-	Image      string        `json:"image"`
-	Command    []string      `json:"command,omitempty"`
-	Args       []string      `json:"args,omitempty"`
-	Env        []EnvVar      `json:"env,omitempty"`
-	Resources  *Resources    `json:"resources,omitempty"`
-	WorkingDir string        `json:"workingDir,omitempty"`
+	Name            string           `json:"name,omitempty"`
+	Image           string           `json:"image"`
+	Command         []string         `json:"command,omitempty"`
+	Args            []string         `json:"args,omitempty"`
+	Env             []EnvVar         `json:"env,omitempty"`
+	Resources       *Resources       `json:"resources,omitempty"`
+	WorkingDir      string           `json:"workingDir,omitempty"`
+	SecurityContext *SecurityContext `json:"securityContext,omitempty"`
+	VolumeMounts    []VolumeMount    `json:"volumeMounts,omitempty"`
 }
 
 // Script defines a script template.
 // Different from Hera's @script decorator - this is explicit.
 type Script struct {
-	Image      string     `json:"image"`
-	Command    []string   `json:"command,omitempty"`
-	Source     string     `json:"source"`
-	Env        []EnvVar   `json:"env,omitempty"`
-	Resources  *Resources `json:"resources,omitempty"`
-	WorkingDir string     `json:"workingDir,omitempty"`
+	Image        string        `json:"image"`
+	Command      []string      `json:"command,omitempty"`
+	Source       string        `json:"source"`
+	Env          []EnvVar      `json:"env,omitempty"`
+	Resources    *Resources    `json:"resources,omitempty"`
+	WorkingDir   string        `json:"workingDir,omitempty"`
+	VolumeMounts []VolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// UserContainer is an init container or sidecar running in a template's
+// pod next to its main container. With MirrorVolumeMounts, it also gets
+// the main container's volume mounts.
+type UserContainer struct {
+	Container          `json:",inline"`
+	MirrorVolumeMounts *bool `json:"mirrorVolumeMounts,omitempty"`
+}
+
+// ContainerSet defines a containerSet template, which runs several
+// containers in one pod. A container starts once the containers it depends
+// on have succeeded; the outputs of the template are those of the
+// container named "main".
+type ContainerSet struct {
+	Containers    []ContainerNode            `json:"containers"`
+	VolumeMounts  []VolumeMount              `json:"volumeMounts,omitempty"` // shared by all containers
+	RetryStrategy *ContainerSetRetryStrategy `json:"retryStrategy,omitempty"`
+}
+
+// ContainerNode is a container of a containerSet template.
+type ContainerNode struct {
+	Container    `json:",inline"`
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// ContainerSetRetryStrategy retries failed containers within the pod,
+// waiting Duration between attempts.
+type ContainerSetRetryStrategy struct {
+	Duration string              `json:"duration,omitempty"`
+	Retries  *intstr.IntOrString `json:"retries"`
+}
+
+// Resource defines a resource template, which acts on a Kubernetes
+// manifest the way kubectl does. Not to be confused with Resources, the
+// compute requirements of a container.
+// SuccessCondition and FailureCondition are label selectors matched
+// against the resource, e.g. "status.succeeded > 0".
+type Resource struct {
+	Action            string        `json:"action"`                  // get, create, apply, delete, replace, patch
+	MergeStrategy     string        `json:"mergeStrategy,omitempty"` // strategic, merge, json; patch only
+	Manifest          string        `json:"manifest,omitempty"`
+	ManifestFrom      *ManifestFrom `json:"manifestFrom,omitempty"`
+	SetOwnerReference bool          `json:"setOwnerReference,omitempty"`
+	SuccessCondition  string        `json:"successCondition,omitempty"`
+	FailureCondition  string        `json:"failureCondition,omitempty"`
+	Flags             []string      `json:"flags,omitempty"`
+}
+
+// ManifestFrom reads a resource template's manifest from an input artifact.
+type ManifestFrom struct {
+	Artifact *Artifact `json:"artifact"`
+}
+
+// Resource actions.
+const (
+	ResourceActionGet     = "get"
+	ResourceActionCreate  = "create"
+	ResourceActionApply   = "apply"
+	ResourceActionDelete  = "delete"
+	ResourceActionReplace = "replace"
+	ResourceActionPatch   = "patch"
+)
+
+// Merge strategies of a patch resource action.
+const (
+	MergeStrategyStrategic = "strategic"
+	MergeStrategyMerge     = "merge"
+	MergeStrategyJSON      = "json"
+)
+
+// Suspend defines a suspend template, which pauses the workflow until it
+// is resumed or, if set, Duration has passed.
+type Suspend struct {
+	Duration string `json:"duration,omitempty"` // e.g., "30m"
+}
+
+// HTTP defines an http template, which calls an endpoint from the
+// controller instead of a pod. SuccessCondition is an expression over the
+// response, e.g. "response.statusCode == 201".
+type HTTP struct {
+	Method             string          `json:"method,omitempty"`
+	URL                string          `json:"url"`
+	Headers            []HTTPHeader    `json:"headers,omitempty"`
+	TimeoutSeconds     *int64          `json:"timeoutSeconds,omitempty"`
+	SuccessCondition   string          `json:"successCondition,omitempty"`
+	Body               string          `json:"body,omitempty"`
+	BodyFrom           *HTTPBodySource `json:"bodyFrom,omitempty"`
+	InsecureSkipVerify bool            `json:"insecureSkipVerify,omitempty"`
+}
+
+// HTTPHeader is a request header of an http template.
+type HTTPHeader struct {
+	Name      string            `json:"name"`
+	Value     string            `json:"value,omitempty"`
+	ValueFrom *HTTPHeaderSource `json:"valueFrom,omitempty"`
+}
+
+// HTTPHeaderSource reads a header value from a Secret.
+type HTTPHeaderSource struct {
+	SecretKeyRef *SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// HTTPBodySource holds a raw request body.
+type HTTPBodySource struct {
+	Bytes []byte `json:"bytes,omitempty"`
+}
+
+// Data defines a data template, which reads a list of artifact paths and
+// filters or maps it through a series of expressions.
+type Data struct {
+	Source         DataSource           `json:"source"`
+	Transformation []TransformationStep `json:"transformation"`
+}
+
+// DataSource is where a data template reads its input from.
+type DataSource struct {
+	ArtifactPaths *ArtifactPaths `json:"artifactPaths,omitempty"`
+}
+
+// ArtifactPaths lists the paths of an artifact, such as the keys under an
+// S3 prefix.
+type ArtifactPaths struct {
+	Artifact `json:",inline"`
+}
+
+// TransformationStep is one expression of a data template, e.g.
+// `filter(data, {# endsWith ".csv"})`.
+type TransformationStep struct {
+	Expression string `json:"expression"`
 }
 
 // DAG defines a directed acyclic graph template.
@@ -68,19 +319,55 @@ type DAG struct {
 
 // DAGTask defines a single task in a DAG.
 type DAGTask struct {
-	Name         string      `json:"name"`
-	Template     string      `json:"template"`
-	Dependencies []string    `json:"dependencies,omitempty"`
-	Arguments    *Arguments  `json:"arguments,omitempty"`
-	When         string      `json:"when,omitempty"`
+	Name         string         `json:"name"`
+	Template     string         `json:"template,omitempty"`
+	TemplateRef  *TemplateRef   `json:"templateRef,omitempty"`
+	Dependencies []string       `json:"dependencies,omitempty"`
+	Depends      string         `json:"depends,omitempty"`
+	Arguments    *Arguments     `json:"arguments,omitempty"`
+	When         string         `json:"when,omitempty"`
+	WithItems    []interface{}  `json:"withItems,omitempty"`
+	WithParam    string         `json:"withParam,omitempty"`
+	WithSequence *Sequence      `json:"withSequence,omitempty"`
+	Hooks        LifecycleHooks `json:"hooks,omitempty"`
 }
 
 // StepGroup represents a group of parallel steps.
 type StepGroup struct {
-	Name      string     `json:"name"`
-	Template  string     `json:"template"`
-	Arguments *Arguments `json:"arguments,omitempty"`
-	When      string     `json:"when,omitempty"`
+	Name         string         `json:"name"`
+	Template     string         `json:"template,omitempty"`
+	TemplateRef  *TemplateRef   `json:"templateRef,omitempty"`
+	Arguments    *Arguments     `json:"arguments,omitempty"`
+	When         string         `json:"when,omitempty"`
+	WithItems    []interface{}  `json:"withItems,omitempty"`
+	WithParam    string         `json:"withParam,omitempty"`
+	WithSequence *Sequence      `json:"withSequence,omitempty"`
+	Hooks        LifecycleHooks `json:"hooks,omitempty"`
+}
+
+// ExitHook is the name of the hook that runs when a task or step completes,
+// whatever its outcome.
+const ExitHook = "exit"
+
+// LifecycleHooks maps a hook name to the template it invokes. The "exit"
+// hook always runs; any other hook runs when its expression holds.
+type LifecycleHooks map[string]LifecycleHook
+
+// LifecycleHook invokes a template in response to a lifecycle event.
+type LifecycleHook struct {
+	Template   string     `json:"template"`
+	Arguments  *Arguments `json:"arguments,omitempty"`
+	Expression string     `json:"expression,omitempty"`
+}
+
+// Sequence generates the numbers a task or step loops over.
+// Either Count or End is set; Start defaults to 0. Each value may also be a
+// template placeholder such as "{{inputs.parameters.n}}".
+type Sequence struct {
+	Count  *intstr.IntOrString `json:"count,omitempty"`
+	Start  *intstr.IntOrString `json:"start,omitempty"`
+	End    *intstr.IntOrString `json:"end,omitempty"`
+	Format string              `json:"format,omitempty"`
 }
 
 // Arguments contains workflow or template arguments.
@@ -100,6 +387,7 @@ type Outputs struct {
 	Parameters []Parameter `json:"parameters,omitempty"`
 	Artifacts  []Artifact  `json:"artifacts,omitempty"`
 	Result     string      `json:"result,omitempty"`
+	ExitCode   *string     `json:"exitCode,omitempty"`
 }
 
 // Parameter defines a workflow parameter.
@@ -120,35 +408,6 @@ type ValueFrom struct {
 	Parameter       string `json:"parameter,omitempty"`
 }
 
-// Artifact defines a workflow artifact.
-type Artifact struct {
-	Name string         `json:"name"`
-	Path string         `json:"path,omitempty"`
-	From string         `json:"from,omitempty"`
-	S3   *S3Artifact    `json:"s3,omitempty"`
-	HTTP *HTTPArtifact  `json:"http,omitempty"`
-	Git  *GitArtifact   `json:"git,omitempty"`
-}
-
-// S3Artifact defines an S3 artifact location.
-type S3Artifact struct {
-	Endpoint string `json:"endpoint,omitempty"`
-	Bucket   string `json:"bucket"`
-	Key      string `json:"key"`
-	Region   string `json:"region,omitempty"`
-}
-
-// HTTPArtifact defines an HTTP artifact location.
-type HTTPArtifact struct {
-	URL string `json:"url"`
-}
-
-// GitArtifact defines a Git artifact location.
-type GitArtifact struct {
-	Repo     string `json:"repo"`
-	Revision string `json:"revision,omitempty"`
-}
-
 // EnvVar represents an environment variable.
 type EnvVar struct {
 	Name      string         `json:"name"`
@@ -185,20 +444,69 @@ type ResourceList map[string]string
 
 // WorkflowStatus represents the status of a workflow.
 type WorkflowStatus struct {
-	Phase      string           `json:"phase"`
-	StartedAt  metav1.Time      `json:"startedAt,omitempty"`
-	FinishedAt metav1.Time      `json:"finishedAt,omitempty"`
-	Message    string           `json:"message,omitempty"`
-	Nodes      map[string]Node  `json:"nodes,omitempty"`
+	Phase           string                 `json:"phase"`
+	StartedAt       metav1.Time            `json:"startedAt,omitempty"`
+	FinishedAt      metav1.Time            `json:"finishedAt,omitempty"`
+	Message         string                 `json:"message,omitempty"`
+	Nodes           map[string]Node        `json:"nodes,omitempty"`
+	Synchronization *SynchronizationStatus `json:"synchronization,omitempty"`
 }
 
 // Node represents a workflow execution node.
 type Node struct {
-	ID         string      `json:"id"`
-	Name       string      `json:"name"`
-	Type       string      `json:"type"`
-	Phase      string      `json:"phase"`
-	StartedAt  metav1.Time `json:"startedAt,omitempty"`
-	FinishedAt metav1.Time `json:"finishedAt,omitempty"`
-	Message    string      `json:"message,omitempty"`
+	ID                    string                     `json:"id"`
+	Name                  string                     `json:"name"`
+	DisplayName           string                     `json:"displayName,omitempty"`
+	Type                  string                     `json:"type"`
+	TemplateName          string                     `json:"templateName,omitempty"`
+	Phase                 string                     `json:"phase"`
+	BoundaryID            string                     `json:"boundaryID,omitempty"`
+	StartedAt             metav1.Time                `json:"startedAt,omitempty"`
+	FinishedAt            metav1.Time                `json:"finishedAt,omitempty"`
+	Message               string                     `json:"message,omitempty"`
+	Outputs               *Outputs                   `json:"outputs,omitempty"`
+	Children              []string                   `json:"children,omitempty"`
+	MemoizationStatus     *MemoizationStatus         `json:"memoizationStatus,omitempty"`
+	SynchronizationStatus *NodeSynchronizationStatus `json:"synchronizationStatus,omitempty"`
+	Daemoned              *bool                      `json:"daemoned,omitempty"`
+	PodIP                 string                     `json:"podIP,omitempty"`
+}
+
+// MemoizationStatus records whether a memoized node's outputs came from
+// the cache.
+type MemoizationStatus struct {
+	Hit       bool   `json:"hit"`
+	Key       string `json:"key"`
+	CacheName string `json:"cacheName"`
+}
+
+// Node and workflow phases, matching the values reported by the Argo server.
+const (
+	PhasePending   = "Pending"
+	PhaseRunning   = "Running"
+	PhaseSucceeded = "Succeeded"
+	PhaseFailed    = "Failed"
+	PhaseError     = "Error"
+	PhaseSkipped   = "Skipped"
+	PhaseOmitted   = "Omitted"
+)
+
+// Node types, matching the values reported by the Argo server.
+const (
+	NodeTypePod       = "Pod"
+	NodeTypeSteps     = "Steps"
+	NodeTypeStepGroup = "StepGroup"
+	NodeTypeDAG       = "DAG"
+	NodeTypeTaskGroup = "TaskGroup"
+	NodeTypeRetry     = "Retry"
+	NodeTypeSkipped   = "Skipped"
+)
+
+// Fulfilled reports whether a phase is terminal.
+func (n Node) Fulfilled() bool {
+	switch n.Phase {
+	case PhaseSucceeded, PhaseFailed, PhaseError, PhaseSkipped, PhaseOmitted:
+		return true
+	}
+	return false
 }
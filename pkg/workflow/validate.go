@@ -0,0 +1,1399 @@
+package workflow
+
+import (
+	"fmt"
+	pathpkg "path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// Validate performs a whole-workflow semantic check and reports every
+// problem it finds, each with the path of the offending field, e.g.
+// spec.templates[2].dag.tasks[1].template. Unlike Builder.Build, which
+// stops at the first error, this is meant for linting manifests in CI.
+func Validate(wf *Workflow) field.ErrorList {
+	v := newValidator()
+	v.validateMeta(field.NewPath("metadata"), &wf.ObjectMeta, true)
+	v.validateSpec(field.NewPath("spec"), &wf.Spec, true)
+	return v.errs
+}
+
+// ValidateWorkflowTemplate checks a WorkflowTemplate the way Validate checks
+// a Workflow. The entrypoint is optional, since a WorkflowTemplate may only
+// provide templates for others to reference.
+func ValidateWorkflowTemplate(wft *WorkflowTemplate) field.ErrorList {
+	v := newValidator()
+	v.validateMeta(field.NewPath("metadata"), &wft.ObjectMeta, false)
+	v.validateSpec(field.NewPath("spec"), &wft.Spec, false)
+	return v.errs
+}
+
+// ValidateClusterWorkflowTemplate checks a ClusterWorkflowTemplate; see
+// ValidateWorkflowTemplate.
+func ValidateClusterWorkflowTemplate(cwft *ClusterWorkflowTemplate) field.ErrorList {
+	v := newValidator()
+	v.validateMeta(field.NewPath("metadata"), &cwft.ObjectMeta, false)
+	v.validateSpec(field.NewPath("spec"), &cwft.Spec, false)
+	return v.errs
+}
+
+// ValidateCronWorkflow checks a CronWorkflow's schedule settings and the
+// workflow it submits.
+func ValidateCronWorkflow(cwf *CronWorkflow) field.ErrorList {
+	v := newValidator()
+	v.validateMeta(field.NewPath("metadata"), &cwf.ObjectMeta, false)
+
+	spec := field.NewPath("spec")
+	if cwf.Spec.Schedule == "" {
+		v.errs = append(v.errs, field.Required(spec.Child("schedule"), ""))
+	} else if err := checkSchedule(cwf.Spec.Schedule); err != nil {
+		v.errs = append(v.errs, field.Invalid(spec.Child("schedule"), cwf.Spec.Schedule, err.Error()))
+	}
+	if cwf.Spec.Timezone != "" {
+		if _, err := time.LoadLocation(cwf.Spec.Timezone); err != nil {
+			v.errs = append(v.errs, field.Invalid(spec.Child("timezone"), cwf.Spec.Timezone, err.Error()))
+		}
+	}
+	switch cwf.Spec.ConcurrencyPolicy {
+	case "", ConcurrencyPolicyAllow, ConcurrencyPolicyForbid, ConcurrencyPolicyReplace:
+	default:
+		v.errs = append(v.errs, field.NotSupported(spec.Child("concurrencyPolicy"), cwf.Spec.ConcurrencyPolicy,
+			[]string{ConcurrencyPolicyAllow, ConcurrencyPolicyForbid, ConcurrencyPolicyReplace}))
+	}
+	if d := cwf.Spec.StartingDeadlineSeconds; d != nil && *d < 0 {
+		v.errs = append(v.errs, field.Invalid(spec.Child("startingDeadlineSeconds"), *d, "must be non-negative"))
+	}
+
+	v.validateSpec(spec.Child("workflowSpec"), &cwf.Spec.WorkflowSpec, true)
+	return v.errs
+}
+
+// validator holds the template and volume indexes shared by the
+// individual checks. volumes is nil when the workflow's volumes are not
+// known, as for a WorkflowTemplate whose templates may mount volumes
+// declared by the workflows that use them.
+type validator struct {
+	templates map[string]*Template
+	volumes   map[string]bool
+	errs      field.ErrorList
+}
+
+func newValidator() *validator {
+	return &validator{templates: make(map[string]*Template)}
+}
+
+// validateMeta checks the object name. Only Workflows may be named through
+// generateName.
+func (v *validator) validateMeta(path *field.Path, meta *metav1.ObjectMeta, allowGenerateName bool) {
+	switch {
+	case meta.Name != "":
+		v.checkDNSSubdomain(path.Child("name"), meta.Name)
+	case meta.GenerateName != "" && allowGenerateName:
+		// The server appends a random suffix, so only the prefix is checked.
+		v.checkDNSSubdomain(path.Child("generateName"), strings.TrimSuffix(meta.GenerateName, "-")+"-x")
+	case allowGenerateName:
+		v.errs = append(v.errs, field.Required(path.Child("name"), "name or generateName is required"))
+	default:
+		v.errs = append(v.errs, field.Required(path.Child("name"), ""))
+	}
+}
+
+// validateSpec checks a workflow spec. When the spec references a
+// WorkflowTemplate, its templates and entrypoint may come from there and
+// are only checked if present.
+func (v *validator) validateSpec(spec *field.Path, ws *WorkflowSpec, requireEntrypoint bool) {
+	templatesPath := spec.Child("templates")
+	ref := ws.WorkflowTemplateRef
+
+	if ref != nil && ref.Name == "" {
+		v.errs = append(v.errs, field.Required(spec.Child("workflowTemplateRef", "name"), ""))
+	}
+	if len(ws.Templates) == 0 && ref == nil {
+		v.errs = append(v.errs, field.Required(templatesPath, "at least one template is required"))
+	}
+
+	for i := range ws.Templates {
+		t := &ws.Templates[i]
+		path := templatesPath.Index(i)
+		if _, dup := v.templates[t.Name]; dup {
+			v.errs = append(v.errs, field.Duplicate(path.Child("name"), t.Name))
+			continue
+		}
+		v.templates[t.Name] = t
+	}
+
+	if entry, ok := v.templates[ws.Entrypoint]; ok {
+		v.checkArguments(spec.Child("arguments"), ws.Arguments, entry)
+	} else if ref == nil {
+		switch {
+		case ws.Entrypoint != "":
+			v.errs = append(v.errs, field.NotFound(spec.Child("entrypoint"), ws.Entrypoint))
+		case requireEntrypoint:
+			v.errs = append(v.errs, field.Required(spec.Child("entrypoint"), ""))
+		}
+	}
+
+	if ws.OnExit != "" {
+		if t, ok := v.templates[ws.OnExit]; ok {
+			v.checkArguments(spec.Child("onExit"), nil, t)
+		} else if ref == nil {
+			v.errs = append(v.errs, field.NotFound(spec.Child("onExit"), ws.OnExit))
+		}
+	}
+
+	checkNonNegative(v, spec.Child("parallelism"), ws.Parallelism)
+	checkNonNegative(v, spec.Child("activeDeadlineSeconds"), ws.ActiveDeadline)
+	checkNonNegative(v, spec.Child("ttlSecondsAfterFinished"), ws.TTL)
+	if ttl := ws.TTLStrategy; ttl != nil {
+		p := spec.Child("ttlStrategy")
+		checkNonNegative(v, p.Child("secondsAfterCompletion"), ttl.SecondsAfterCompletion)
+		checkNonNegative(v, p.Child("secondsAfterSuccess"), ttl.SecondsAfterSuccess)
+		checkNonNegative(v, p.Child("secondsAfterFailure"), ttl.SecondsAfterFailure)
+	}
+	if gc := ws.PodGC; gc != nil {
+		p := spec.Child("podGC")
+		switch gc.Strategy {
+		case "", PodGCOnPodCompletion, PodGCOnPodSuccess, PodGCOnWorkflowCompletion, PodGCOnWorkflowSuccess:
+		default:
+			v.errs = append(v.errs, field.NotSupported(p.Child("strategy"), gc.Strategy,
+				[]string{PodGCOnPodCompletion, PodGCOnPodSuccess, PodGCOnWorkflowCompletion, PodGCOnWorkflowSuccess}))
+		}
+		v.checkDuration(p.Child("deleteDelayDuration"), gc.DeleteDelayDuration)
+	}
+	if ws.Synchronization != nil {
+		v.checkSynchronization(spec.Child("synchronization"), ws.Synchronization)
+	}
+
+	v.checkScheduling(spec, ws.NodeSelector, ws.Tolerations, ws.Affinity)
+	v.checkPodSecurityContext(spec.Child("securityContext"), ws.SecurityContext)
+	v.checkPodSpecPatch(spec.Child("podSpecPatch"), ws.PodSpecPatch)
+	if ws.PodPriorityClassName != "" {
+		v.checkDNSSubdomain(spec.Child("podPriorityClassName"), ws.PodPriorityClassName)
+	}
+	v.checkMetadata(spec.Child("podMetadata"), ws.PodMetadata)
+	volumes := v.checkVolumes(spec.Child("volumes"), ws.Volumes)
+	v.checkVolumeClaimTemplates(spec.Child("volumeClaimTemplates"), ws.VolumeClaimTemplates, volumes)
+	if ref := ws.ArtifactRepositoryRef; ref != nil && ref.ConfigMap != "" {
+		v.checkDNSSubdomain(spec.Child("artifactRepositoryRef", "configMap"), ref.ConfigMap)
+	}
+	v.checkArtifactGC(spec.Child("artifactGC"), ws.ArtifactGC)
+	if ws.Arguments != nil {
+		v.checkArtifacts(spec.Child("arguments", "artifacts"), ws.Arguments.Artifacts, true)
+	}
+	if requireEntrypoint && ref == nil {
+		v.volumes = volumes
+	}
+
+	for i := range ws.Templates {
+		v.validateTemplate(templatesPath.Index(i), &ws.Templates[i])
+	}
+}
+
+func (v *validator) validateTemplate(path *field.Path, t *Template) {
+	v.checkName(path.Child("name"), t.Name)
+
+	set := make([]string, 0, 1)
+	if t.Container != nil {
+		set = append(set, "container")
+		v.validateContainer(path.Child("container"), t.Container.Image, t.Container.Resources)
+	}
+	if t.Script != nil {
+		set = append(set, "script")
+		v.validateContainer(path.Child("script"), t.Script.Image, t.Script.Resources)
+		if t.Script.Source == "" {
+			v.errs = append(v.errs, field.Required(path.Child("script", "source"), ""))
+		}
+	}
+	if t.DAG != nil {
+		set = append(set, "dag")
+		v.validateDAG(path.Child("dag"), t.DAG)
+	}
+	if t.Steps != nil {
+		set = append(set, "steps")
+		v.validateSteps(path.Child("steps"), *t.Steps)
+	}
+	if t.Resource != nil {
+		set = append(set, "resource")
+		v.validateResource(path.Child("resource"), t.Resource)
+	}
+	if t.Suspend != nil {
+		set = append(set, "suspend")
+		v.checkDuration(path.Child("suspend", "duration"), t.Suspend.Duration)
+	}
+	if t.HTTP != nil {
+		set = append(set, "http")
+		v.validateHTTP(path.Child("http"), t.HTTP)
+	}
+	if t.Data != nil {
+		set = append(set, "data")
+		v.validateData(path.Child("data"), t.Data)
+	}
+	if t.ContainerSet != nil {
+		set = append(set, "containerSet")
+		v.validateContainerSet(path.Child("containerSet"), t.ContainerSet)
+	}
+
+	const kinds = "container, script, dag, steps, resource, suspend, http, data or containerSet"
+	switch len(set) {
+	case 0:
+		v.errs = append(v.errs, field.Required(path, "exactly one of "+kinds+" must be set"))
+	case 1:
+	default:
+		v.errs = append(v.errs, field.Forbidden(path, fmt.Sprintf("exactly one of %s must be set, got %s", kinds, strings.Join(set, ", "))))
+	}
+
+	if t.RetryStrategy != nil {
+		rs := path.Child("retryStrategy")
+		if t.RetryStrategy.Limit != nil && *t.RetryStrategy.Limit < 0 {
+			v.errs = append(v.errs, field.Invalid(rs.Child("limit"), *t.RetryStrategy.Limit, "must be non-negative"))
+		}
+		switch t.RetryStrategy.RetryPolicy {
+		case "", RetryPolicyAlways, RetryPolicyOnFailure, RetryPolicyOnError, RetryPolicyOnTransientError:
+		default:
+			v.errs = append(v.errs, field.NotSupported(rs.Child("retryPolicy"), t.RetryStrategy.RetryPolicy,
+				[]string{RetryPolicyAlways, RetryPolicyOnFailure, RetryPolicyOnError, RetryPolicyOnTransientError}))
+		}
+		if t.RetryStrategy.Expression != "" {
+			v.checkCondition(rs.Child("expression"), t.RetryStrategy.Expression)
+		}
+		if b := t.RetryStrategy.Backoff; b != nil {
+			v.checkDuration(rs.Child("backoff", "duration"), b.Duration)
+			v.checkDuration(rs.Child("backoff", "maxDuration"), b.MaxDuration)
+			if b.Duration != "" && b.MaxDuration != "" {
+				base, err1 := ParseDuration(b.Duration)
+				ceiling, err2 := ParseDuration(b.MaxDuration)
+				if err1 == nil && err2 == nil && ceiling < base {
+					v.errs = append(v.errs, field.Invalid(rs.Child("backoff", "maxDuration"), b.MaxDuration, "must not be less than duration"))
+				}
+			}
+			if b.Factor != nil && *b.Factor < 1 {
+				v.errs = append(v.errs, field.Invalid(rs.Child("backoff", "factor"), *b.Factor, "must be at least 1"))
+			}
+		}
+	}
+
+	if t.Timeout != nil {
+		v.checkDuration(path.Child("timeout", "duration"), t.Timeout.Duration)
+	}
+	if d := t.ActiveDeadlineSeconds; d != nil {
+		v.checkIntOrString(path.Child("activeDeadlineSeconds"), d)
+		if d.Type == intstr.Int && d.IntVal < 0 {
+			v.errs = append(v.errs, field.Invalid(path.Child("activeDeadlineSeconds"), d.IntVal, "must be non-negative"))
+		}
+	}
+	if m := t.Memoize; m != nil {
+		mp := path.Child("memoize")
+		if m.Key == "" {
+			v.errs = append(v.errs, field.Required(mp.Child("key"), ""))
+		}
+		if m.Cache == nil || m.Cache.ConfigMap == nil || m.Cache.ConfigMap.Name == "" {
+			v.errs = append(v.errs, field.Required(mp.Child("cache", "configMap", "name"), ""))
+		} else {
+			v.checkDNSSubdomain(mp.Child("cache", "configMap", "name"), m.Cache.ConfigMap.Name)
+		}
+		v.checkDuration(mp.Child("maxAge"), m.MaxAge)
+	}
+	if t.Synchronization != nil {
+		v.checkSynchronization(path.Child("synchronization"), t.Synchronization)
+	}
+
+	v.checkScheduling(path, t.NodeSelector, t.Tolerations, t.Affinity)
+	v.checkPodSecurityContext(path.Child("securityContext"), t.SecurityContext)
+	v.checkPodSpecPatch(path.Child("podSpecPatch"), t.PodSpecPatch)
+	if t.PriorityClassName != "" {
+		v.checkDNSSubdomain(path.Child("priorityClassName"), t.PriorityClassName)
+	}
+	if t.ServiceAccountName != "" {
+		v.checkDNSSubdomain(path.Child("serviceAccountName"), t.ServiceAccountName)
+	}
+	v.checkMetadata(path.Child("metadata"), t.Metadata)
+	if t.Inputs != nil {
+		v.checkArtifacts(path.Child("inputs", "artifacts"), t.Inputs.Artifacts, false)
+	}
+	if t.Outputs != nil {
+		v.checkArtifacts(path.Child("outputs", "artifacts"), t.Outputs.Artifacts, false)
+	}
+	volumes := v.checkVolumes(path.Child("volumes"), t.Volumes)
+	if c := t.Container; c != nil {
+		v.checkSecurityContext(path.Child("container", "securityContext"), c.SecurityContext)
+		v.checkVolumeMounts(path.Child("container", "volumeMounts"), c.VolumeMounts, volumes)
+	}
+	if sc := t.Script; sc != nil {
+		v.checkVolumeMounts(path.Child("script", "volumeMounts"), sc.VolumeMounts, volumes)
+	}
+	if cs := t.ContainerSet; cs != nil {
+		for i, c := range cs.Containers {
+			v.checkVolumeMounts(path.Child("containerSet", "containers").Index(i).Child("volumeMounts"), append(slices.Clip(cs.VolumeMounts), c.VolumeMounts...), volumes)
+		}
+	}
+
+	pod := t.Container != nil || t.Script != nil || t.ContainerSet != nil
+	if t.Daemon != nil && *t.Daemon && !pod {
+		v.errs = append(v.errs, field.Forbidden(path.Child("daemon"), "only container, script and containerSet templates can run as daemons"))
+	}
+	if (len(t.InitContainers) > 0 || len(t.Sidecars) > 0) && !pod {
+		v.errs = append(v.errs, field.Forbidden(path, "only container, script and containerSet templates can have init containers or sidecars"))
+	}
+	// The main container is named "main", as in the controller; a
+	// containerSet's containers take its place.
+	names := map[string]bool{"main": t.ContainerSet == nil}
+	if t.ContainerSet != nil {
+		for _, c := range t.ContainerSet.Containers {
+			names[c.Name] = true
+		}
+	}
+	v.validateUserContainers(path.Child("initContainers"), t.InitContainers, names, volumes)
+	v.validateUserContainers(path.Child("sidecars"), t.Sidecars, names, volumes)
+}
+
+func (v *validator) validateContainer(path *field.Path, image string, res *Resources) {
+	if image == "" {
+		v.errs = append(v.errs, field.Required(path.Child("image"), ""))
+	}
+	if res != nil {
+		v.checkResourceList(path.Child("resources", "limits"), res.Limits)
+		v.checkResourceList(path.Child("resources", "requests"), res.Requests)
+	}
+}
+
+// validateUserContainers checks init containers or sidecars. names holds
+// the container names of the pod seen so far.
+func (v *validator) validateUserContainers(path *field.Path, containers []UserContainer, names, volumes map[string]bool) {
+	for i := range containers {
+		c := &containers[i].Container
+		p := path.Index(i)
+		v.checkContainerName(p.Child("name"), c.Name, names)
+		v.validateContainer(p, c.Image, c.Resources)
+		v.checkSecurityContext(p.Child("securityContext"), c.SecurityContext)
+		v.checkVolumeMounts(p.Child("volumeMounts"), c.VolumeMounts, volumes)
+	}
+}
+
+// checkContainerName checks that a container of a pod has a unique DNS
+// label name.
+func (v *validator) checkContainerName(path *field.Path, name string, names map[string]bool) {
+	switch {
+	case name == "":
+		v.errs = append(v.errs, field.Required(path, ""))
+	case names[name]:
+		v.errs = append(v.errs, field.Duplicate(path, name))
+	default:
+		for _, msg := range validation.IsDNS1123Label(name) {
+			v.errs = append(v.errs, field.Invalid(path, name, msg))
+		}
+		names[name] = true
+	}
+}
+
+// validateContainerSet checks the containers of a containerSet template and
+// that their dependencies form a DAG.
+func (v *validator) validateContainerSet(path *field.Path, cs *ContainerSet) {
+	cp := path.Child("containers")
+	if len(cs.Containers) == 0 {
+		v.errs = append(v.errs, field.Required(cp, "at least one container is required"))
+	}
+
+	names := make(map[string]bool, len(cs.Containers))
+	for i := range cs.Containers {
+		c := &cs.Containers[i]
+		v.checkContainerName(cp.Index(i).Child("name"), c.Name, names)
+		v.validateContainer(cp.Index(i), c.Image, c.Resources)
+		v.checkSecurityContext(cp.Index(i).Child("securityContext"), c.SecurityContext)
+	}
+
+	// Dependencies are checked like those of DAG tasks.
+	tasks := make([]DAGTask, len(cs.Containers))
+	for i, c := range cs.Containers {
+		tasks[i] = DAGTask{Name: c.Name, Dependencies: c.Dependencies}
+		for j, dep := range c.Dependencies {
+			if !names[dep] {
+				v.errs = append(v.errs, field.NotFound(cp.Index(i).Child("dependencies").Index(j), dep))
+			}
+		}
+	}
+	cycles, _ := NewDependencyGraph(tasks).problems()
+	for _, c := range cycles {
+		v.errs = append(v.errs, field.Invalid(cp, len(tasks), c.Error()))
+	}
+
+	if rs := cs.RetryStrategy; rs != nil {
+		rp := path.Child("retryStrategy")
+		if rs.Retries == nil {
+			v.errs = append(v.errs, field.Required(rp.Child("retries"), ""))
+		}
+		v.checkIntOrString(rp.Child("retries"), rs.Retries)
+		v.checkDuration(rp.Child("duration"), rs.Duration)
+	}
+}
+
+var (
+	resourceActions = []string{ResourceActionGet, ResourceActionCreate, ResourceActionApply, ResourceActionDelete, ResourceActionReplace, ResourceActionPatch}
+	mergeStrategies = []string{MergeStrategyStrategic, MergeStrategyMerge, MergeStrategyJSON}
+)
+
+func (v *validator) validateResource(path *field.Path, r *Resource) {
+	switch {
+	case r.Action == "":
+		v.errs = append(v.errs, field.Required(path.Child("action"), ""))
+	case !slices.Contains(resourceActions, r.Action):
+		v.errs = append(v.errs, field.NotSupported(path.Child("action"), r.Action, resourceActions))
+	}
+
+	if r.MergeStrategy != "" {
+		switch {
+		case r.Action != ResourceActionPatch:
+			v.errs = append(v.errs, field.Forbidden(path.Child("mergeStrategy"), "may only be set for the patch action"))
+		case !slices.Contains(mergeStrategies, r.MergeStrategy):
+			v.errs = append(v.errs, field.NotSupported(path.Child("mergeStrategy"), r.MergeStrategy, mergeStrategies))
+		}
+	}
+
+	switch {
+	case r.Manifest != "" && r.ManifestFrom != nil:
+		v.errs = append(v.errs, field.Forbidden(path.Child("manifestFrom"), "manifest and manifestFrom cannot be used together"))
+	case r.ManifestFrom != nil:
+		if r.ManifestFrom.Artifact == nil || r.ManifestFrom.Artifact.Name == "" {
+			v.errs = append(v.errs, field.Required(path.Child("manifestFrom", "artifact", "name"), ""))
+		}
+	case r.Manifest == "":
+		// kubectl can get or delete by flags alone, e.g. a label selector.
+		if (r.Action != ResourceActionGet && r.Action != ResourceActionDelete) || len(r.Flags) == 0 {
+			v.errs = append(v.errs, field.Required(path.Child("manifest"), "manifest or manifestFrom is required"))
+		}
+	case !strings.Contains(r.Manifest, "{{"):
+		// A manifest with placeholders can only be checked once resolved.
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(r.Manifest), &obj); err != nil {
+			v.errs = append(v.errs, field.Invalid(path.Child("manifest"), r.Manifest, err.Error()))
+		} else if obj["apiVersion"] == nil || obj["kind"] == nil {
+			v.errs = append(v.errs, field.Invalid(path.Child("manifest"), r.Manifest, "must set apiVersion and kind"))
+		}
+	}
+
+	v.checkSelector(path.Child("successCondition"), r.SuccessCondition)
+	v.checkSelector(path.Child("failureCondition"), r.FailureCondition)
+}
+
+// checkSelector validates a resource condition, which uses label selector
+// syntax over the fields of the resource.
+func (v *validator) checkSelector(path *field.Path, cond string) {
+	if cond == "" || strings.Contains(cond, "{{") {
+		return
+	}
+	if _, err := labels.ParseToRequirements(cond); err != nil {
+		v.errs = append(v.errs, field.Invalid(path, cond, err.Error()))
+	}
+}
+
+func (v *validator) validateHTTP(path *field.Path, h *HTTP) {
+	if h.URL == "" {
+		v.errs = append(v.errs, field.Required(path.Child("url"), ""))
+	}
+	for i, header := range h.Headers {
+		p := path.Child("headers").Index(i)
+		if header.Name == "" {
+			v.errs = append(v.errs, field.Required(p.Child("name"), ""))
+		}
+		if header.Value != "" && header.ValueFrom != nil {
+			v.errs = append(v.errs, field.Forbidden(p.Child("valueFrom"), "value and valueFrom cannot be used together"))
+		}
+		if header.ValueFrom != nil && header.ValueFrom.SecretKeyRef == nil {
+			v.errs = append(v.errs, field.Required(p.Child("valueFrom", "secretKeyRef"), ""))
+		}
+	}
+	checkNonNegative(v, path.Child("timeoutSeconds"), h.TimeoutSeconds)
+	if h.Body != "" && h.BodyFrom != nil {
+		v.errs = append(v.errs, field.Forbidden(path.Child("bodyFrom"), "body and bodyFrom cannot be used together"))
+	}
+	if h.SuccessCondition != "" {
+		v.checkCondition(path.Child("successCondition"), h.SuccessCondition)
+	}
+}
+
+func (v *validator) validateData(path *field.Path, d *Data) {
+	if d.Source.ArtifactPaths == nil {
+		v.errs = append(v.errs, field.Required(path.Child("source", "artifactPaths"), ""))
+	}
+	if len(d.Transformation) == 0 {
+		v.errs = append(v.errs, field.Required(path.Child("transformation"), "at least one step is required"))
+	}
+	for i, step := range d.Transformation {
+		if step.Expression == "" {
+			v.errs = append(v.errs, field.Required(path.Child("transformation").Index(i).Child("expression"), ""))
+		}
+	}
+}
+
+func (v *validator) validateDAG(path *field.Path, dag *DAG) {
+	tasksPath := path.Child("tasks")
+	seen := make(map[string]bool, len(dag.Tasks))
+
+	for i := range dag.Tasks {
+		task := &dag.Tasks[i]
+		p := tasksPath.Index(i)
+
+		v.checkName(p.Child("name"), task.Name)
+		if seen[task.Name] {
+			v.errs = append(v.errs, field.Duplicate(p.Child("name"), task.Name))
+		}
+		seen[task.Name] = true
+
+		v.checkTemplateRef(p, task.Template, task.TemplateRef, task.Arguments)
+		if task.When != "" {
+			v.checkCondition(p.Child("when"), task.When)
+		}
+		v.checkLoop(p, task.WithItems, task.WithParam, task.WithSequence)
+		if !task.Looped() {
+			v.checkItemReferences(p, task.Arguments, task.When)
+		}
+		v.checkHooks(p.Child("hooks"), task.Hooks)
+	}
+
+	for i := range dag.Tasks {
+		for j, dep := range dag.Tasks[i].Dependencies {
+			if !seen[dep] {
+				v.errs = append(v.errs, field.NotFound(tasksPath.Index(i).Child("dependencies").Index(j), dep))
+			}
+		}
+		if dag.Tasks[i].Depends != "" {
+			v.checkDepends(tasksPath.Index(i), &dag.Tasks[i], dag.Tasks)
+		}
+	}
+
+	cycles, _ := NewDependencyGraph(dag.Tasks).problems()
+	for _, c := range cycles {
+		v.errs = append(v.errs, field.Invalid(tasksPath, len(dag.Tasks), c.Error()))
+	}
+}
+
+// checkDepends validates a task's depends expression: its syntax, that
+// every task it mentions exists, and that AnySucceeded and AllFailed are
+// only used on looped tasks.
+func (v *validator) checkDepends(path *field.Path, task *DAGTask, tasks []DAGTask) {
+	p := path.Child("depends")
+	if len(task.Dependencies) > 0 {
+		v.errs = append(v.errs, field.Invalid(p, task.Depends, "cannot be used together with dependencies"))
+	}
+
+	d, err := ParseDepends(task.Depends)
+	if err != nil {
+		v.errs = append(v.errs, field.Invalid(p, task.Depends, err.Error()))
+		return
+	}
+
+	byName := make(map[string]*DAGTask, len(tasks))
+	for i := range tasks {
+		byName[tasks[i].Name] = &tasks[i]
+	}
+	for _, name := range d.Tasks() {
+		if byName[name] == nil {
+			v.errs = append(v.errs, field.NotFound(p, name))
+		}
+	}
+	for _, term := range d.Terms() {
+		dep := byName[term.Task]
+		if dep == nil || dep.Looped() {
+			continue
+		}
+		if term.Result == DependsAnySucceeded || term.Result == DependsAllFailed {
+			v.errs = append(v.errs, field.Invalid(p, task.Depends,
+				fmt.Sprintf("%s is only valid for tasks with withItems, withParam or withSequence", term)))
+		}
+	}
+}
+
+func (v *validator) validateSteps(path *field.Path, groups [][]StepGroup) {
+	seen := make(map[string]bool)
+
+	for i, group := range groups {
+		for j := range group {
+			step := &group[j]
+			p := path.Index(i).Index(j)
+
+			v.checkName(p.Child("name"), step.Name)
+			if seen[step.Name] {
+				v.errs = append(v.errs, field.Duplicate(p.Child("name"), step.Name))
+			}
+			seen[step.Name] = true
+
+			v.checkTemplateRef(p, step.Template, step.TemplateRef, step.Arguments)
+			if step.When != "" {
+				v.checkCondition(p.Child("when"), step.When)
+			}
+			v.checkLoop(p, step.WithItems, step.WithParam, step.WithSequence)
+			if !step.Looped() {
+				v.checkItemReferences(p, step.Arguments, step.When)
+			}
+			v.checkHooks(p.Child("hooks"), step.Hooks)
+		}
+	}
+}
+
+// checkTemplateRef verifies that a task or step names its template exactly
+// one way. A TemplateRef points into another resource, so only its fields
+// are checked.
+func (v *validator) checkTemplateRef(path *field.Path, name string, ref *TemplateRef, args *Arguments) {
+	if args != nil {
+		v.checkArtifacts(path.Child("arguments", "artifacts"), args.Artifacts, true)
+	}
+	if ref == nil {
+		v.checkReference(path, name, args)
+		return
+	}
+
+	if name != "" {
+		v.errs = append(v.errs, field.Forbidden(path.Child("template"), "only one of template or templateRef may be set"))
+	}
+	if ref.Name == "" {
+		v.errs = append(v.errs, field.Required(path.Child("templateRef", "name"), ""))
+	}
+	if ref.Template == "" {
+		v.errs = append(v.errs, field.Required(path.Child("templateRef", "template"), ""))
+	}
+}
+
+// checkReference verifies that a task or step names an existing template
+// and supplies every input parameter that template requires.
+func (v *validator) checkReference(path *field.Path, name string, args *Arguments) {
+	if name == "" {
+		v.errs = append(v.errs, field.Required(path.Child("template"), "template or templateRef is required"))
+		return
+	}
+
+	t, ok := v.templates[name]
+	if !ok {
+		v.errs = append(v.errs, field.NotFound(path.Child("template"), name))
+		return
+	}
+
+	v.checkArguments(path.Child("arguments"), args, t)
+}
+
+// checkArguments reports input parameters of t that have neither a value,
+// a default, a valueFrom source nor a matching argument, and input
+// artifacts that are neither optional, located nor supplied.
+func (v *validator) checkArguments(path *field.Path, args *Arguments, t *Template) {
+	if t.Inputs == nil {
+		return
+	}
+
+	supplied := make(map[string]bool)
+	suppliedArtifacts := make(map[string]bool)
+	if args != nil {
+		for _, p := range args.Parameters {
+			supplied[p.Name] = true
+		}
+		for _, a := range args.Artifacts {
+			suppliedArtifacts[a.Name] = a.From != "" || a.HasLocation() || a.Optional
+		}
+	}
+
+	for _, p := range t.Inputs.Parameters {
+		if p.Value != nil || p.Default != nil || p.ValueFrom != nil || supplied[p.Name] {
+			continue
+		}
+		v.errs = append(v.errs, field.Required(path.Child("parameters"),
+			fmt.Sprintf("input parameter %q of template %q is not supplied", p.Name, t.Name)))
+	}
+	for _, a := range t.Inputs.Artifacts {
+		if a.Optional || a.From != "" || a.HasLocation() || suppliedArtifacts[a.Name] {
+			continue
+		}
+		v.errs = append(v.errs, field.Required(path.Child("artifacts"),
+			fmt.Sprintf("input artifact %q of template %q is not supplied", a.Name, t.Name)))
+	}
+}
+
+// checkHooks verifies that every hook names an existing template and that
+// hooks other than the exit hook say when they run.
+func (v *validator) checkHooks(path *field.Path, hooks LifecycleHooks) {
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hook := hooks[name]
+		p := path.Key(name)
+		v.checkReference(p, hook.Template, hook.Arguments)
+		if name != ExitHook && hook.Expression == "" {
+			v.errs = append(v.errs, field.Required(p.Child("expression"), "hooks other than exit require an expression"))
+		}
+	}
+}
+
+// checkLoop verifies that at most one loop source is set and that a
+// sequence is well-formed.
+func (v *validator) checkLoop(path *field.Path, items []interface{}, param string, seq *Sequence) {
+	var set []string
+	if items != nil {
+		set = append(set, "withItems")
+	}
+	if param != "" {
+		set = append(set, "withParam")
+	}
+	if seq != nil {
+		set = append(set, "withSequence")
+	}
+	if len(set) > 1 {
+		v.errs = append(v.errs, field.Forbidden(path, fmt.Sprintf("only one of withItems, withParam or withSequence may be set, got %s", strings.Join(set, ", "))))
+	}
+
+	if seq == nil {
+		return
+	}
+	sp := path.Child("withSequence")
+	switch {
+	case seq.Count != nil && seq.End != nil:
+		v.errs = append(v.errs, field.Forbidden(sp, "only one of count or end may be set"))
+	case seq.Count == nil && seq.End == nil:
+		v.errs = append(v.errs, field.Required(sp, "one of count or end is required"))
+	}
+	v.checkIntOrString(sp.Child("count"), seq.Count)
+	v.checkIntOrString(sp.Child("start"), seq.Start)
+	v.checkIntOrString(sp.Child("end"), seq.End)
+	if seq.Format != "" && strings.Contains(fmt.Sprintf(seq.Format, 0), "%!") {
+		v.errs = append(v.errs, field.Invalid(sp.Child("format"), seq.Format, "must format a single integer"))
+	}
+}
+
+func (v *validator) checkSynchronization(path *field.Path, s *Synchronization) {
+	if s.Mutex == nil && s.Semaphore == nil {
+		v.errs = append(v.errs, field.Required(path, "mutex or semaphore must be set"))
+	}
+	if m := s.Mutex; m != nil {
+		if m.Name == "" {
+			v.errs = append(v.errs, field.Required(path.Child("mutex", "name"), ""))
+		}
+		v.checkNamespace(path.Child("mutex", "namespace"), m.Namespace)
+	}
+	if sem := s.Semaphore; sem != nil {
+		p := path.Child("semaphore", "configMapKeyRef")
+		if ref := sem.ConfigMapKeyRef; ref == nil {
+			v.errs = append(v.errs, field.Required(p, ""))
+		} else {
+			if ref.Name == "" {
+				v.errs = append(v.errs, field.Required(p.Child("name"), ""))
+			} else {
+				v.checkDNSSubdomain(p.Child("name"), ref.Name)
+			}
+			if ref.Key == "" {
+				v.errs = append(v.errs, field.Required(p.Child("key"), ""))
+			} else {
+				for _, msg := range validation.IsConfigMapKey(ref.Key) {
+					v.errs = append(v.errs, field.Invalid(p.Child("key"), ref.Key, msg))
+				}
+			}
+		}
+		v.checkNamespace(path.Child("semaphore", "namespace"), sem.Namespace)
+	}
+}
+
+var (
+	tolerationOperators = []string{TolerationOpExists, TolerationOpEqual}
+	taintEffects        = []string{TaintEffectNoSchedule, TaintEffectPreferNoSchedule, TaintEffectNoExecute}
+	nodeSelectorOps     = []string{NodeSelectorOpIn, NodeSelectorOpNotIn, NodeSelectorOpExists, NodeSelectorOpDoesNotExist, NodeSelectorOpGt, NodeSelectorOpLt}
+)
+
+// checkScheduling checks the node selector, tolerations and affinity of a
+// workflow or template, which share field names.
+func (v *validator) checkScheduling(path *field.Path, nodeSelector map[string]string, tolerations []Toleration, affinity *Affinity) {
+	v.checkLabels(path.Child("nodeSelector"), nodeSelector)
+
+	for i, t := range tolerations {
+		p := path.Child("tolerations").Index(i)
+		if t.Key != "" {
+			for _, msg := range validation.IsQualifiedName(t.Key) {
+				v.errs = append(v.errs, field.Invalid(p.Child("key"), t.Key, msg))
+			}
+		}
+		switch t.Operator {
+		case TolerationOpExists:
+			if t.Value != "" {
+				v.errs = append(v.errs, field.Invalid(p.Child("value"), t.Value, "must be empty when operator is Exists"))
+			}
+		case "", TolerationOpEqual:
+			if t.Key == "" {
+				v.errs = append(v.errs, field.Invalid(p.Child("operator"), t.Operator, "must be Exists when key is empty"))
+			}
+			for _, msg := range validation.IsValidLabelValue(t.Value) {
+				v.errs = append(v.errs, field.Invalid(p.Child("value"), t.Value, msg))
+			}
+		default:
+			v.errs = append(v.errs, field.NotSupported(p.Child("operator"), t.Operator, tolerationOperators))
+		}
+		if t.Effect != "" && !slices.Contains(taintEffects, t.Effect) {
+			v.errs = append(v.errs, field.NotSupported(p.Child("effect"), t.Effect, taintEffects))
+		}
+		if t.TolerationSeconds != nil && t.Effect != TaintEffectNoExecute {
+			v.errs = append(v.errs, field.Forbidden(p.Child("tolerationSeconds"), "may only be set for the NoExecute effect"))
+		}
+	}
+
+	if affinity == nil {
+		return
+	}
+	ap := path.Child("affinity")
+	if na := affinity.NodeAffinity; na != nil {
+		p := ap.Child("nodeAffinity")
+		if req := na.RequiredDuringSchedulingIgnoredDuringExecution; req != nil {
+			rp := p.Child("requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms")
+			if len(req.NodeSelectorTerms) == 0 {
+				v.errs = append(v.errs, field.Required(rp, "at least one term is required"))
+			}
+			for i := range req.NodeSelectorTerms {
+				v.checkNodeSelectorTerm(rp.Index(i), &req.NodeSelectorTerms[i])
+			}
+		}
+		for i := range na.PreferredDuringSchedulingIgnoredDuringExecution {
+			term := &na.PreferredDuringSchedulingIgnoredDuringExecution[i]
+			tp := p.Child("preferredDuringSchedulingIgnoredDuringExecution").Index(i)
+			v.checkWeight(tp.Child("weight"), term.Weight)
+			v.checkNodeSelectorTerm(tp.Child("preference"), &term.Preference)
+		}
+	}
+	v.checkPodAffinity(ap.Child("podAffinity"), affinity.PodAffinity)
+	v.checkPodAffinity(ap.Child("podAntiAffinity"), affinity.PodAntiAffinity)
+}
+
+func (v *validator) checkNodeSelectorTerm(path *field.Path, term *NodeSelectorTerm) {
+	check := func(p *field.Path, reqs []NodeSelectorRequirement) {
+		for i, req := range reqs {
+			rp := p.Index(i)
+			if req.Key == "" {
+				v.errs = append(v.errs, field.Required(rp.Child("key"), ""))
+			}
+			switch req.Operator {
+			case NodeSelectorOpIn, NodeSelectorOpNotIn:
+				if len(req.Values) == 0 {
+					v.errs = append(v.errs, field.Required(rp.Child("values"), "must be set for In and NotIn"))
+				}
+			case NodeSelectorOpExists, NodeSelectorOpDoesNotExist:
+				if len(req.Values) > 0 {
+					v.errs = append(v.errs, field.Forbidden(rp.Child("values"), "may not be set for Exists and DoesNotExist"))
+				}
+			case NodeSelectorOpGt, NodeSelectorOpLt:
+				if len(req.Values) != 1 {
+					v.errs = append(v.errs, field.Invalid(rp.Child("values"), req.Values, "must be a single integer for Gt and Lt"))
+				} else if _, err := strconv.ParseInt(req.Values[0], 10, 64); err != nil {
+					v.errs = append(v.errs, field.Invalid(rp.Child("values").Index(0), req.Values[0], "must be an integer"))
+				}
+			default:
+				v.errs = append(v.errs, field.NotSupported(rp.Child("operator"), req.Operator, nodeSelectorOps))
+			}
+		}
+	}
+	check(path.Child("matchExpressions"), term.MatchExpressions)
+	check(path.Child("matchFields"), term.MatchFields)
+}
+
+func (v *validator) checkPodAffinity(path *field.Path, pa *PodAffinity) {
+	if pa == nil {
+		return
+	}
+	check := func(p *field.Path, term *PodAffinityTerm) {
+		if term.TopologyKey == "" {
+			v.errs = append(v.errs, field.Required(p.Child("topologyKey"), ""))
+		} else {
+			for _, msg := range validation.IsQualifiedName(term.TopologyKey) {
+				v.errs = append(v.errs, field.Invalid(p.Child("topologyKey"), term.TopologyKey, msg))
+			}
+		}
+		if term.LabelSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(term.LabelSelector); err != nil {
+				v.errs = append(v.errs, field.Invalid(p.Child("labelSelector"), term.LabelSelector, err.Error()))
+			}
+		}
+		for i, ns := range term.Namespaces {
+			v.checkNamespace(p.Child("namespaces").Index(i), ns)
+		}
+	}
+	for i := range pa.RequiredDuringSchedulingIgnoredDuringExecution {
+		check(path.Child("requiredDuringSchedulingIgnoredDuringExecution").Index(i), &pa.RequiredDuringSchedulingIgnoredDuringExecution[i])
+	}
+	for i := range pa.PreferredDuringSchedulingIgnoredDuringExecution {
+		term := &pa.PreferredDuringSchedulingIgnoredDuringExecution[i]
+		p := path.Child("preferredDuringSchedulingIgnoredDuringExecution").Index(i)
+		v.checkWeight(p.Child("weight"), term.Weight)
+		check(p.Child("podAffinityTerm"), &term.PodAffinityTerm)
+	}
+}
+
+func (v *validator) checkWeight(path *field.Path, weight int32) {
+	if weight < 1 || weight > 100 {
+		v.errs = append(v.errs, field.Invalid(path, weight, "must be between 1 and 100"))
+	}
+}
+
+func (v *validator) checkPodSecurityContext(path *field.Path, sc *PodSecurityContext) {
+	if sc != nil {
+		v.checkRunAsNonRoot(path, sc.RunAsNonRoot, sc.RunAsUser)
+	}
+}
+
+func (v *validator) checkSecurityContext(path *field.Path, sc *SecurityContext) {
+	if sc == nil {
+		return
+	}
+	v.checkRunAsNonRoot(path, sc.RunAsNonRoot, sc.RunAsUser)
+	if sc.Privileged != nil && *sc.Privileged && sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation {
+		v.errs = append(v.errs, field.Invalid(path.Child("allowPrivilegeEscalation"), false, "cannot be false when privileged is true"))
+	}
+}
+
+// checkRunAsNonRoot reports a context that both requires a non-root user
+// and runs as root, which the kubelet would refuse to start.
+func (v *validator) checkRunAsNonRoot(path *field.Path, nonRoot *bool, user *int64) {
+	if nonRoot != nil && *nonRoot && user != nil && *user == 0 {
+		v.errs = append(v.errs, field.Invalid(path.Child("runAsNonRoot"), true, "cannot be true when runAsUser is 0"))
+	}
+	checkNonNegative(v, path.Child("runAsUser"), user)
+}
+
+// checkPodSpecPatch checks that a patch is a JSON or YAML object. Patches
+// with placeholders are only checked once resolved.
+func (v *validator) checkPodSpecPatch(path *field.Path, patch string) {
+	if patch == "" || len(Placeholders(patch)) > 0 {
+		return
+	}
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(patch), &obj); err != nil {
+		v.errs = append(v.errs, field.Invalid(path, patch, "must be a JSON or YAML object: "+err.Error()))
+	}
+}
+
+func (v *validator) checkMetadata(path *field.Path, m *Metadata) {
+	if m == nil {
+		return
+	}
+	v.checkLabels(path.Child("labels"), m.Labels)
+	for _, key := range sortedKeys(m.Annotations) {
+		for _, msg := range validation.IsQualifiedName(strings.ToLower(key)) {
+			v.errs = append(v.errs, field.Invalid(path.Child("annotations").Key(key), key, msg))
+		}
+	}
+}
+
+// checkLabels checks the keys and values of labels or a node selector.
+func (v *validator) checkLabels(path *field.Path, labels map[string]string) {
+	for _, key := range sortedKeys(labels) {
+		for _, msg := range validation.IsQualifiedName(key) {
+			v.errs = append(v.errs, field.Invalid(path.Key(key), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(labels[key]) {
+			v.errs = append(v.errs, field.Invalid(path.Key(key), labels[key], msg))
+		}
+	}
+}
+
+// checkVolumes checks volume declarations and returns the declared names.
+func (v *validator) checkVolumes(path *field.Path, volumes []Volume) map[string]bool {
+	names := make(map[string]bool, len(volumes))
+	for i, vol := range volumes {
+		p := path.Index(i)
+		if vol.Name == "" {
+			v.errs = append(v.errs, field.Required(p.Child("name"), ""))
+		} else if names[vol.Name] {
+			v.errs = append(v.errs, field.Duplicate(p.Child("name"), vol.Name))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(vol.Name) {
+				v.errs = append(v.errs, field.Invalid(p.Child("name"), vol.Name, msg))
+			}
+			names[vol.Name] = true
+		}
+
+		set := make([]string, 0, 1)
+		if vol.EmptyDir != nil {
+			set = append(set, "emptyDir")
+			if vol.EmptyDir.SizeLimit != "" {
+				if _, err := resource.ParseQuantity(vol.EmptyDir.SizeLimit); err != nil {
+					v.errs = append(v.errs, field.Invalid(p.Child("emptyDir", "sizeLimit"), vol.EmptyDir.SizeLimit, err.Error()))
+				}
+			}
+		}
+		if vol.ConfigMap != nil {
+			set = append(set, "configMap")
+			if vol.ConfigMap.Name == "" {
+				v.errs = append(v.errs, field.Required(p.Child("configMap", "name"), ""))
+			} else {
+				v.checkDNSSubdomain(p.Child("configMap", "name"), vol.ConfigMap.Name)
+			}
+			v.checkKeyToPaths(p.Child("configMap", "items"), vol.ConfigMap.Items)
+		}
+		if vol.Secret != nil {
+			set = append(set, "secret")
+			if vol.Secret.SecretName == "" {
+				v.errs = append(v.errs, field.Required(p.Child("secret", "secretName"), ""))
+			} else {
+				v.checkDNSSubdomain(p.Child("secret", "secretName"), vol.Secret.SecretName)
+			}
+			v.checkKeyToPaths(p.Child("secret", "items"), vol.Secret.Items)
+		}
+		if vol.PersistentVolumeClaim != nil {
+			set = append(set, "persistentVolumeClaim")
+			if vol.PersistentVolumeClaim.ClaimName == "" {
+				v.errs = append(v.errs, field.Required(p.Child("persistentVolumeClaim", "claimName"), ""))
+			}
+		}
+
+		const kinds = "emptyDir, configMap, secret or persistentVolumeClaim"
+		switch len(set) {
+		case 0:
+			v.errs = append(v.errs, field.Required(p, "exactly one of "+kinds+" must be set"))
+		case 1:
+		default:
+			v.errs = append(v.errs, field.Forbidden(p, fmt.Sprintf("exactly one of %s must be set, got %s", kinds, strings.Join(set, ", "))))
+		}
+	}
+	return names
+}
+
+var accessModes = []string{ReadWriteOnce, ReadOnlyMany, ReadWriteMany, ReadWriteOncePod}
+
+// checkVolumeClaimTemplates checks claim templates and adds their names to
+// the declared volumes, which they share a namespace with.
+func (v *validator) checkVolumeClaimTemplates(path *field.Path, claims []PersistentVolumeClaim, volumes map[string]bool) {
+	for i := range claims {
+		claim := &claims[i]
+		p := path.Index(i)
+		if claim.Name == "" {
+			v.errs = append(v.errs, field.Required(p.Child("metadata", "name"), ""))
+		} else if volumes[claim.Name] {
+			v.errs = append(v.errs, field.Duplicate(p.Child("metadata", "name"), claim.Name))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(claim.Name) {
+				v.errs = append(v.errs, field.Invalid(p.Child("metadata", "name"), claim.Name, msg))
+			}
+			volumes[claim.Name] = true
+		}
+
+		sp := p.Child("spec")
+		if len(claim.Spec.AccessModes) == 0 {
+			v.errs = append(v.errs, field.Required(sp.Child("accessModes"), "at least one access mode is required"))
+		}
+		for j, mode := range claim.Spec.AccessModes {
+			if !slices.Contains(accessModes, mode) {
+				v.errs = append(v.errs, field.NotSupported(sp.Child("accessModes").Index(j), mode, accessModes))
+			}
+		}
+		if sc := claim.Spec.StorageClassName; sc != nil && *sc != "" {
+			v.checkDNSSubdomain(sp.Child("storageClassName"), *sc)
+		}
+		if _, ok := claim.Spec.Resources.Requests["storage"]; !ok {
+			v.errs = append(v.errs, field.Required(sp.Child("resources", "requests").Key("storage"), ""))
+		}
+		v.checkResourceList(sp.Child("resources", "requests"), claim.Spec.Resources.Requests)
+		v.checkResourceList(sp.Child("resources", "limits"), claim.Spec.Resources.Limits)
+	}
+}
+
+func (v *validator) checkKeyToPaths(path *field.Path, items []KeyToPath) {
+	for i, item := range items {
+		p := path.Index(i)
+		if item.Key == "" {
+			v.errs = append(v.errs, field.Required(p.Child("key"), ""))
+		}
+		v.checkRelativePath(p.Child("path"), item.Path, true)
+	}
+}
+
+const artifactLocations = "s3, git, http, artifactory, hdfs, raw, oss, gcs or azure"
+
+var artifactGCStrategies = []string{ArtifactGCOnWorkflowCompletion, ArtifactGCOnWorkflowDeletion, ArtifactGCNever}
+
+// checkArtifacts checks that each artifact has a unique name, at most one
+// location, and does not both take its value from another artifact and
+// set a location. Arguments, which supply artifacts, must set one of the
+// two unless they are optional.
+func (v *validator) checkArtifacts(path *field.Path, artifacts []Artifact, arguments bool) {
+	names := make(map[string]bool, len(artifacts))
+	for i := range artifacts {
+		a := &artifacts[i]
+		p := path.Index(i)
+		if a.Name == "" {
+			v.errs = append(v.errs, field.Required(p.Child("name"), ""))
+		} else if names[a.Name] {
+			v.errs = append(v.errs, field.Duplicate(p.Child("name"), a.Name))
+		}
+		names[a.Name] = true
+
+		set := a.locations()
+		switch {
+		case len(set) > 1:
+			v.errs = append(v.errs, field.Forbidden(p, fmt.Sprintf("exactly one of %s must be set, got %s", artifactLocations, strings.Join(set, ", "))))
+		case len(set) == 0 && arguments && a.From == "" && !a.Optional:
+			v.errs = append(v.errs, field.Required(p, "from or exactly one of "+artifactLocations+" must be set"))
+		}
+		if a.From != "" && len(set) > 0 {
+			v.errs = append(v.errs, field.Forbidden(p.Child("from"), "from and a location cannot be used together"))
+		}
+		v.checkArtifactLocation(p, a)
+
+		if a.Mode != nil && (*a.Mode < 0 || *a.Mode > 0o777) {
+			v.errs = append(v.errs, field.Invalid(p.Child("mode"), *a.Mode, "must be between 0 and 0777"))
+		}
+		if ar := a.Archive; ar != nil {
+			switch {
+			case ar.Tar != nil && ar.None != nil:
+				v.errs = append(v.errs, field.Forbidden(p.Child("archive"), "exactly one of tar or none must be set, got tar, none"))
+			case ar.Tar != nil && ar.Tar.CompressionLevel != nil:
+				if l := *ar.Tar.CompressionLevel; l < 0 || l > 9 {
+					v.errs = append(v.errs, field.Invalid(p.Child("archive", "tar", "compressionLevel"), l, "must be between 0 and 9"))
+				}
+			}
+		}
+		v.checkArtifactGC(p.Child("artifactGC"), a.ArtifactGC)
+	}
+}
+
+// checkArtifactLocation checks the fields that identify an artifact within
+// its location, and the secrets that hold its credentials.
+func (v *validator) checkArtifactLocation(path *field.Path, a *Artifact) {
+	required := func(p *field.Path, value string) {
+		if value == "" {
+			v.errs = append(v.errs, field.Required(p, ""))
+		}
+	}
+
+	if s3 := a.S3; s3 != nil {
+		p := path.Child("s3")
+		required(p.Child("key"), s3.Key)
+		v.checkSecretKeySelector(p.Child("accessKeySecret"), s3.AccessKeySecret)
+		v.checkSecretKeySelector(p.Child("secretKeySecret"), s3.SecretKeySecret)
+	}
+	if h := a.HTTP; h != nil {
+		p := path.Child("http")
+		required(p.Child("url"), h.URL)
+		for i, header := range h.Headers {
+			required(p.Child("headers").Index(i).Child("name"), header.Name)
+		}
+		if h.Auth != nil && h.Auth.BasicAuth != nil {
+			v.checkSecretKeySelector(p.Child("auth", "basicAuth", "usernameSecret"), h.Auth.BasicAuth.UsernameSecret)
+			v.checkSecretKeySelector(p.Child("auth", "basicAuth", "passwordSecret"), h.Auth.BasicAuth.PasswordSecret)
+		}
+	}
+	if g := a.Git; g != nil {
+		p := path.Child("git")
+		required(p.Child("repo"), g.Repo)
+		if g.Depth != nil && *g.Depth == 0 {
+			v.errs = append(v.errs, field.Invalid(p.Child("depth"), *g.Depth, "must be positive"))
+		}
+		v.checkSecretKeySelector(p.Child("usernameSecret"), g.UsernameSecret)
+		v.checkSecretKeySelector(p.Child("passwordSecret"), g.PasswordSecret)
+		v.checkSecretKeySelector(p.Child("sshPrivateKeySecret"), g.SSHPrivateKeySecret)
+	}
+	if af := a.Artifactory; af != nil {
+		p := path.Child("artifactory")
+		required(p.Child("url"), af.URL)
+		v.checkSecretKeySelector(p.Child("usernameSecret"), af.UsernameSecret)
+		v.checkSecretKeySelector(p.Child("passwordSecret"), af.PasswordSecret)
+	}
+	if h := a.HDFS; h != nil {
+		required(path.Child("hdfs", "path"), h.Path)
+	}
+	if o := a.OSS; o != nil {
+		p := path.Child("oss")
+		required(p.Child("key"), o.Key)
+		v.checkSecretKeySelector(p.Child("accessKeySecret"), o.AccessKeySecret)
+		v.checkSecretKeySelector(p.Child("secretKeySecret"), o.SecretKeySecret)
+	}
+	if g := a.GCS; g != nil {
+		p := path.Child("gcs")
+		required(p.Child("key"), g.Key)
+		v.checkSecretKeySelector(p.Child("serviceAccountKeySecret"), g.ServiceAccountKeySecret)
+	}
+	if az := a.Azure; az != nil {
+		p := path.Child("azure")
+		required(p.Child("blob"), az.Blob)
+		if az.UseSDKCreds && az.AccountKeySecret != nil {
+			v.errs = append(v.errs, field.Forbidden(p.Child("accountKeySecret"), "accountKeySecret and useSDKCreds cannot be used together"))
+		}
+		v.checkSecretKeySelector(p.Child("accountKeySecret"), az.AccountKeySecret)
+	}
+}
+
+func (v *validator) checkSecretKeySelector(path *field.Path, sel *SecretKeySelector) {
+	if sel == nil {
+		return
+	}
+	if sel.Name == "" {
+		v.errs = append(v.errs, field.Required(path.Child("name"), ""))
+	} else {
+		v.checkDNSSubdomain(path.Child("name"), sel.Name)
+	}
+	if sel.Key == "" {
+		v.errs = append(v.errs, field.Required(path.Child("key"), ""))
+	}
+}
+
+func (v *validator) checkArtifactGC(path *field.Path, gc *ArtifactGC) {
+	if gc == nil {
+		return
+	}
+	if gc.Strategy != "" && !slices.Contains(artifactGCStrategies, gc.Strategy) {
+		v.errs = append(v.errs, field.NotSupported(path.Child("strategy"), gc.Strategy, artifactGCStrategies))
+	}
+	if gc.ServiceAccountName != "" {
+		v.checkDNSSubdomain(path.Child("serviceAccountName"), gc.ServiceAccountName)
+	}
+	v.checkMetadata(path.Child("podMetadata"), gc.PodMetadata)
+}
+
+// checkVolumeMounts checks that the mounts of a container name a volume
+// declared by the template or, when known, the workflow, and that no two
+// share a mount path.
+func (v *validator) checkVolumeMounts(path *field.Path, mounts []VolumeMount, templateVolumes map[string]bool) {
+	mountPaths := make(map[string]bool, len(mounts))
+	for i, m := range mounts {
+		p := path.Index(i)
+		switch {
+		case m.Name == "":
+			v.errs = append(v.errs, field.Required(p.Child("name"), ""))
+		case templateVolumes[m.Name], v.volumes == nil, v.volumes[m.Name]:
+		default:
+			v.errs = append(v.errs, field.NotFound(p.Child("name"), m.Name))
+		}
+		if m.MountPath == "" {
+			v.errs = append(v.errs, field.Required(p.Child("mountPath"), ""))
+		} else if !strings.HasPrefix(m.MountPath, "/") {
+			v.errs = append(v.errs, field.Invalid(p.Child("mountPath"), m.MountPath, "must be an absolute path"))
+		} else if mp := pathpkg.Clean(m.MountPath); mountPaths[mp] {
+			v.errs = append(v.errs, field.Duplicate(p.Child("mountPath"), m.MountPath))
+		} else {
+			mountPaths[mp] = true
+		}
+		v.checkRelativePath(p.Child("subPath"), m.SubPath, false)
+	}
+}
+
+// checkRelativePath reports absolute paths and paths that escape their
+// volume through "..".
+func (v *validator) checkRelativePath(path *field.Path, s string, required bool) {
+	switch {
+	case s == "":
+		if required {
+			v.errs = append(v.errs, field.Required(path, ""))
+		}
+	case strings.HasPrefix(s, "/"):
+		v.errs = append(v.errs, field.Invalid(path, s, "must be a relative path"))
+	case slices.Contains(strings.Split(s, "/"), ".."):
+		v.errs = append(v.errs, field.Invalid(path, s, "must not contain '..'"))
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkIntOrString checks that a value is an integer, or a string that
+// holds one or a template placeholder.
+func (v *validator) checkIntOrString(path *field.Path, value *intstr.IntOrString) {
+	if value == nil || value.Type == intstr.Int || len(Placeholders(value.StrVal)) > 0 {
+		return
+	}
+	if _, err := strconv.Atoi(value.StrVal); err != nil {
+		v.errs = append(v.errs, field.Invalid(path, value.StrVal, "must be an integer"))
+	}
+}
+
+// checkItemReferences reports {{item}} references in a task or step that
+// does not loop, where they could never be resolved.
+func (v *validator) checkItemReferences(path *field.Path, args *Arguments, when string) {
+	if args != nil {
+		for i, p := range args.Parameters {
+			if str, ok := p.Value.(string); ok && hasItemReference(str) {
+				v.errs = append(v.errs, field.Invalid(path.Child("arguments", "parameters").Index(i).Child("value"), str,
+					"{{item}} can only be used with withItems, withParam or withSequence"))
+			}
+		}
+	}
+	if hasItemReference(when) {
+		v.errs = append(v.errs, field.Invalid(path.Child("when"), when,
+			"{{item}} can only be used with withItems, withParam or withSequence"))
+	}
+}
+
+func hasItemReference(s string) bool {
+	for _, name := range Placeholders(s) {
+		if isItemReference(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) checkCondition(path *field.Path, cond string) {
+	if err := CheckCondition(cond); err != nil {
+		v.errs = append(v.errs, field.Invalid(path, cond, err.Error()))
+	}
+}
+
+// checkName validates template, task and step names. Argo applies the
+// DNS-1123 label rules to these names but, unlike Kubernetes, accepts upper
+// case letters, so "A" is a valid task name.
+func (v *validator) checkName(path *field.Path, name string) {
+	if name == "" {
+		v.errs = append(v.errs, field.Required(path, ""))
+		return
+	}
+	for _, msg := range validation.IsDNS1123Label(strings.ToLower(name)) {
+		v.errs = append(v.errs, field.Invalid(path, name, msg))
+	}
+}
+
+func (v *validator) checkDNSSubdomain(path *field.Path, name string) {
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		v.errs = append(v.errs, field.Invalid(path, name, msg))
+	}
+}
+
+func (v *validator) checkNamespace(path *field.Path, ns string) {
+	if ns == "" {
+		return
+	}
+	for _, msg := range validation.IsDNS1123Label(ns) {
+		v.errs = append(v.errs, field.Invalid(path, ns, msg))
+	}
+}
+
+// checkNonNegative reports a negative count or number of seconds.
+func checkNonNegative[T int32 | int64](v *validator, path *field.Path, value *T) {
+	if value != nil && *value < 0 {
+		v.errs = append(v.errs, field.Invalid(path, *value, "must be non-negative"))
+	}
+}
+
+func (v *validator) checkDuration(path *field.Path, s string) {
+	if s == "" {
+		return
+	}
+	if _, err := ParseDuration(s); err != nil {
+		v.errs = append(v.errs, field.Invalid(path, s, err.Error()))
+	}
+}
+
+func (v *validator) checkResourceList(path *field.Path, list ResourceList) {
+	for _, name := range sortedKeys(list) {
+		quantity := list[name]
+		if _, err := resource.ParseQuantity(quantity); err != nil {
+			v.errs = append(v.errs, field.Invalid(path.Key(name), quantity, err.Error()))
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidate(t *testing.T) {
+	echo := ContainerTemplate("echo", WithImage("alpine:3.18"))
+	echo.Inputs = NewInputs().AddParameter(Parameter{Name: "message"})
+
+	wf := &Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "Bad_Name"},
+		Spec: WorkflowSpec{
+			Entrypoint: "main",
+			Templates: []Template{
+				ContainerTemplate("noimage"),
+				echo,
+				NewDAG("main").
+					Task("a", "echo", WithArguments(NewArguments().AddParameter(Parameter{Name: "message", Value: "hi"}))).
+					Task("b", "missing").
+					Task("c", "echo", WithDependencies("a")).
+					Build(),
+				{
+					Name:   "both",
+					Script: &Script{Image: "python:3.11", Source: "print(1)"},
+					Container: &Container{
+						Image:     "alpine:3.18",
+						Resources: &Resources{Limits: ResourceList{"memory": "lots"}},
+					},
+					Timeout: &TimeoutPolicy{Duration: "soon"},
+				},
+			},
+		},
+	}
+
+	errs := Validate(wf)
+
+	want := map[string]field.ErrorType{
+		"metadata.name":                                        field.ErrorTypeInvalid,
+		"spec.templates[0].container.image":                    field.ErrorTypeRequired,
+		"spec.templates[2].dag.tasks[1].template":              field.ErrorTypeNotFound,
+		"spec.templates[2].dag.tasks[2].arguments.parameters":  field.ErrorTypeRequired,
+		"spec.templates[3]":                                    field.ErrorTypeForbidden,
+		"spec.templates[3].container.resources.limits[memory]": field.ErrorTypeInvalid,
+		"spec.templates[3].timeout.duration":                   field.ErrorTypeInvalid,
+	}
+
+	got := make(map[string]field.ErrorType, len(errs))
+	for _, err := range errs {
+		got[err.Field] = err.Type
+	}
+	for path, typ := range want {
+		if got[path] != typ {
+			t.Errorf("%s: got %q, want %q", path, got[path], typ)
+		}
+	}
+	if len(errs) != len(want) {
+		t.Errorf("got %d errors, want %d:\n%v", len(errs), len(want), errs.ToAggregate())
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	wf, err := New("valid").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"), WithRetryBackoff("10", 2, "5m"))).
+		WithTemplate(NewDAG("main").
+			Task("A", "echo").
+			Task("B", "echo", WithDependencies("A")).
+			Build()).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if errs := Validate(wf); len(errs) > 0 {
+		t.Errorf("Validate() = %v, want no errors", errs)
+	}
+}
+
+func TestValidateSteps(t *testing.T) {
+	wf := &Workflow{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "steps-"},
+		Spec: WorkflowSpec{
+			Entrypoint: "main",
+			Templates: []Template{
+				ContainerTemplate("echo", WithImage("alpine:3.18")),
+				{
+					Name: "main",
+					Steps: &[][]StepGroup{
+						{{Name: "one", Template: "echo"}},
+						{{Name: "two", Template: "echo", When: "{{steps.one.outputs.result}} =="}, {Name: "one", Template: "echo"}},
+					},
+				},
+			},
+		},
+	}
+
+	errs := Validate(wf)
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors, want 2: %v", len(errs), errs)
+	}
+	if errs[0].Field != "spec.templates[1].steps[1][0].when" {
+		t.Errorf("Field = %v, want spec.templates[1].steps[1][0].when", errs[0].Field)
+	}
+	if errs[1].Field != "spec.templates[1].steps[1][1].name" || errs[1].Type != field.ErrorTypeDuplicate {
+		t.Errorf("got %v, want duplicate spec.templates[1].steps[1][1].name", errs[1])
+	}
+}
+
+func TestValidateCycle(t *testing.T) {
+	wf := &Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "cycle"},
+		Spec: WorkflowSpec{
+			Entrypoint: "main",
+			Templates: []Template{
+				ContainerTemplate("echo", WithImage("alpine:3.18")),
+				NewDAG("main").
+					Task("A", "echo", WithDependencies("B")).
+					Task("B", "echo", WithDependencies("A")).
+					Build(),
+			},
+		},
+	}
+
+	errs := Validate(wf)
+	if len(errs) != 1 || errs[0].Field != "spec.templates[1].dag.tasks" {
+		t.Fatalf("Validate() = %v, want one error at spec.templates[1].dag.tasks", errs)
+	}
+	if !strings.Contains(errs[0].Detail, "cycle") {
+		t.Errorf("Detail = %v, want cycle error", errs[0].Detail)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"30", "30s", false},
+		{"1m30s", "1m30s", false},
+		{"2h", "2h0m0s", false},
+		{"soon", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDuration(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMissingDependencies(t *testing.T) {
+	wf := &Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "missing"},
+		Spec: WorkflowSpec{
+			Entrypoint: "main",
+			Templates: []Template{
+				ContainerTemplate("echo", WithImage("alpine:3.18")),
+				NewDAG("main").
+					Task("A", "echo", WithDependencies("X")).
+					Task("B", "echo", WithDependencies("A", "Y")).
+					Build(),
+			},
+		},
+	}
+
+	var got []string
+	for _, err := range Validate(wf) {
+		got = append(got, err.Field)
+	}
+	want := "spec.templates[1].dag.tasks[0].dependencies[0] spec.templates[1].dag.tasks[1].dependencies[1]"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Validate() fields = %v, want %v", got, want)
+	}
+}
+
+func TestValidateDepends(t *testing.T) {
+	_, err := New("depends").
+		WithEntrypoint("main").
+		WithTemplate(ContainerTemplate("echo", WithImage("alpine:3.18"))).
+		WithTemplate(NewDAG("main").
+			Task("a", "echo").
+			Task("fan", "echo", WithItems(1, 2)).
+			Task("b", "echo", WithDepends("a.AnySucceeded || fan.AllFailed || ghost")).
+			Task("c", "echo", WithDepends("a &&"), WithDependencies("a")).
+			Build()).
+		Build()
+	if err == nil {
+		t.Fatal("Build() should reject invalid depends")
+	}
+
+	for _, want := range []string{
+		`spec.templates[1].dag.tasks[2].depends: Not found: "ghost"`,
+		`spec.templates[1].dag.tasks[2].depends: Invalid value: "a.AnySucceeded || fan.AllFailed || ghost": a.AnySucceeded is only valid for tasks with withItems, withParam or withSequence`,
+		`spec.templates[1].dag.tasks[3].depends: Invalid value: "a &&": cannot be used together with dependencies`,
+		`spec.templates[1].dag.tasks[3].depends: Invalid value: "a &&": syntax error at position 5`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "fan.AllFailed is only valid") {
+		t.Errorf("fan is looped:\n%v", err)
+	}
+}
+
+func TestValidateTemplateTypes(t *testing.T) {
+	both := SuspendTemplate("both", "")
+	both.HTTP = &HTTP{URL: "https://example.com"}
+	patch := ResourceTemplate("patch", ResourceActionApply, "kind: Job", WithMergeStrategy(MergeStrategyMerge), WithResourceSuccessCondition("status.succeeded >"))
+	http := HTTPTemplate("http", "", WithHTTPSuccessCondition("response.statusCode =="))
+	http.HTTP.Headers = []HTTPHeader{{Value: "x", ValueFrom: &HTTPHeaderSource{}}}
+
+	wf := &Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "types"},
+		Spec: WorkflowSpec{
+			Entrypoint: "none",
+			Templates: []Template{
+				{Name: "none"},
+				both,
+				patch,
+				ResourceTemplate("scale", "scale", ""),
+				ResourceTemplate("cleanup", ResourceActionDelete, "", WithResourceFlags("-l", "app=load")),
+				SuspendTemplate("wait", "later"),
+				http,
+				DataTemplate("data", Artifact{Name: "raw"}),
+				HTTPTemplate("google", "https://www.google.com", WithHTTPSuccessCondition(`response.body contains "google"`)),
+			},
+		},
+	}
+
+	want := map[string]field.ErrorType{
+		"spec.templates[0]":                                        field.ErrorTypeRequired,
+		"spec.templates[1]":                                        field.ErrorTypeForbidden,
+		"spec.templates[2].resource.mergeStrategy":                 field.ErrorTypeForbidden,
+		"spec.templates[2].resource.manifest":                      field.ErrorTypeInvalid,
+		"spec.templates[2].resource.successCondition":              field.ErrorTypeInvalid,
+		"spec.templates[3].resource.action":                        field.ErrorTypeNotSupported,
+		"spec.templates[3].resource.manifest":                      field.ErrorTypeRequired,
+		"spec.templates[5].suspend.duration":                       field.ErrorTypeInvalid,
+		"spec.templates[6].http.url":                               field.ErrorTypeRequired,
+		"spec.templates[6].http.headers[0].name":                   field.ErrorTypeRequired,
+		"spec.templates[6].http.headers[0].valueFrom":              field.ErrorTypeForbidden,
+		"spec.templates[6].http.headers[0].valueFrom.secretKeyRef": field.ErrorTypeRequired,
+		"spec.templates[6].http.successCondition":                  field.ErrorTypeInvalid,
+		"spec.templates[7].data.transformation":                    field.ErrorTypeRequired,
+	}
+
+	errs := Validate(wf)
+	got := make(map[string]field.ErrorType, len(errs))
+	for _, err := range errs {
+		got[err.Field] = err.Type
+	}
+	for path, typ := range want {
+		if got[path] != typ {
+			t.Errorf("%s: got %q, want %q", path, got[path], typ)
+		}
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok {
+			t.Errorf("unexpected error at %s: %v", path, errs)
+		}
+	}
+
+	if msg := errs.ToAggregate().Error(); !strings.Contains(msg, "spec.templates[1]: Forbidden: exactly one of container, script, dag, steps, resource, suspend, http, data or containerSet must be set, got suspend, http") {
+		t.Errorf("errors = %v", msg)
+	}
+}
+
+func TestValidatePodContainers(t *testing.T) {
+	wf := &Workflow{}
+	wf.Name = "containers"
+	wf.Spec.Entrypoint = "main"
+	wf.Spec.Templates = []Template{
+		NewDAG("main").Task("a", "test").Build(),
+		ContainerTemplate("test",
+			WithImage("golang:1.21"),
+			WithInitContainer("main", WithImage("alpine:3.18")),
+			WithSidecar("db"),
+			WithSidecar("db", WithImage("postgres:16"), WithVolumeMounts(VolumeMount{Name: "data", MountPath: "/data"}))),
+		ContainerSetTemplate("set",
+			WithContainerNode("a", []string{"b"}, WithImage("alpine:3.18")),
+			WithContainerNode("b", []string{"a", "c"}, WithImage("alpine:3.18")),
+			WithContainerNode("B", nil)),
+		ContainerSetTemplate("empty"),
+	}
+	wf.Spec.Templates[0].Daemon = &[]bool{true}[0]
+	wf.Spec.Templates[0].Sidecars = []UserContainer{{Container: Container{Name: "proxy", Image: "envoy"}}}
+	wf.Spec.Templates[2].Sidecars = []UserContainer{{Container: Container{Name: "a", Image: "envoy"}}}
+	wf.Spec.Templates[2].InitContainers = []UserContainer{{Container: Container{Name: "main", Image: "alpine:3.18"}}}
+	wf.Spec.Templates[3].ContainerSet.RetryStrategy = &ContainerSetRetryStrategy{Duration: "soon"}
+
+	want := map[string]field.ErrorType{
+		"spec.templates[0].daemon":                                     field.ErrorTypeForbidden,
+		"spec.templates[0]":                                            field.ErrorTypeForbidden,
+		"spec.templates[1].initContainers[0].name":                     field.ErrorTypeDuplicate,
+		"spec.templates[1].sidecars[0].image":                          field.ErrorTypeRequired,
+		"spec.templates[1].sidecars[1].name":                           field.ErrorTypeDuplicate,
+		"spec.templates[1].sidecars[1].volumeMounts[0].name":           field.ErrorTypeNotFound,
+		"spec.templates[2].sidecars[0].name":                           field.ErrorTypeDuplicate,
+		"spec.templates[2].containerSet.containers":                    field.ErrorTypeInvalid,
+		"spec.templates[2].containerSet.containers[1].dependencies[1]": field.ErrorTypeNotFound,
+		"spec.templates[2].containerSet.containers[2].name":            field.ErrorTypeInvalid,
+		"spec.templates[2].containerSet.containers[2].image":           field.ErrorTypeRequired,
+		"spec.templates[3].containerSet.containers":                    field.ErrorTypeRequired,
+		"spec.templates[3].containerSet.retryStrategy.retries":         field.ErrorTypeRequired,
+		"spec.templates[3].containerSet.retryStrategy.duration":        field.ErrorTypeInvalid,
+	}
+
+	errs := Validate(wf)
+	got := make(map[string]field.ErrorType, len(errs))
+	for _, err := range errs {
+		got[err.Field] = err.Type
+	}
+	for path, typ := range want {
+		if got[path] != typ {
+			t.Errorf("%s: got %q, want %q", path, got[path], typ)
+		}
+	}
+	if _, ok := got["spec.templates[2].initContainers[0].name"]; ok {
+		t.Errorf("init container main of a containerSet was rejected: %v", errs)
+	}
+}
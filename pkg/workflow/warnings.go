@@ -0,0 +1,79 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Warning describes a setting that is valid but probably does not do what
+// was intended, such as a template timeout longer than the workflow may
+// run.
+type Warning struct {
+	Field   string
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Field + ": " + w.Message
+}
+
+// Warnings checks a workflow for time limits that cannot take effect:
+// template timeouts and deadlines longer than the workflow's
+// activeDeadlineSeconds, and retry backoff that waits longer in total than
+// the template's timeout, or the workflow's deadline, allows. Unlike the
+// problems Validate reports, these do not stop a workflow from running, so
+// Build does not check for them.
+func Warnings(wf *Workflow) []Warning {
+	var warnings []Warning
+	warn := func(path *field.Path, format string, args ...interface{}) {
+		warnings = append(warnings, Warning{Field: path.String(), Message: fmt.Sprintf(format, args...)})
+	}
+
+	var deadline time.Duration
+	if wf.Spec.ActiveDeadline != nil {
+		deadline = time.Duration(*wf.Spec.ActiveDeadline) * time.Second
+	}
+
+	templatesPath := field.NewPath("spec", "templates")
+	for i := range wf.Spec.Templates {
+		t := &wf.Spec.Templates[i]
+		path := templatesPath.Index(i)
+
+		var timeout time.Duration
+		if t.Timeout != nil && t.Timeout.Duration != "" {
+			timeout, _ = ParseDuration(t.Timeout.Duration)
+		}
+		if deadline > 0 && timeout > deadline {
+			warn(path.Child("timeout", "duration"), "timeout of %v exceeds the workflow's activeDeadlineSeconds of %v", timeout, deadline)
+		}
+		if d := t.ActiveDeadlineSeconds; d != nil && d.Type == intstr.Int && deadline > 0 {
+			if seconds := time.Duration(d.IntVal) * time.Second; seconds > deadline {
+				warn(path.Child("activeDeadlineSeconds"), "deadline of %v exceeds the workflow's activeDeadlineSeconds of %v", seconds, deadline)
+			}
+		}
+
+		if t.RetryStrategy == nil || t.RetryStrategy.Backoff == nil {
+			continue
+		}
+		schedule, err := t.RetryStrategy.Schedule()
+		if err != nil {
+			continue
+		}
+		var total time.Duration
+		for _, delay := range schedule {
+			total += delay
+		}
+
+		backoffPath := path.Child("retryStrategy", "backoff")
+		switch {
+		case timeout > 0 && total >= timeout:
+			warn(backoffPath, "retries wait %v in total, which does not fit within the timeout of %v", total, timeout)
+		case timeout == 0 && deadline > 0 && total >= deadline:
+			warn(backoffPath, "retries wait %v in total, which does not fit within the workflow's activeDeadlineSeconds of %v", total, deadline)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,42 @@
+package workflow
+
+import (
+	"testing"
+)
+
+func TestWarnings(t *testing.T) {
+	limit := int32(4)
+	slow := ContainerTemplate("slow", WithImage("alpine:3.18"), WithTimeout("2h"), WithActiveDeadlineSeconds(7200))
+	flaky := ContainerTemplate("flaky", WithImage("alpine:3.18"), WithTimeout("1m"), WithRetryBackoff("10s", 2, ""))
+	flaky.RetryStrategy.Limit = &limit
+	patient := ContainerTemplate("patient", WithImage("alpine:3.18"), WithRetryBackoff("30m", 1, ""))
+	fine := ContainerTemplate("fine", WithImage("alpine:3.18"), WithTimeout("10m"), WithRetryBackoff("10s", 2, "1m"))
+
+	wf, err := New("warn").
+		WithEntrypoint("slow").
+		WithActiveDeadline(3600).
+		WithTemplate(slow).
+		WithTemplate(flaky).
+		WithTemplate(patient).
+		WithTemplate(fine).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	want := []string{
+		"spec.templates[0].timeout.duration: timeout of 2h0m0s exceeds the workflow's activeDeadlineSeconds of 1h0m0s",
+		"spec.templates[0].activeDeadlineSeconds: deadline of 2h0m0s exceeds the workflow's activeDeadlineSeconds of 1h0m0s",
+		"spec.templates[1].retryStrategy.backoff: retries wait 2m30s in total, which does not fit within the timeout of 1m0s",
+		"spec.templates[2].retryStrategy.backoff: retries wait 1h30m0s in total, which does not fit within the workflow's activeDeadlineSeconds of 1h0m0s",
+	}
+	got := Warnings(wf)
+	if len(got) != len(want) {
+		t.Fatalf("Warnings() = %v, want %d warnings", got, len(want))
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("warning %d = %q, want %q", i, got[i].String(), want[i])
+		}
+	}
+}
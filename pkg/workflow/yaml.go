@@ -72,6 +72,147 @@ func FromYAMLReader(r io.Reader) (*Workflow, error) {
 	return FromYAML(data)
 }
 
+// ToYAML serializes a WorkflowTemplate to YAML format.
+func (wft *WorkflowTemplate) ToYAML() ([]byte, error) {
+	wft.APIVersion = "argoproj.io/v1alpha1"
+	wft.Kind = "WorkflowTemplate"
+
+	data, err := yaml.Marshal(wft)
+	if err != nil {
+		return nil, fmt.Errorf("marshal workflow template: %w", err)
+	}
+
+	return data, nil
+}
+
+// ToYAMLFile writes a WorkflowTemplate to a YAML file.
+func (wft *WorkflowTemplate) ToYAMLFile(filename string) error {
+	return writeYAMLFile(filename, wft.ToYAML)
+}
+
+// WorkflowTemplateFromYAML deserializes a WorkflowTemplate from YAML.
+func WorkflowTemplateFromYAML(data []byte) (*WorkflowTemplate, error) {
+	var wft WorkflowTemplate
+	if err := yaml.Unmarshal(data, &wft); err != nil {
+		return nil, fmt.Errorf("unmarshal workflow template: %w", err)
+	}
+
+	if wft.Kind != "WorkflowTemplate" {
+		return nil, fmt.Errorf("invalid kind: %s (expected WorkflowTemplate)", wft.Kind)
+	}
+
+	return &wft, nil
+}
+
+// WorkflowTemplateFromYAMLFile reads a WorkflowTemplate from a YAML file.
+func WorkflowTemplateFromYAMLFile(filename string) (*WorkflowTemplate, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	return WorkflowTemplateFromYAML(data)
+}
+
+// ToYAML serializes a ClusterWorkflowTemplate to YAML format.
+func (cwft *ClusterWorkflowTemplate) ToYAML() ([]byte, error) {
+	cwft.APIVersion = "argoproj.io/v1alpha1"
+	cwft.Kind = "ClusterWorkflowTemplate"
+
+	data, err := yaml.Marshal(cwft)
+	if err != nil {
+		return nil, fmt.Errorf("marshal cluster workflow template: %w", err)
+	}
+
+	return data, nil
+}
+
+// ToYAMLFile writes a ClusterWorkflowTemplate to a YAML file.
+func (cwft *ClusterWorkflowTemplate) ToYAMLFile(filename string) error {
+	return writeYAMLFile(filename, cwft.ToYAML)
+}
+
+// ClusterWorkflowTemplateFromYAML deserializes a ClusterWorkflowTemplate
+// from YAML.
+func ClusterWorkflowTemplateFromYAML(data []byte) (*ClusterWorkflowTemplate, error) {
+	var cwft ClusterWorkflowTemplate
+	if err := yaml.Unmarshal(data, &cwft); err != nil {
+		return nil, fmt.Errorf("unmarshal cluster workflow template: %w", err)
+	}
+
+	if cwft.Kind != "ClusterWorkflowTemplate" {
+		return nil, fmt.Errorf("invalid kind: %s (expected ClusterWorkflowTemplate)", cwft.Kind)
+	}
+
+	return &cwft, nil
+}
+
+// ClusterWorkflowTemplateFromYAMLFile reads a ClusterWorkflowTemplate from
+// a YAML file.
+func ClusterWorkflowTemplateFromYAMLFile(filename string) (*ClusterWorkflowTemplate, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	return ClusterWorkflowTemplateFromYAML(data)
+}
+
+// ToYAML serializes a CronWorkflow to YAML format.
+func (cwf *CronWorkflow) ToYAML() ([]byte, error) {
+	cwf.APIVersion = "argoproj.io/v1alpha1"
+	cwf.Kind = "CronWorkflow"
+
+	data, err := yaml.Marshal(cwf)
+	if err != nil {
+		return nil, fmt.Errorf("marshal cron workflow: %w", err)
+	}
+
+	return data, nil
+}
+
+// ToYAMLFile writes a CronWorkflow to a YAML file.
+func (cwf *CronWorkflow) ToYAMLFile(filename string) error {
+	return writeYAMLFile(filename, cwf.ToYAML)
+}
+
+// CronWorkflowFromYAML deserializes a CronWorkflow from YAML.
+func CronWorkflowFromYAML(data []byte) (*CronWorkflow, error) {
+	var cwf CronWorkflow
+	if err := yaml.Unmarshal(data, &cwf); err != nil {
+		return nil, fmt.Errorf("unmarshal cron workflow: %w", err)
+	}
+
+	if cwf.Kind != "CronWorkflow" {
+		return nil, fmt.Errorf("invalid kind: %s (expected CronWorkflow)", cwf.Kind)
+	}
+
+	return &cwf, nil
+}
+
+// CronWorkflowFromYAMLFile reads a CronWorkflow from a YAML file.
+func CronWorkflowFromYAMLFile(filename string) (*CronWorkflow, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	return CronWorkflowFromYAML(data)
+}
+
+func writeYAMLFile(filename string, marshal func() ([]byte, error)) error {
+	data, err := marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
+
 // YAMLBuilder provides a fluent API for YAML workflow operations.
 type YAMLBuilder struct {
 	wf *Workflow
@@ -233,3 +233,183 @@ func TestInvalidYAML(t *testing.T) {
 		})
 	}
 }
+
+func TestYAMLLoops(t *testing.T) {
+	yamlData := []byte(`
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: loops
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: items
+        template: echo
+        withItems: [a, {os: linux}]
+      - name: param
+        template: echo
+        withParam: "{{tasks.items.outputs.result}}"
+      - name: seq
+        template: echo
+        withSequence:
+          count: "5"
+          start: 1
+          format: "%02d"
+  - name: echo
+    container:
+      image: alpine:latest
+`)
+
+	wf, err := FromYAML(yamlData)
+	if err != nil {
+		t.Fatalf("FromYAML() error = %v", err)
+	}
+
+	tasks := wf.Spec.Templates[0].DAG.Tasks
+	if len(tasks[0].WithItems) != 2 {
+		t.Errorf("withItems = %v, want 2 items", tasks[0].WithItems)
+	}
+	if tasks[1].WithParam != "{{tasks.items.outputs.result}}" {
+		t.Errorf("withParam = %v", tasks[1].WithParam)
+	}
+	seq := tasks[2].WithSequence
+	if seq == nil || seq.Count.String() != "5" || seq.Start.IntValue() != 1 || seq.Format != "%02d" {
+		t.Fatalf("withSequence = %+v", seq)
+	}
+
+	out, err := wf.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML() error = %v", err)
+	}
+	for _, want := range []string{"withItems:", "withParam:", "count: \"5\"", "start: 1"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("ToYAML() missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestYAMLTemplateTypes(t *testing.T) {
+	manifest := "apiVersion: batch/v1\nkind: Job\nmetadata:\n  generateName: load-\n"
+	original, err := New("template-types").
+		WithEntrypoint("main").
+		WithTemplate(NewSteps("main").
+			Step("apply", "apply").
+			Step("approve", "approve").
+			Step("notify", "notify").
+			Step("select", "select").
+			Build()).
+		WithTemplate(ResourceTemplate("apply", ResourceActionCreate, manifest,
+			WithResourceSuccessCondition("status.succeeded > 0"),
+			WithResourceFailureCondition("status.failed > 3"),
+			WithOwnerReference())).
+		WithTemplate(SuspendTemplate("approve", "30m")).
+		WithTemplate(HTTPTemplate("notify", "https://example.com/hooks/{{workflow.name}}",
+			WithHTTPMethod("POST"),
+			WithHTTPHeader("Content-Type", "application/json"),
+			WithHTTPSecretHeader("Authorization", "hook-token", "token"),
+			WithHTTPBody(`{"status": "done"}`),
+			WithHTTPSuccessCondition("response.statusCode == 201"),
+			WithHTTPTimeout(20))).
+		WithTemplate(DataTemplate("select",
+			Artifact{Name: "raw", S3: &S3Artifact{Bucket: "lake", Key: "raw/"}},
+			`filter(data, {# endsWith ".csv"})`)).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	data, err := original.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML() error = %v", err)
+	}
+	for _, want := range []string{
+		"resource:\n      action: create\n      failureCondition: status.failed > 3\n      manifest: |\n        apiVersion: batch/v1",
+		"suspend:\n      duration: 30m",
+		"http:\n      body: '{\"status\": \"done\"}'",
+		"valueFrom:\n          secretKeyRef:\n            key: token\n            name: hook-token",
+		"source:\n        artifactPaths:\n          name: raw\n          s3:",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("YAML missing %q:\n%s", want, data)
+		}
+	}
+
+	parsed, err := FromYAMLStrict(data)
+	if err != nil {
+		t.Fatalf("FromYAMLStrict() error = %v", err)
+	}
+	templates := parsed.Spec.Templates
+	if r := templates[1].Resource; r == nil || r.Manifest != manifest || !r.SetOwnerReference || r.SuccessCondition != "status.succeeded > 0" {
+		t.Errorf("Resource = %+v, want it to round-trip", r)
+	}
+	if s := templates[2].Suspend; s == nil || s.Duration != "30m" {
+		t.Errorf("Suspend = %+v, want 30m", s)
+	}
+	if h := templates[3].HTTP; h == nil || h.Method != "POST" || len(h.Headers) != 2 || *h.TimeoutSeconds != 20 {
+		t.Errorf("HTTP = %+v, want it to round-trip", h)
+	}
+	if d := templates[4].Data; d == nil || d.Source.ArtifactPaths.S3.Bucket != "lake" || len(d.Transformation) != 1 {
+		t.Errorf("Data = %+v, want it to round-trip", d)
+	}
+}
+
+func TestYAMLPodContainers(t *testing.T) {
+	original, err := New("pod-containers").
+		WithEntrypoint("main").
+		WithTemplate(NewDAG("main").
+			Task("db", "db").
+			Task("test", "test", WithDependencies("db")).
+			Task("build", "build").
+			Build()).
+		WithTemplate(ContainerTemplate("db", WithImage("postgres:16"), WithDaemon())).
+		WithTemplate(ContainerTemplate("test",
+			WithImage("golang:1.21"),
+			WithCommand("go", "test", "./..."),
+			WithInitContainer("migrate", WithImage("migrate/migrate"), WithArgs("up")),
+			WithSidecar("redis", WithImage("redis:7")))).
+		WithTemplate(ContainerSetTemplate("build",
+			WithContainerNode("fetch", nil, WithImage("alpine/git")),
+			WithContainerNode("main", []string{"fetch"}, WithImage("golang:1.21"), WithCommand("go", "build")),
+			WithContainerSetVolumeMounts(VolumeMount{Name: "workspace", MountPath: "/workspace"}),
+			WithContainerSetRetries(2, "10s"),
+			WithTemplateVolumes(EmptyDirVolume("workspace")))).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	data, err := original.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML() error = %v", err)
+	}
+	for _, want := range []string{
+		"daemon: true",
+		"initContainers:\n    - args:\n      - up\n      image: migrate/migrate\n      name: migrate",
+		"sidecars:\n    - image: redis:7\n      name: redis",
+		"containerSet:\n      containers:\n      - image: alpine/git\n        name: fetch\n      - command:",
+		"dependencies:\n        - fetch",
+		"retryStrategy:\n        duration: 10s\n        retries: 2",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("YAML missing %q:\n%s", want, data)
+		}
+	}
+
+	parsed, err := FromYAMLStrict(data)
+	if err != nil {
+		t.Fatalf("FromYAMLStrict() error = %v", err)
+	}
+	templates := parsed.Spec.Templates
+	if d := templates[1].Daemon; d == nil || !*d {
+		t.Errorf("Daemon = %v, want true", d)
+	}
+	if test := templates[2]; len(test.InitContainers) != 1 || test.Sidecars[0].Image != "redis:7" {
+		t.Errorf("init containers = %+v, sidecars = %+v, want them to round-trip", test.InitContainers, test.Sidecars)
+	}
+	if cs := templates[3].ContainerSet; cs == nil || len(cs.Containers) != 2 || cs.Containers[1].Dependencies[0] != "fetch" || cs.RetryStrategy.Retries.IntValue() != 2 {
+		t.Errorf("ContainerSet = %+v, want it to round-trip", cs)
+	}
+}